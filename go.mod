@@ -7,6 +7,7 @@ require (
 	github.com/grafana/grafana-plugin-sdk-go v0.291.0
 	github.com/magefile/mage v1.16.1
 	github.com/montanaflynn/stats v0.9.0
+	github.com/prometheus/client_golang v1.23.2
 	google.golang.org/protobuf v1.36.11
 	nhooyr.io/websocket v1.8.17
 )
@@ -57,7 +58,6 @@ require (
 	github.com/olekukonko/tablewriter v1.1.4 // indirect
 	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
 	github.com/pierrec/lz4/v4 v4.1.26 // indirect
-	github.com/prometheus/client_golang v1.23.2 // indirect
 	github.com/prometheus/client_model v0.6.2 // indirect
 	github.com/prometheus/common v0.67.5 // indirect
 	github.com/prometheus/procfs v0.20.1 // indirect
@@ -1,12 +1,14 @@
 package aalive
 
 import (
+	"context"
 	"regexp"
 	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"nhooyr.io/websocket"
 )
 
 const (
@@ -42,3 +44,13 @@ func SendErrorFrame(msg string, sender *backend.StreamSender) {
 		log.DefaultLogger.Error("Failed to send error frame", "error", serr)
 	}
 }
+
+func CheckConnection(ctx context.Context, uri string) error {
+	// Open and immediately close a WebSocket connection to confirm that the server is reachable
+	c, _, err := websocket.Dial(ctx, uri, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.Close(websocket.StatusNormalClosure, "")
+}
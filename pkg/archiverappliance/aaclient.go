@@ -3,12 +3,16 @@ package archiverappliance
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
@@ -18,28 +22,73 @@ import (
 type Client interface {
 	FetchRegexTargetPVs(ctx context.Context, regex string, limit int) ([]string, error)
 	ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error)
+	// ExecuteBatchQuery retrieves the data of several PVs in one request. The data is keyed by the PV name.
+	// errBatchUnsupported is returned if the appliance doesn't support the bulk retrieval.
+	ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error)
+	FetchVersion(ctx context.Context) (string, error)
 }
 
 type AAclient struct {
-	baseURL    string
+	// endpoints holds the equivalent retrieval URLs. The first one is the primary.
+	endpoints  *endpoints
 	httpClient *http.Client
+	// batchUnsupported is set once the appliance rejects the bulk retrieval endpoint
+	batchUnsupported *atomic.Bool
 }
 
-func NewAAClient(ctx context.Context, url string, httpOptions httpclient.Options) (*AAclient, error) {
+func NewAAClient(ctx context.Context, url string, httpOptions httpclient.Options, retryOptions RetryOptions) (*AAclient, error) {
+	return NewFailoverAAClient(ctx, []string{url}, httpOptions, retryOptions, 0)
+}
+
+// NewFailoverAAClient returns the client which fails over to the next URL on connection failure or 5xx.
+// The preferred URLs are probed at probeInterval while a replica is in use, and the client fails back to them when they recover.
+// Close must be called to stop the probing.
+func NewFailoverAAClient(ctx context.Context, urls []string, httpOptions httpclient.Options, retryOptions RetryOptions, probeInterval time.Duration) (*AAclient, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no retrieval URL is given")
+	}
+
 	client, err := httpclient.New(httpOptions)
 	if err != nil {
 		return nil, err
 	}
-	return &AAclient{
-		baseURL:    url,
-		httpClient: client,
-	}, nil
+	client.Transport = newRetryTransport(client.Transport, retryOptions)
+
+	aaClient := &AAclient{
+		endpoints:        newEndpoints(urls),
+		httpClient:       client,
+		batchUnsupported: &atomic.Bool{},
+	}
+
+	if len(urls) > 1 {
+		if probeInterval <= 0 {
+			probeInterval = DEFAULT_FAILOVER_PROBE_INTERVAL
+		}
+		go aaClient.endpoints.probe(probeInterval, aaClient.healthy)
+	}
+
+	return aaClient, nil
 }
 
-func (client AAclient) FetchRegexTargetPVs(ctx context.Context, regex string, limit int) ([]string, error) {
-	regexUrl := buildRegexUrl(regex, client.baseURL, limit)
+// Close stops the health probing of the retrieval URLs
+func (client AAclient) Close() {
+	client.endpoints.close()
+}
 
-	regexQueryResponse, err := archiverRegexQuery(ctx, regexUrl, client.httpClient)
+// healthy reports whether the retrieval URL answers
+func (client AAclient) healthy(ctx context.Context, baseURL string) bool {
+	_, err := client.fetchVersion(ctx, baseURL)
+	return err == nil
+}
+
+func (client AAclient) FetchRegexTargetPVs(ctx context.Context, regex string, limit int) ([]string, error) {
+	var regexQueryResponse []byte
+	_, err := client.endpoints.do(ctx, func(baseURL string) error {
+		var err error
+		regexUrl := buildRegexUrl(regex, baseURL, limit)
+		regexQueryResponse, err = archiverRegexQuery(ctx, regexUrl, client.httpClient)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -65,8 +114,14 @@ func (client AAclient) ExecuteSingleQuery(ctx context.Context, target string, qm
 		return sD, nil
 	}
 
-	queryUrl := buildQueryUrl(target, client.baseURL, qm)
-	queryResponse, err := archiverSingleQuery(ctx, queryUrl, client.httpClient)
+	var queryUrl string
+	var queryResponse io.ReadCloser
+	endpoint, err := client.endpoints.do(ctx, func(baseURL string) error {
+		var err error
+		queryUrl = buildQueryUrl(target, baseURL, qm)
+		queryResponse, err = archiverSingleQuery(ctx, queryUrl, client.httpClient)
+		return err
+	})
 
 	if err != nil {
 		err = fmt.Errorf("url = %q: %w", queryUrl, err)
@@ -75,7 +130,7 @@ func (client AAclient) ExecuteSingleQuery(ctx context.Context, target string, qm
 
 	defer queryResponse.Close()
 
-	parsedResponse, err := archiverPBSingleQueryParser(queryResponse, models.FieldName(qm.FieldName), qm.MaxDataPoints, qm.HideInvalid)
+	parsedResponse, err := archiverPBSingleQueryParser(queryResponse, models.FieldName(qm.FieldName), qm.MaxDataPoints, qm.HideInvalid, models.IsPointCountOperator(qm.Operator))
 	if err != nil {
 		err = fmt.Errorf("target = %q: %w", target, err)
 		return parsedResponse, err
@@ -83,15 +138,85 @@ func (client AAclient) ExecuteSingleQuery(ctx context.Context, target string, qm
 
 	parsedResponse.Name = target
 	parsedResponse.PVname = target
+	parsedResponse.Endpoint = endpoint
+	parsedResponse.Meta.UseAlarmThresholds = qm.AlarmThresholds
+	if !qm.SampleMetadata {
+		parsedResponse.SampleMeta = nil
+	}
 
 	return parsedResponse, err
 }
 
+func (client AAclient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	if client.batchUnsupported.Load() {
+		return nil, errBatchUnsupported
+	}
+
+	// For liveOnly response
+	if qm.LiveOnly {
+		result := make(map[string]models.SingleData, len(targets))
+		for _, target := range targets {
+			result[target] = models.SingleData{Name: target, PVname: target, Values: &models.Scalars{}}
+		}
+		return result, nil
+	}
+
+	var queryUrl string
+	var queryResponse io.ReadCloser
+	endpoint, err := client.endpoints.do(ctx, func(baseURL string) error {
+		var err error
+		queryUrl = buildBatchQueryUrl(targets, baseURL, qm)
+		queryResponse, err = archiverSingleQuery(ctx, queryUrl, client.httpClient)
+		return err
+	})
+
+	if err != nil {
+		var statusErr *responseStatusError
+		if errors.As(err, &statusErr) && statusErr.unsupported() {
+			log.DefaultLogger.Info("Bulk retrieval is not supported by the appliance", "status", statusErr.code)
+			client.batchUnsupported.Store(true)
+			return nil, errBatchUnsupported
+		}
+
+		err = fmt.Errorf("url = %q: %w", queryUrl, err)
+		return nil, err
+	}
+
+	defer queryResponse.Close()
+
+	parsedResponses, err := archiverPBQueryParser(queryResponse, models.FieldName(qm.FieldName), qm.MaxDataPoints, qm.HideInvalid, models.IsPointCountOperator(qm.Operator))
+	if err != nil {
+		err = fmt.Errorf("targets = %q: %w", targets, err)
+		return nil, err
+	}
+
+	result := make(map[string]models.SingleData, len(parsedResponses))
+	for _, parsedResponse := range parsedResponses {
+		parsedResponse.Meta.UseAlarmThresholds = qm.AlarmThresholds
+		parsedResponse.Endpoint = endpoint
+		if !qm.SampleMetadata {
+			parsedResponse.SampleMeta = nil
+		}
+		result[parsedResponse.PVname] = parsedResponse
+	}
+
+	return result, nil
+}
+
 func buildQueryUrl(target string, baseURL string, qm models.ArchiverQueryModel) string {
+	const RAW_DATA_URL = "data/getData.raw"
+	return buildDataUrl([]string{target}, RAW_DATA_URL, baseURL, qm)
+}
+
+func buildBatchQueryUrl(targets []string, baseURL string, qm models.ArchiverQueryModel) string {
+	const BATCH_DATA_URL = "data/getDataForPVs.raw"
+	return buildDataUrl(targets, BATCH_DATA_URL, baseURL, qm)
+}
+
+func buildDataUrl(targets []string, dataPath string, baseURL string, qm models.ArchiverQueryModel) string {
 	// Build the URL to query the archiver built from Grafana's configuration
 	// Set some constants
 	const TIME_FORMAT = "2006-01-02T15:04:05.000-07:00"
-	const RAW_DATA_URL = "data/getData.raw"
 
 	// Unpack the configured URL for the datasource and use that as the base for assembling the query URL
 	u, err := url.Parse(baseURL)
@@ -108,24 +233,25 @@ func buildQueryUrl(target string, baseURL string, qm models.ArchiverQueryModel)
 	// log.DefaultLogger.Debug("pluginctx","pluginctx", pluginctx)
 	// log.DefaultLogger.Debug("query","query", query)
 
-	var targetPv string
-	if len(opQuery) > 0 {
-		var opBuilder strings.Builder
-		opBuilder.WriteString(opQuery)
-		opBuilder.WriteString("(")
-		opBuilder.WriteString(target)
-		opBuilder.WriteString(")")
-		targetPv = opBuilder.String()
-
-	} else {
-		targetPv = target
+	targetPvs := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if len(opQuery) > 0 {
+			var opBuilder strings.Builder
+			opBuilder.WriteString(opQuery)
+			opBuilder.WriteString("(")
+			opBuilder.WriteString(target)
+			opBuilder.WriteString(")")
+			targetPvs = append(targetPvs, opBuilder.String())
+		} else {
+			targetPvs = append(targetPvs, target)
+		}
 	}
 
 	// amend the incomplete path
 	var pathBuilder strings.Builder
 	pathBuilder.WriteString(u.Path)
 	pathBuilder.WriteString("/")
-	pathBuilder.WriteString(RAW_DATA_URL)
+	pathBuilder.WriteString(dataPath)
 	u.Path = pathBuilder.String()
 
 	// from should be same as to in last operator mode
@@ -138,7 +264,7 @@ func buildQueryUrl(target string, baseURL string, qm models.ArchiverQueryModel)
 
 	// assemble the query of the URL and attach it to u
 	query_vals := make(url.Values)
-	query_vals["pv"] = []string{targetPv}
+	query_vals["pv"] = targetPvs
 	query_vals["from"] = []string{from}
 	query_vals["to"] = []string{qm.TimeRange.To.Format(TIME_FORMAT)}
 	query_vals["donotchunk"] = []string{""}
@@ -171,8 +297,25 @@ func archiverSingleQuery(ctx context.Context, queryUrl string, httpClient *http.
 	// Error handling
 	defer httpResponse.Body.Close()
 
-	err := fmt.Errorf("required=200, received=%d: %w", httpResponse.StatusCode, errResponseStatusCode)
-	return nil, err
+	return nil, &responseStatusError{code: httpResponse.StatusCode}
+}
+
+// responseStatusError holds the status code of the response which is not 200
+type responseStatusError struct {
+	code int
+}
+
+func (e *responseStatusError) Error() string {
+	return fmt.Sprintf("required=200, received=%d: %s", e.code, errResponseStatusCode)
+}
+
+func (e *responseStatusError) Unwrap() error {
+	return errResponseStatusCode
+}
+
+// unsupported reports whether the status code means that the endpoint is not available in the appliance
+func (e *responseStatusError) unsupported() bool {
+	return e.code == http.StatusNotFound || e.code == http.StatusMethodNotAllowed || e.code == http.StatusNotImplemented
 }
 
 func buildRegexUrl(regex string, baseURL string, limit int) string {
@@ -219,6 +362,10 @@ func archiverRegexQuery(ctx context.Context, queryUrl string, httpClient *http.C
 	}
 	defer httpResponse.Body.Close()
 
+	if httpResponse.StatusCode != http.StatusOK {
+		return jsonAsBytes, &responseStatusError{code: httpResponse.StatusCode}
+	}
+
 	// Convert get request response to variable and close the file
 	jsonAsBytes, ioErr := io.ReadAll(httpResponse.Body)
 	if ioErr != nil {
@@ -239,3 +386,73 @@ func archiverRegexQueryParser(jsonAsBytes []byte) ([]string, error) {
 
 	return pvList, nil
 }
+
+func (client AAclient) FetchVersion(ctx context.Context) (string, error) {
+	var version string
+	_, err := client.endpoints.do(ctx, func(baseURL string) error {
+		var err error
+		version, err = client.fetchVersion(ctx, baseURL)
+		return err
+	})
+
+	return version, err
+}
+
+func (client AAclient) fetchVersion(ctx context.Context, baseURL string) (string, error) {
+	versionUrl := buildVersionUrl(baseURL)
+
+	versionResponse, err := archiverSingleQuery(ctx, versionUrl, client.httpClient)
+	if err != nil {
+		return "", err
+	}
+	defer versionResponse.Close()
+
+	versionAsBytes, err := io.ReadAll(versionResponse)
+	if err != nil {
+		return "", err
+	}
+
+	return archiverVersionParser(versionAsBytes), nil
+}
+
+func buildVersionUrl(baseURL string) string {
+	// Construct the request URL for the version of the appliance and return it as a string
+	const VERSION_URL = "bpl/getVersion"
+
+	// Unpack the configured URL for the datasource and use that as the base for assembling the query URL
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		log.DefaultLogger.Warn("err", "err", err)
+	}
+
+	// amend the incomplete path
+	var pathBuilder strings.Builder
+	pathBuilder.WriteString(u.Path)
+	pathBuilder.WriteString("/")
+	pathBuilder.WriteString(VERSION_URL)
+	u.Path = pathBuilder.String()
+
+	return u.String()
+}
+
+func archiverVersionParser(response []byte) string {
+	// getVersion returns either a JSON object such as {"retrieval_version": "..."} or plain text depending on the appliance version
+	var versions map[string]string
+	if err := json.Unmarshal(response, &versions); err != nil {
+		return strings.TrimSpace(string(response))
+	}
+
+	keys := make([]string, 0, len(versions))
+	for k := range versions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if strings.Contains(strings.ToLower(k), "version") {
+			return versions[k]
+		}
+	}
+
+	return strings.TrimSpace(string(response))
+}
@@ -2,6 +2,7 @@ package archiverappliance
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -10,8 +11,10 @@ import (
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pb"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestBuildQueryUrl(t *testing.T) {
@@ -261,7 +264,7 @@ func TestLiveOnly(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			ctx := context.Background()
 			httpOptions := httpclient.Options{Timeouts: &httpclient.TimeoutOptions{Timeout: 5 * time.Second}}
-			client, _ := NewAAClient(ctx, "url", httpOptions)
+			client, _ := NewAAClient(ctx, "url", httpOptions, RetryOptions{})
 			result, _ := client.ExecuteSingleQuery(ctx, testCase.target, testCase.qm)
 			pvname := "PV:NAME"
 
@@ -280,3 +283,75 @@ func TestLiveOnly(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildBatchQueryUrl(t *testing.T) {
+	TIME_FORMAT := "2006-01-02T15:04:05.000-07:00"
+	qm := models.ArchiverQueryModel{
+		Operator: "mean",
+		TimeRange: backend.TimeRange{
+			From: testhelper.MultiReturnHelperParse(time.Parse(TIME_FORMAT, "2021-01-27T14:25:41.678-08:00")),
+			To:   testhelper.MultiReturnHelperParse(time.Parse(TIME_FORMAT, "2021-01-27T14:30:41.678-08:00")),
+		},
+		Interval: 10,
+	}
+
+	result := buildBatchQueryUrl([]string{"PV:A", "PV:B"}, "http://localhost:3396/retrieval", qm)
+	output := "http://localhost:3396/retrieval/data/getDataForPVs.raw?donotchunk=&from=2021-01-27T14%3A25%3A41.678-08%3A00&pv=mean_10%28PV%3AA%29&pv=mean_10%28PV%3AB%29&to=2021-01-27T14%3A30%3A41.678-08%3A00"
+	if result != output {
+		t.Errorf("got %v, want %v", result, output)
+	}
+}
+
+func TestExecuteBatchQuery(t *testing.T) {
+	infoA := &pb.PayloadInfo{Type: pb.PayloadType_SCALAR_DOUBLE.Enum(), Pvname: proto.String("PV:A"), Year: proto.Int32(2024)}
+	infoB := &pb.PayloadInfo{Type: pb.PayloadType_SCALAR_DOUBLE.Enum(), Pvname: proto.String("PV:B"), Year: proto.Int32(2024)}
+	sample := &pb.ScalarDouble{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: proto.Float64(1)}
+	response := buildPBResponse(buildPBChunk(t, infoA, sample), buildPBChunk(t, infoB, sample))
+
+	var requests int
+	mockServer := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.URL.Path != "/retrieval/data/getDataForPVs.raw" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if pvs := r.URL.Query()["pv"]; len(pvs) != 2 {
+				t.Errorf("Unexpected PVs: %v", pvs)
+			}
+			w.Write(response)
+		},
+	))
+	defer mockServer.Close()
+
+	ctx := context.Background()
+	qm := models.ArchiverQueryModel{Operator: "raw", FieldName: string(models.FIELD_NAME_VAL), MaxDataPoints: 100}
+	httpOptions := httpclient.Options{Timeouts: &httpclient.TimeoutOptions{Timeout: 5 * time.Second}}
+
+	t.Run("supported", func(t *testing.T) {
+		client, _ := NewAAClient(ctx, mockServer.URL+"/retrieval", httpOptions, RetryOptions{})
+		result, err := client.ExecuteBatchQuery(ctx, []string{"PV:A", "PV:B"}, qm)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(result) != 2 || result["PV:A"].PVname != "PV:A" || result["PV:B"].PVname != "PV:B" {
+			t.Errorf("Unexpected result: %v", result)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		client, _ := NewAAClient(ctx, mockServer.URL+"/old", httpOptions, RetryOptions{})
+		requests = 0
+
+		for range 2 {
+			if _, err := client.ExecuteBatchQuery(ctx, []string{"PV:A", "PV:B"}, qm); !errors.Is(err, errBatchUnsupported) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}
+
+		// The endpoint is not requested again once it is rejected
+		if requests != 1 {
+			t.Errorf("got %d requests, want 1", requests)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package archiverappliance
+
+import (
+	"context"
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+func annotationQuery(ctx context.Context, qm models.ArchiverQueryModel, client Client, config models.DatasourceSettings) backend.DataResponse {
+	// Every transition is required. Don't bin the data.
+	qm.Operator = "raw"
+	qm.Interval = 0
+
+	field, alarmOnly := annotationFieldName(models.FieldName(qm.FieldName))
+	qm.FieldName = string(field)
+
+	// The PVs are retrieved in the same way as the data queries so that a regex over many PVs doesn't hang the dashboard
+	responseData, timeoutNotice, responseErr := fetchTargetData(ctx, qm, client, config)
+
+	responseData, err := applyAlias(responseData, qm)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+
+	sort.Slice(responseData, func(i, j int) bool { return responseData[i].Name < responseData[j].Name })
+
+	response := backend.DataResponse{}
+	for _, d := range responseData {
+		frame, err := d.ToAnnotationFrame(qm.TimeRange.To, alarmOnly)
+		if err != nil {
+			if responseErr == nil {
+				responseErr = err
+			}
+			continue
+		}
+		response.Frames = append(response.Frames, frame)
+	}
+
+	response.Frames = appendNotice(response.Frames, timeoutNotice)
+	response.Error = responseErr
+
+	return response
+}
+
+// annotationFieldName returns the field to fetch for the annotations and
+// whether the annotations cover the alarm states only.
+func annotationFieldName(field models.FieldName) (models.FieldName, bool) {
+	switch field {
+	// Enums have the text of the states
+	case models.FIELD_NAME_SEVR, models.FIELD_NAME_SEVR_AS_ENUM:
+		return models.FIELD_NAME_SEVR_AS_ENUM, true
+	case models.FIELD_NAME_STAT, models.FIELD_NAME_STAT_AS_ENUM:
+		return models.FIELD_NAME_STAT_AS_ENUM, true
+	case models.FIELD_NAME_VAL_WITH_ALARM:
+		return field, true
+	}
+
+	return field, false
+}
@@ -0,0 +1,100 @@
+package archiverappliance
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
+)
+
+func TestAnnotationQuery(t *testing.T) {
+	req := backend.DataQuery{
+		JSON: json.RawMessage(`{
+			"target": "alarm",
+			"alias": "Alarm",
+			"functions": [
+				{"params": ["SEVR"], "def": {"category": "Options", "name": "fieldName", "params": [{"name": "field", "type": "string"}]}}
+			]
+		}`),
+		QueryType: models.QUERY_TYPE_ANNOTATION,
+		TimeRange: backend.TimeRange{
+			From: testhelper.TimeHelper(0),
+			To:   testhelper.TimeHelper(10),
+		},
+	}
+
+	result := Query(context.Background(), req, fakeClient{}, models.DatasourceSettings{})
+	if result.Error != nil {
+		t.Fatalf("Unexpected error: %v", result.Error)
+	}
+	if len(result.Frames) != 1 {
+		t.Fatalf("Number of frames differs - Wanted: 1 Got: %v", len(result.Frames))
+	}
+
+	frame := result.Frames[0]
+	if frame.Name != "Alarm" {
+		t.Errorf("Frame name differs - Wanted: Alarm Got: %v", frame.Name)
+	}
+
+	// NO_ALARM state is not an annotation
+	var out []string
+	for idx := 0; idx < frame.Rows(); idx++ {
+		out = append(out, frame.Fields[2].At(idx).(string))
+	}
+	if diff := cmp.Diff([]string{"Alarm: MAJOR", "Alarm: MINOR"}, out); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAnnotationQueryTimeout(t *testing.T) {
+	f := slowClient{canceled: make(chan string, 1)}
+	qm := models.ArchiverQueryModel{
+		Target:    "(alarm|slow1)",
+		FieldName: string(models.FIELD_NAME_SEVR),
+		TimeRange: backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(10)},
+		Timeout:   1,
+	}
+
+	res := annotationQuery(context.Background(), qm, f, models.DatasourceSettings{})
+	if res.Error != nil {
+		t.Fatalf("Unexpected error: %v", res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(res.Frames))
+	}
+
+	wantNotices := []data.Notice{{
+		Severity: data.NoticeSeverityWarning,
+		Text:     "Data is incomplete. Query timed out after 1 seconds for PVs: slow1",
+	}}
+	if diff := cmp.Diff(wantNotices, res.Frames[0].Meta.Notices); diff != "" {
+		t.Errorf("Notices mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAnnotationFieldName(t *testing.T) {
+	var tests = []struct {
+		input     models.FieldName
+		output    models.FieldName
+		alarmOnly bool
+	}{
+		{input: models.FIELD_NAME_VAL, output: models.FIELD_NAME_VAL, alarmOnly: false},
+		{input: models.FIELD_NAME_SEVR, output: models.FIELD_NAME_SEVR_AS_ENUM, alarmOnly: true},
+		{input: models.FIELD_NAME_STAT_AS_ENUM, output: models.FIELD_NAME_STAT_AS_ENUM, alarmOnly: true},
+		{input: models.FIELD_NAME_VAL_WITH_ALARM, output: models.FIELD_NAME_VAL_WITH_ALARM, alarmOnly: true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(string(testCase.input), func(t *testing.T) {
+			field, alarmOnly := annotationFieldName(testCase.input)
+			if field != testCase.output || alarmOnly != testCase.alarmOnly {
+				t.Errorf("got %v, %v; want %v, %v", field, alarmOnly, testCase.output, testCase.alarmOnly)
+			}
+		})
+	}
+}
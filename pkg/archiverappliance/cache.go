@@ -0,0 +1,378 @@
+package archiverappliance
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+const (
+	CACHE_DEFAULT_MAX_SIZE = 1000000
+	// Samples newer than this are not cached because the appliance may not have written them yet
+	CACHE_SETTLE_DURATION = time.Minute
+)
+
+const (
+	cacheResultHit     = "hit"
+	cacheResultPartial = "partial"
+	cacheResultMiss    = "miss"
+)
+
+var cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "archiverappliance",
+	Name:      "cache_requests_total",
+	Help:      "Number of the queries handled by the response cache by result (hit, partial or miss).",
+}, []string{"datasource", "result"})
+
+// CacheStats holds the counters of the response cache.
+// A partial hit is a query whose historical samples are served from the cache and only the new tail is fetched.
+type CacheStats struct {
+	Hits        int64 `json:"hits"`
+	PartialHits int64 `json:"partialHits"`
+	Misses      int64 `json:"misses"`
+	Entries     int   `json:"entries"`
+	Size        int   `json:"size"`
+}
+
+type cacheKey struct {
+	pvname          string
+	operator        string
+	interval        int
+	disableAutoRaw  bool
+	fieldName       string
+	hideInvalid     bool
+	alarmThresholds bool
+	processing      models.ProcessingOption
+}
+
+type cacheEntry struct {
+	key cacheKey
+	// data holds the samples in [from, to). The samples after to are not settled yet.
+	data     models.SingleData
+	from     time.Time
+	to       time.Time
+	storedAt time.Time
+	size     int
+}
+
+// CachedClient caches the responses of the wrapped client in memory.
+// The cache is bounded by the total size of the cached data and the entries expire after the TTL.
+// The size is the number of the elements, so a waveform sample counts its elements. See valuesSize.
+type CachedClient struct {
+	Client
+	uid     string
+	ttl     time.Duration
+	maxSize int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	size    int
+
+	hits        atomic.Int64
+	partialHits atomic.Int64
+	misses      atomic.Int64
+}
+
+func NewCachedClient(client Client, uid string, ttl time.Duration, maxSize int) *CachedClient {
+	if maxSize <= 0 {
+		maxSize = CACHE_DEFAULT_MAX_SIZE
+	}
+
+	return &CachedClient{
+		Client:  client,
+		uid:     uid,
+		ttl:     ttl,
+		maxSize: maxSize,
+		now:     time.Now,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *CachedClient) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:        c.hits.Load(),
+		PartialHits: c.partialHits.Load(),
+		Misses:      c.misses.Load(),
+		Entries:     c.lru.Len(),
+		Size:        c.size,
+	}
+}
+
+func (c *CachedClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	if !isCacheable(qm) {
+		return c.Client.ExecuteSingleQuery(ctx, target, qm)
+	}
+
+	key := newCacheKey(target, qm)
+	now := c.now()
+	settled := alignTime(now.Add(-CACHE_SETTLE_DURATION), qm.BinInterval())
+
+	from, to := qm.TimeRange.From, qm.TimeRange.To
+
+	if entry, ok := c.get(key, now); ok && !from.Before(entry.from) {
+		// All samples are in the cache
+		if !to.After(entry.to) {
+			c.count(cacheResultHit)
+			return sliceSingleData(entry.data, from, to), nil
+		}
+
+		// Fetch only the samples after the cached ones
+		tailQm := qm
+		tailQm.TimeRange.From = entry.to
+		tail, err := c.Client.ExecuteSingleQuery(ctx, target, tailQm)
+		if err != nil && !errors.Is(err, errEmptyResponse) {
+			return tail, err
+		}
+
+		// The type of the values can change if the PV is changed in the appliance. Fetch all samples in that case.
+		merged, err := mergeSingleData(entry.data, tail, entry.to)
+		if err == nil {
+			c.count(cacheResultPartial)
+			c.put(key, merged, entry.from, minTime(to, settled), now)
+			return sliceSingleData(merged, from, to), nil
+		}
+	}
+
+	c.count(cacheResultMiss)
+	sD, err := c.Client.ExecuteSingleQuery(ctx, target, qm)
+	if err != nil {
+		return sD, err
+	}
+
+	c.put(key, sD, from, minTime(to, settled), now)
+
+	return sD, nil
+}
+
+// ExecuteBatchQuery serves the PVs in the cache and retrieves the others in one request.
+// The PVs whose cached samples don't cover the time range are retrieved entirely.
+func (c *CachedClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	if !isCacheable(qm) {
+		return c.Client.ExecuteBatchQuery(ctx, targets, qm)
+	}
+
+	now := c.now()
+	settled := alignTime(now.Add(-CACHE_SETTLE_DURATION), qm.BinInterval())
+	from, to := qm.TimeRange.From, qm.TimeRange.To
+
+	result := make(map[string]models.SingleData, len(targets))
+	var misses []string
+	for _, target := range targets {
+		entry, ok := c.get(newCacheKey(target, qm), now)
+		if ok && !from.Before(entry.from) && !to.After(entry.to) {
+			result[target] = sliceSingleData(entry.data, from, to)
+			continue
+		}
+		misses = append(misses, target)
+	}
+
+	if len(misses) > 0 {
+		fetched, err := c.Client.ExecuteBatchQuery(ctx, misses, qm)
+		if err != nil {
+			return nil, err
+		}
+
+		for target, sD := range fetched {
+			c.count(cacheResultMiss)
+			c.put(newCacheKey(target, qm), sD, from, minTime(to, settled), now)
+			result[target] = sD
+		}
+	}
+
+	// The hits are counted after the request succeeded because the caller retries the PVs one by one on error
+	for range len(targets) - len(misses) {
+		c.count(cacheResultHit)
+	}
+
+	return result, nil
+}
+
+func (c *CachedClient) count(result string) {
+	switch result {
+	case cacheResultHit:
+		c.hits.Add(1)
+	case cacheResultPartial:
+		c.partialHits.Add(1)
+	case cacheResultMiss:
+		c.misses.Add(1)
+	}
+	cacheRequests.WithLabelValues(c.uid, result).Inc()
+}
+
+func (c *CachedClient) get(key cacheKey, now time.Time) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if now.Sub(entry.storedAt) > c.ttl {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return entry, true
+}
+
+// put stores the samples in [from, to). The samples are copied so that the query can modify the returned data.
+func (c *CachedClient) put(key cacheKey, sD models.SingleData, from time.Time, to time.Time, now time.Time) {
+	if !to.After(from) {
+		return
+	}
+
+	v, ok := sD.Values.(models.TimeSlicer)
+	if !ok {
+		return
+	}
+
+	settled := v.Slice(0, countBefore(v, to)).(models.TimeSlicer)
+	entry := &cacheEntry{
+		key:      key,
+		data:     models.SingleData{Name: sD.Name, PVname: sD.PVname, Meta: sD.Meta, Values: settled, Endpoint: sD.Endpoint},
+		from:     from,
+		to:       to,
+		storedAt: now,
+		size:     valuesSize(settled),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	if entry.size > c.maxSize {
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *CachedClient) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+func isCacheable(qm models.ArchiverQueryModel) bool {
+	// last operator and liveOnly don't retrieve the time range.
+	// Sample metadata is aligned with the samples by the index and can't be cut.
+	// The bins of the optimized operators depend on the time range.
+	return qm.Operator != "last" && !qm.LiveOnly && !qm.SampleMetadata && !models.IsPointCountOperator(qm.Operator)
+}
+
+func newCacheKey(target string, qm models.ArchiverQueryModel) cacheKey {
+	return cacheKey{
+		pvname:          target,
+		operator:        qm.Operator,
+		interval:        qm.Interval,
+		disableAutoRaw:  qm.DisableAutoRaw,
+		fieldName:       qm.FieldName,
+		hideInvalid:     qm.HideInvalid,
+		alarmThresholds: qm.AlarmThresholds,
+		processing:      qm.Processing,
+	}
+}
+
+// valuesSize returns the number of the elements in the cached values, which approximates their memory usage.
+// A sample of a scalar counts 1 and a sample of an array counts its elements, at least 1 for an empty array.
+func valuesSize(v models.TimeSlicer) int {
+	arrays, ok := v.(*models.Arrays)
+	if !ok {
+		return v.Len()
+	}
+
+	size := 0
+	for _, row := range arrays.Values {
+		size += max(len(row), 1)
+	}
+	return size
+}
+
+// alignTime aligns the time to the bin boundary so that the bins are not split between the cached samples and the tail
+func alignTime(t time.Time, interval int) time.Time {
+	if interval <= 0 {
+		return t
+	}
+
+	return t.Truncate(time.Duration(interval) * time.Second)
+}
+
+func countBefore(v models.TimeSlicer, t time.Time) int {
+	return sort.Search(v.Len(), func(i int) bool { return !v.TimeAt(i).Before(t) })
+}
+
+func sliceSingleData(sD models.SingleData, from time.Time, to time.Time) models.SingleData {
+	v := sD.Values.(models.TimeSlicer)
+	return models.SingleData{
+		Name:     sD.Name,
+		PVname:   sD.PVname,
+		Meta:     sD.Meta,
+		Values:   models.SliceByTime(v, from, to),
+		Endpoint: sD.Endpoint,
+	}
+}
+
+func mergeSingleData(head models.SingleData, tail models.SingleData, at time.Time) (models.SingleData, error) {
+	h := head.Values.(models.TimeSlicer)
+
+	// The tail is empty
+	if tail.Values == nil {
+		return head, nil
+	}
+
+	t, ok := tail.Values.(models.TimeSlicer)
+	if !ok {
+		return head, errTypeMismatch
+	}
+
+	v, err := models.MergeByTime(h, t, at)
+	if err != nil {
+		return head, err
+	}
+
+	merged := head
+	merged.Values = v
+	// The headers of the newer response take precedence
+	if len(tail.Meta.Fields) > 0 {
+		merged.Meta = tail.Meta
+	}
+	if tail.Endpoint != "" {
+		merged.Endpoint = tail.Endpoint
+	}
+
+	return merged, nil
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,213 @@
+package archiverappliance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+// minuteClient returns a sample at every minute in the time range and records the requested ranges
+type minuteClient struct {
+	fakeClient
+	ranges  []backend.TimeRange
+	batches [][]string
+}
+
+func (f *minuteClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	f.ranges = append(f.ranges, qm.TimeRange)
+
+	v := models.NewSclars(0)
+	for t := qm.TimeRange.From.Truncate(time.Minute); !t.After(qm.TimeRange.To); t = t.Add(time.Minute) {
+		if t.Before(qm.TimeRange.From) {
+			continue
+		}
+		v.AppendConcrete(float64(t.Minute()), t)
+	}
+
+	return models.SingleData{Name: target, PVname: target, Values: v}, nil
+}
+
+func (f *minuteClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	f.batches = append(f.batches, targets)
+
+	result := make(map[string]models.SingleData)
+	for _, target := range targets {
+		result[target], _ = f.ExecuteSingleQuery(ctx, target, qm)
+	}
+	return result, nil
+}
+
+func TestCachedClient(t *testing.T) {
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now := base.Add(30 * time.Minute)
+
+	inner := &minuteClient{}
+	c := NewCachedClient(inner, "uid", time.Hour, 0)
+	c.now = func() time.Time { return now }
+
+	query := func(from, to time.Time) []float64 {
+		qm := models.ArchiverQueryModel{Operator: "raw", FieldName: "VAL", TimeRange: backend.TimeRange{From: from, To: to}}
+		sD, err := c.ExecuteSingleQuery(context.Background(), "PV", qm)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var vals []float64
+		for _, v := range sD.Values.(*models.Scalars).Values {
+			vals = append(vals, *v)
+		}
+		return vals
+	}
+
+	// Miss: the samples until now - settle duration (29 min) are cached
+	if diff := cmp.Diff([]float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, query(base, base.Add(10*time.Minute))); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+
+	// Hit: the range is in the cache
+	if diff := cmp.Diff([]float64{4, 5, 6}, query(base.Add(5*time.Minute), base.Add(6*time.Minute))); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+
+	// Partial hit: only the tail is fetched
+	if diff := cmp.Diff([]float64{8, 9, 10, 11, 12}, query(base.Add(9*time.Minute), base.Add(12*time.Minute))); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+
+	wantRanges := []backend.TimeRange{
+		{From: base, To: base.Add(10 * time.Minute)},
+		{From: base.Add(10 * time.Minute), To: base.Add(12 * time.Minute)},
+	}
+	if diff := cmp.Diff(wantRanges, inner.ranges); diff != "" {
+		t.Errorf("Requested ranges mismatch (-want +got):\n%s", diff)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.PartialHits != 1 || stats.Misses != 1 {
+		t.Errorf("Unexpected counters: %+v", stats)
+	}
+
+	// Expired entries are fetched again
+	now = now.Add(2 * time.Hour)
+	query(base, base.Add(time.Minute))
+	if c.Stats().Misses != 2 {
+		t.Errorf("Expired entry should be a miss: %+v", c.Stats())
+	}
+}
+
+func TestCachedClientMaxSize(t *testing.T) {
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewCachedClient(&minuteClient{}, "uid", time.Hour, 15)
+	c.now = func() time.Time { return base.Add(time.Hour) }
+
+	for _, pv := range []string{"PV1", "PV2"} {
+		qm := models.ArchiverQueryModel{Operator: "raw", FieldName: "VAL", TimeRange: backend.TimeRange{From: base, To: base.Add(10 * time.Minute)}}
+		if _, err := c.ExecuteSingleQuery(context.Background(), pv, qm); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// The least recently used entry is evicted. The sample at the end of the range is not cached.
+	stats := c.Stats()
+	if stats.Entries != 1 || stats.Size != 10 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestValuesSize(t *testing.T) {
+	times := []time.Time{time.Unix(0, 0), time.Unix(1, 0), time.Unix(2, 0)}
+
+	var tests = []struct {
+		name   string
+		values models.TimeSlicer
+		output int
+	}{
+		{name: "scalars", values: &models.Scalars{Times: times, Values: make([]*float64, 3)}, output: 3},
+		{name: "arrays", values: &models.Arrays{Times: times, Values: [][]float64{{1, 2, 3}, {}, make([]float64, 100000)}}, output: 100004},
+		{name: "strings", values: &models.Strings{Times: times[:2], Values: []string{"a", "b"}}, output: 2},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if size := valuesSize(testCase.values); size != testCase.output {
+				t.Errorf("got %v, want %v", size, testCase.output)
+			}
+		})
+	}
+}
+
+func TestCachedClientModifiedResponse(t *testing.T) {
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewCachedClient(&minuteClient{}, "uid", time.Hour, 0)
+	c.now = func() time.Time { return base.Add(time.Hour) }
+
+	qm := models.ArchiverQueryModel{Operator: "raw", FieldName: "VAL", TimeRange: backend.TimeRange{From: base, To: base.Add(2 * time.Minute)}}
+	sD, _ := c.ExecuteSingleQuery(context.Background(), "PV", qm)
+
+	// Functions modify the response in place
+	*sD.Values.(*models.Scalars).Values[0] = 100
+
+	sD, _ = c.ExecuteSingleQuery(context.Background(), "PV", qm)
+	if v := *sD.Values.(*models.Scalars).Values[0]; v != 0 {
+		t.Errorf("Cached data should not be modified: %v", v)
+	}
+}
+
+func TestIsCacheable(t *testing.T) {
+	var tests = []struct {
+		name   string
+		qm     models.ArchiverQueryModel
+		output bool
+	}{
+		{name: "mean", qm: models.ArchiverQueryModel{Operator: "mean"}, output: true},
+		{name: "last", qm: models.ArchiverQueryModel{Operator: "last"}, output: false},
+		{name: "liveOnly", qm: models.ArchiverQueryModel{Operator: "raw", LiveOnly: true}, output: false},
+		{name: "sampleMetadata", qm: models.ArchiverQueryModel{Operator: "raw", SampleMetadata: true}, output: false},
+		{name: "optimized", qm: models.ArchiverQueryModel{Operator: "optimized", MaxDataPoints: 1000}, output: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := isCacheable(testCase.qm); result != testCase.output {
+				t.Errorf("got %v, want %v", result, testCase.output)
+			}
+		})
+	}
+}
+
+func TestCachedClientBatch(t *testing.T) {
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	inner := &minuteClient{}
+	c := NewCachedClient(inner, "uid", time.Hour, 0)
+	c.now = func() time.Time { return base.Add(time.Hour) }
+
+	qm := models.ArchiverQueryModel{Operator: "raw", FieldName: "VAL", TimeRange: backend.TimeRange{From: base, To: base.Add(2 * time.Minute)}}
+	if _, err := c.ExecuteSingleQuery(context.Background(), "PV1", qm); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := c.ExecuteBatchQuery(context.Background(), []string{"PV1", "PV2"}, qm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Errorf("Unexpected result: %v", result)
+	}
+
+	// Only the PV which is not in the cache is requested
+	if diff := cmp.Diff([][]string{{"PV2"}}, inner.batches); diff != "" {
+		t.Errorf("Requested PVs mismatch (-want +got):\n%s", diff)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Unexpected counters: %+v", stats)
+	}
+}
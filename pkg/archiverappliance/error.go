@@ -8,4 +8,6 @@ var (
 	errIllegalPayloadType    = errors.New("response from Archiver Appliance might be illegal payload type")
 	errIllegalFieldName      = errors.New("unavailable field name")
 	errFailedToParsePBFormat = errors.New("failed to parse the PB format response")
+	errTypeMismatch          = errors.New("values of different types can't be merged")
+	errBatchUnsupported      = errors.New("bulk retrieval is not supported by the appliance")
 )
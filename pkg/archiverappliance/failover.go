@@ -0,0 +1,112 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// Interval of the health probes of the preferred endpoints while a replica is in use
+const DEFAULT_FAILOVER_PROBE_INTERVAL = 30 * time.Second
+
+// endpoints holds the equivalent retrieval URLs in the order of preference.
+// The requests are sent to the active URL and moved to the next one on connection failure or 5xx.
+type endpoints struct {
+	urls   []string
+	active atomic.Int32
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newEndpoints(urls []string) *endpoints {
+	return &endpoints{urls: urls, stop: make(chan struct{})}
+}
+
+func (e *endpoints) current() string {
+	return e.urls[e.active.Load()]
+}
+
+// do sends the request to the active URL and fails over to the next URLs in order.
+// It returns the URL which served the request.
+func (e *endpoints) do(ctx context.Context, request func(baseURL string) error) (string, error) {
+	start := int(e.active.Load())
+
+	var firstErr error
+	for n := range len(e.urls) {
+		idx := (start + n) % len(e.urls)
+		err := request(e.urls[idx])
+		if err == nil {
+			if idx != start && e.active.CompareAndSwap(int32(start), int32(idx)) {
+				log.DefaultLogger.Warn("Failed over to the other retrieval URL", "from", e.urls[start], "to", e.urls[idx])
+			}
+			return e.urls[idx], nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil || !isFailoverError(err) {
+			return e.urls[idx], err
+		}
+	}
+
+	return e.urls[start], firstErr
+}
+
+// isFailoverError reports whether the endpoint looks unavailable
+func isFailoverError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *responseStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= http.StatusInternalServerError
+	}
+
+	// Errors with the response, e.g. the parse errors, are not the problem of the endpoint
+	if errors.Is(err, errFailedToParsePBFormat) || errors.Is(err, errEmptyResponse) || errors.Is(err, errIllegalPayloadType) || errors.Is(err, errIllegalFieldName) {
+		return false
+	}
+
+	// Connection errors
+	return true
+}
+
+// probe fails back to the most preferred URL which answers while a replica is in use
+func (e *endpoints) probe(interval time.Duration, healthy func(ctx context.Context, baseURL string) bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+
+		active := int(e.active.Load())
+		for idx := range active {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			ok := healthy(ctx, e.urls[idx])
+			cancel()
+
+			if ok {
+				if e.active.CompareAndSwap(int32(active), int32(idx)) {
+					log.DefaultLogger.Info("Failed back to the recovered retrieval URL", "from", e.urls[active], "to", e.urls[idx])
+				}
+				break
+			}
+		}
+	}
+}
+
+func (e *endpoints) close() {
+	e.stopOnce.Do(func() { close(e.stop) })
+}
@@ -0,0 +1,182 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pb"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// replicaServer answers with the status while it is not 200 and serves the data and the version otherwise
+func replicaServer(t *testing.T, name string, status int) (*httptest.Server, *atomic.Int32) {
+	info := &pb.PayloadInfo{Type: pb.PayloadType_SCALAR_DOUBLE.Enum(), Pvname: proto.String("PV:A"), Year: proto.Int32(2024)}
+	sample := &pb.ScalarDouble{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: proto.Float64(1)}
+	response := buildPBResponse(buildPBChunk(t, info, sample))
+
+	var s atomic.Int32
+	s.Store(int32(status))
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if code := int(s.Load()); code != http.StatusOK {
+				w.WriteHeader(code)
+				return
+			}
+
+			switch r.URL.Path {
+			case "/retrieval/data/getData.raw":
+				w.Write(response)
+			case "/retrieval/bpl/getVersion":
+				fmt.Fprintf(w, `{"retrieval_version": %q}`, name)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+	))
+	t.Cleanup(server.Close)
+
+	return server, &s
+}
+
+func newTestFailoverClient(t *testing.T, probeInterval time.Duration, urls ...string) *AAclient {
+	httpOptions := httpclient.Options{Timeouts: &httpclient.TimeoutOptions{Timeout: 5 * time.Second}}
+	client, err := NewFailoverAAClient(context.Background(), urls, httpOptions, RetryOptions{}, probeInterval)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	return client
+}
+
+func TestFailover(t *testing.T) {
+	var tests = []struct {
+		name     string
+		status   int
+		closed   bool
+		endpoint string
+		err      bool
+	}{
+		{name: "primary is available", status: http.StatusOK, endpoint: "primary"},
+		{name: "5xx fails over", status: http.StatusServiceUnavailable, endpoint: "replica"},
+		{name: "500 fails over", status: http.StatusInternalServerError, endpoint: "replica"},
+		{name: "connection failure fails over", status: http.StatusOK, closed: true, endpoint: "replica"},
+		{name: "4xx doesn't fail over", status: http.StatusNotFound, err: true},
+	}
+
+	qm := models.ArchiverQueryModel{Operator: "raw", FieldName: string(models.FIELD_NAME_VAL), MaxDataPoints: 100}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			primary, _ := replicaServer(t, "primary", testCase.status)
+			replica, _ := replicaServer(t, "replica", http.StatusOK)
+			if testCase.closed {
+				primary.Close()
+			}
+
+			endpoints := map[string]string{"primary": primary.URL + "/retrieval", "replica": replica.URL + "/retrieval"}
+			client := newTestFailoverClient(t, time.Hour, endpoints["primary"], endpoints["replica"])
+
+			result, err := client.ExecuteSingleQuery(context.Background(), "PV:A", qm)
+			if testCase.err {
+				if err == nil {
+					t.Errorf("Error should be returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Endpoint != endpoints[testCase.endpoint] {
+				t.Errorf("got %v, want %v", result.Endpoint, endpoints[testCase.endpoint])
+			}
+
+			// The following requests are sent to the endpoint which served the query
+			version, err := client.FetchVersion(context.Background())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if version != testCase.endpoint {
+				t.Errorf("got %v, want %v", version, testCase.endpoint)
+			}
+		})
+	}
+}
+
+func TestFailoverAllUnavailable(t *testing.T) {
+	primary, _ := replicaServer(t, "primary", http.StatusServiceUnavailable)
+	replica, _ := replicaServer(t, "replica", http.StatusBadGateway)
+	client := newTestFailoverClient(t, time.Hour, primary.URL+"/retrieval", replica.URL+"/retrieval")
+
+	// The error of the active endpoint is returned
+	_, err := client.FetchVersion(context.Background())
+	var statusErr *responseStatusError
+	if !errors.As(err, &statusErr) || statusErr.code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if client.endpoints.current() != primary.URL+"/retrieval" {
+		t.Errorf("The active endpoint should not be changed: %v", client.endpoints.current())
+	}
+}
+
+func TestFailback(t *testing.T) {
+	primary, status := replicaServer(t, "primary", http.StatusServiceUnavailable)
+	replica, _ := replicaServer(t, "replica", http.StatusOK)
+	client := newTestFailoverClient(t, 10*time.Millisecond, primary.URL+"/retrieval", replica.URL+"/retrieval")
+
+	if version, _ := client.FetchVersion(context.Background()); version != "replica" {
+		t.Fatalf("got %v, want replica", version)
+	}
+
+	// The replica stays active while the primary is unavailable
+	time.Sleep(50 * time.Millisecond)
+	if client.endpoints.current() != replica.URL+"/retrieval" {
+		t.Fatalf("The replica should be active: %v", client.endpoints.current())
+	}
+
+	status.Store(http.StatusOK)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.endpoints.current() != primary.URL+"/retrieval" {
+		if time.Now().After(deadline) {
+			t.Fatalf("The client didn't fail back to the primary")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if version, _ := client.FetchVersion(context.Background()); version != "primary" {
+		t.Errorf("got %v, want primary", version)
+	}
+}
+
+func TestIsFailoverError(t *testing.T) {
+	var tests = []struct {
+		name   string
+		err    error
+		output bool
+	}{
+		{name: "503", err: &responseStatusError{code: http.StatusServiceUnavailable}, output: true},
+		{name: "500", err: fmt.Errorf("wrapped: %w", &responseStatusError{code: http.StatusInternalServerError}), output: true},
+		{name: "404", err: &responseStatusError{code: http.StatusNotFound}, output: false},
+		{name: "connection error", err: errors.New("connection refused"), output: true},
+		{name: "canceled", err: context.Canceled, output: false},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, output: false},
+		{name: "empty response", err: errEmptyResponse, output: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := isFailoverError(testCase.err); result != testCase.output {
+				t.Errorf("got %v, want %v", result, testCase.output)
+			}
+		})
+	}
+}
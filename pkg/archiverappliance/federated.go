@@ -0,0 +1,274 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+// FederatedMember is an appliance in the federation
+type FederatedMember struct {
+	URL    string
+	Client Client
+}
+
+const (
+	// The owners are looked up again after the TTL because the PVs can move to another appliance
+	FEDERATED_OWNER_TTL = time.Hour
+	// Maximum number of the remembered owners. The expired owners are dropped when it is reached.
+	FEDERATED_MAX_OWNERS = 100000
+)
+
+// FederatedClient spreads the queries over several appliances.
+// The PVs found by the regex search are owned by the appliance which returned them first,
+// and the data of a PV is retrieved from its owner.
+// If the owner fails to return the data, the other appliances are probed for a new owner.
+type FederatedClient struct {
+	members []FederatedMember
+	now     func() time.Time
+
+	mu     sync.RWMutex
+	owners map[string]federatedOwner
+}
+
+type federatedOwner struct {
+	idx      int
+	storedAt time.Time
+}
+
+func NewFederatedClient(members []FederatedMember) *FederatedClient {
+	return &FederatedClient{
+		members: members,
+		now:     time.Now,
+		owners:  make(map[string]federatedOwner),
+	}
+}
+
+type federatedResult[T any] struct {
+	value T
+	err   error
+}
+
+// fetchAll runs the fetch on all appliances concurrently and returns the results in the order of the members
+func fetchAll[T any](ctx context.Context, members []FederatedMember, fetch func(ctx context.Context, client Client) (T, error)) []federatedResult[T] {
+	results := make([]federatedResult[T], len(members))
+
+	var wg sync.WaitGroup
+	for idx, m := range members {
+		wg.Add(1)
+		go func(idx int, client Client) {
+			defer wg.Done()
+			v, err := fetch(ctx, client)
+			results[idx] = federatedResult[T]{value: v, err: err}
+		}(idx, m.Client)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (c *FederatedClient) FetchRegexTargetPVs(ctx context.Context, regex string, limit int) ([]string, error) {
+	results := fetchAll(ctx, c.members, func(ctx context.Context, client Client) ([]string, error) {
+		return client.FetchRegexTargetPVs(ctx, regex, limit)
+	})
+
+	var pvs []string
+	var firstErr error
+	succeeded := false
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	seen := make(map[string]bool)
+	for idx, r := range results {
+		if r.err != nil {
+			log.DefaultLogger.Warn("Failed to search PVs", "url", c.members[idx].URL, "Error", r.err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("url = %q: %w", c.members[idx].URL, r.err)
+			}
+			continue
+		}
+		succeeded = true
+
+		for _, pv := range r.value {
+			if seen[pv] {
+				continue
+			}
+			seen[pv] = true
+
+			// The appliance earlier in the list owns the PV archived in several appliances
+			if _, ok := c.lookupOwner(pv, now); !ok {
+				c.storeOwner(pv, idx, now)
+			}
+			pvs = append(pvs, pv)
+		}
+	}
+
+	// The search is successful if any appliance answered
+	if !succeeded {
+		return nil, firstErr
+	}
+
+	if limit > 0 && len(pvs) > limit {
+		pvs = pvs[:limit]
+	}
+
+	return pvs, nil
+}
+
+func (c *FederatedClient) owner(target string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lookupOwner(target, c.now())
+}
+
+func (c *FederatedClient) setOwner(target string, idx int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.storeOwner(target, idx, c.now())
+}
+
+func (c *FederatedClient) forgetOwner(target string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.owners, target)
+}
+
+// lookupOwner returns the owner which is not expired. c.mu must be held.
+func (c *FederatedClient) lookupOwner(target string, now time.Time) (int, bool) {
+	o, ok := c.owners[target]
+	if !ok || now.Sub(o.storedAt) > FEDERATED_OWNER_TTL {
+		return 0, false
+	}
+	return o.idx, true
+}
+
+// storeOwner remembers the owner. The expired owners are dropped if the map is full, and all owners are dropped
+// if it is still full. c.mu must be held for writing.
+func (c *FederatedClient) storeOwner(target string, idx int, now time.Time) {
+	if _, ok := c.owners[target]; !ok && len(c.owners) >= FEDERATED_MAX_OWNERS {
+		for pv, o := range c.owners {
+			if now.Sub(o.storedAt) > FEDERATED_OWNER_TTL {
+				delete(c.owners, pv)
+			}
+		}
+		if len(c.owners) >= FEDERATED_MAX_OWNERS {
+			clear(c.owners)
+		}
+	}
+
+	c.owners[target] = federatedOwner{idx: idx, storedAt: now}
+}
+
+func (c *FederatedClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	owner, ok := c.owner(target)
+	if !ok {
+		return c.probe(ctx, target, qm, -1, nil)
+	}
+
+	sD, err := c.members[owner].Client.ExecuteSingleQuery(ctx, target, qm)
+	if err == nil || ctx.Err() != nil {
+		return sD, err
+	}
+
+	// The PV may have moved to another appliance, or the owner was determined by a transient error
+	log.DefaultLogger.Debug("Owner failed to return the data. Probing the other appliances.", "pv", target, "url", c.members[owner].URL, "Error", err)
+	c.forgetOwner(target)
+	return c.probe(ctx, target, qm, owner, err)
+}
+
+// probe looks up the owner of the PV in order except for the skipped appliance.
+// The owner is the first appliance which returns the data.
+func (c *FederatedClient) probe(ctx context.Context, target string, qm models.ArchiverQueryModel, skip int, firstErr error) (models.SingleData, error) {
+	for idx, m := range c.members {
+		if idx == skip {
+			continue
+		}
+
+		sD, err := m.Client.ExecuteSingleQuery(ctx, target, qm)
+		if err == nil {
+			c.setOwner(target, idx)
+			return sD, nil
+		}
+
+		if ctx.Err() != nil {
+			return sD, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return models.SingleData{}, firstErr
+}
+
+// ExecuteBatchQuery retrieves the PVs from their owners. The PVs whose owner is unknown are not in the result
+// so that they are retrieved one by one.
+func (c *FederatedClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	groups := make([][]string, len(c.members))
+	for _, target := range targets {
+		if idx, ok := c.owner(target); ok {
+			groups[idx] = append(groups[idx], target)
+		}
+	}
+
+	results := make([]federatedResult[map[string]models.SingleData], len(c.members))
+	var wg sync.WaitGroup
+	for idx, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(idx int, group []string) {
+			defer wg.Done()
+			v, err := c.members[idx].Client.ExecuteBatchQuery(ctx, group, qm)
+			results[idx] = federatedResult[map[string]models.SingleData]{value: v, err: err}
+		}(idx, group)
+	}
+	wg.Wait()
+
+	result := make(map[string]models.SingleData, len(targets))
+	for idx, r := range results {
+		// The PVs of the failed request are retrieved one by one and the errors are reported for each PV
+		if r.err != nil {
+			if !errors.Is(r.err, errBatchUnsupported) {
+				log.DefaultLogger.Warn("Bulk retrieval has failed", "url", c.members[idx].URL, "Error", r.err)
+			}
+			continue
+		}
+
+		for target, sD := range r.value {
+			result[target] = sD
+		}
+	}
+
+	return result, nil
+}
+
+// FetchVersion returns the versions of all appliances. An error is returned if any appliance doesn't answer.
+func (c *FederatedClient) FetchVersion(ctx context.Context) (string, error) {
+	results := fetchAll(ctx, c.members, func(ctx context.Context, client Client) (string, error) {
+		return client.FetchVersion(ctx)
+	})
+
+	versions := make([]string, 0, len(results))
+	for idx, r := range results {
+		if r.err != nil {
+			return "", fmt.Errorf("url = %q: %w", c.members[idx].URL, r.err)
+		}
+		versions = append(versions, r.value)
+	}
+
+	return strings.Join(versions, ", "), nil
+}
@@ -0,0 +1,256 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+// memberClient archives the PVs and records the requested PVs
+type memberClient struct {
+	fakeClient
+	pvs     []string
+	err     error
+	version string
+
+	mu      sync.Mutex
+	queried []string
+	batches [][]string
+}
+
+func (f *memberClient) FetchRegexTargetPVs(ctx context.Context, regex string, limit int) ([]string, error) {
+	return f.pvs, f.err
+}
+
+func (f *memberClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	f.mu.Lock()
+	f.queried = append(f.queried, target)
+	f.mu.Unlock()
+
+	if !slices.Contains(f.pvs, target) {
+		return models.SingleData{}, errEmptyResponse
+	}
+	return models.SingleData{Name: target, PVname: target, Values: &models.Scalars{}}, nil
+}
+
+func (f *memberClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	f.mu.Lock()
+	f.batches = append(f.batches, targets)
+	f.mu.Unlock()
+
+	result := make(map[string]models.SingleData)
+	for _, target := range targets {
+		if slices.Contains(f.pvs, target) {
+			result[target] = models.SingleData{Name: target, PVname: target, Values: &models.Scalars{}}
+		}
+	}
+	return result, nil
+}
+
+func (f *memberClient) FetchVersion(ctx context.Context) (string, error) {
+	return f.version, f.err
+}
+
+func newTestFederation() (*FederatedClient, *memberClient, *memberClient) {
+	a := &memberClient{pvs: []string{"PV:A1", "PV:SHARED"}, version: "v1"}
+	b := &memberClient{pvs: []string{"PV:B1", "PV:B2", "PV:SHARED"}, version: "v2"}
+	c := NewFederatedClient([]FederatedMember{{URL: "http://a", Client: a}, {URL: "http://b", Client: b}})
+	return c, a, b
+}
+
+func TestFederatedRegexSearch(t *testing.T) {
+	c, a, b := newTestFederation()
+
+	pvs, err := c.FetchRegexTargetPVs(context.Background(), "PV:.*", 100)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"PV:A1", "PV:SHARED", "PV:B1", "PV:B2"}, pvs); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+
+	// The queries are routed to the owners
+	for _, pv := range []string{"PV:A1", "PV:SHARED", "PV:B1"} {
+		if _, err := c.ExecuteSingleQuery(context.Background(), pv, models.ArchiverQueryModel{}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if diff := cmp.Diff([]string{"PV:A1", "PV:SHARED"}, a.queried); diff != "" {
+		t.Errorf("Queried PVs of a mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"PV:B1"}, b.queried); diff != "" {
+		t.Errorf("Queried PVs of b mismatch (-want +got):\n%s", diff)
+	}
+
+	// The limit is applied to the PVs of all appliances
+	pvs, _ = c.FetchRegexTargetPVs(context.Background(), "PV:.*", 3)
+	if len(pvs) != 3 {
+		t.Errorf("got %d PVs, want 3", len(pvs))
+	}
+}
+
+func TestFederatedRegexSearchError(t *testing.T) {
+	c, a, b := newTestFederation()
+	a.err = errors.New("test error")
+
+	pvs, err := c.FetchRegexTargetPVs(context.Background(), "PV:.*", 100)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"PV:B1", "PV:B2", "PV:SHARED"}, pvs); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+
+	b.err = errors.New("test error")
+	if _, err := c.FetchRegexTargetPVs(context.Background(), "PV:.*", 100); err == nil {
+		t.Errorf("Error should be returned if no appliance answers")
+	}
+}
+
+func TestFederatedUnknownPV(t *testing.T) {
+	c, a, b := newTestFederation()
+
+	// The PV which was not searched is looked up in order
+	if _, err := c.ExecuteSingleQuery(context.Background(), "PV:B2", models.ArchiverQueryModel{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := c.ExecuteSingleQuery(context.Background(), "PV:B2", models.ArchiverQueryModel{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"PV:B2"}, a.queried); diff != "" {
+		t.Errorf("Queried PVs of a mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"PV:B2", "PV:B2"}, b.queried); diff != "" {
+		t.Errorf("Queried PVs of b mismatch (-want +got):\n%s", diff)
+	}
+
+	// The error of the first appliance is returned if no appliance has the PV
+	if _, err := c.ExecuteSingleQuery(context.Background(), "PV:NONE", models.ArchiverQueryModel{}); !errors.Is(err, errEmptyResponse) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestFederatedMovedPV(t *testing.T) {
+	c, a, b := newTestFederation()
+	c.FetchRegexTargetPVs(context.Background(), "PV:.*", 100)
+
+	// PV:A1 moves from a to b
+	a.pvs = []string{"PV:SHARED"}
+	b.pvs = append(b.pvs, "PV:A1")
+
+	for range 2 {
+		if _, err := c.ExecuteSingleQuery(context.Background(), "PV:A1", models.ArchiverQueryModel{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// The owner is probed once and the second query goes to the new owner
+	if diff := cmp.Diff([]string{"PV:A1"}, a.queried); diff != "" {
+		t.Errorf("Queried PVs of a mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"PV:A1", "PV:A1"}, b.queried); diff != "" {
+		t.Errorf("Queried PVs of b mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFederatedOwnerExpiration(t *testing.T) {
+	c, a, b := newTestFederation()
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	// b becomes the owner of PV:SHARED because a failed on the first probe
+	a.pvs = []string{"PV:A1"}
+	if _, err := c.ExecuteSingleQuery(context.Background(), "PV:SHARED", models.ArchiverQueryModel{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	a.pvs = []string{"PV:A1", "PV:SHARED"}
+
+	// The owner is looked up in order again after the TTL
+	now = now.Add(FEDERATED_OWNER_TTL + time.Second)
+	if _, err := c.ExecuteSingleQuery(context.Background(), "PV:SHARED", models.ArchiverQueryModel{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"PV:SHARED", "PV:SHARED"}, a.queried); diff != "" {
+		t.Errorf("Queried PVs of a mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"PV:SHARED"}, b.queried); diff != "" {
+		t.Errorf("Queried PVs of b mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFederatedMaxOwners(t *testing.T) {
+	c, _, _ := newTestFederation()
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for idx := range FEDERATED_MAX_OWNERS {
+		c.storeOwner(fmt.Sprintf("PV:%d", idx), 0, now)
+	}
+
+	// The expired owners are dropped first
+	now = now.Add(FEDERATED_OWNER_TTL + time.Second)
+	c.storeOwner("PV:NEW", 1, now)
+	if len(c.owners) != 1 {
+		t.Errorf("got %d owners, want 1", len(c.owners))
+	}
+
+	// All owners are dropped if none is expired
+	for idx := range FEDERATED_MAX_OWNERS - 1 {
+		c.storeOwner(fmt.Sprintf("PV:%d", idx), 0, now)
+	}
+	c.storeOwner("PV:LAST", 1, now)
+	if _, ok := c.lookupOwner("PV:LAST", now); !ok || len(c.owners) != 1 {
+		t.Errorf("got %d owners, want 1", len(c.owners))
+	}
+}
+
+func TestFederatedBatchQuery(t *testing.T) {
+	c, a, b := newTestFederation()
+	c.FetchRegexTargetPVs(context.Background(), "PV:.*", 100)
+
+	result, err := c.ExecuteBatchQuery(context.Background(), []string{"PV:A1", "PV:B1", "PV:B2", "PV:UNKNOWN"}, models.ArchiverQueryModel{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var targets []string
+	for target := range result {
+		targets = append(targets, target)
+	}
+	slices.Sort(targets)
+	if diff := cmp.Diff([]string{"PV:A1", "PV:B1", "PV:B2"}, targets); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([][]string{{"PV:A1"}}, a.batches); diff != "" {
+		t.Errorf("Batches of a mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([][]string{{"PV:B1", "PV:B2"}}, b.batches); diff != "" {
+		t.Errorf("Batches of b mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFederatedFetchVersion(t *testing.T) {
+	c, a, _ := newTestFederation()
+
+	version, err := c.FetchVersion(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if version != "v1, v2" {
+		t.Errorf("got %v, want %v", version, "v1, v2")
+	}
+
+	a.err = errors.New("test error")
+	if _, err := c.FetchVersion(context.Background()); err == nil {
+		t.Errorf("Error should be returned if any appliance doesn't answer")
+	}
+}
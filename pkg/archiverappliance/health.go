@@ -0,0 +1,117 @@
+package archiverappliance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/aalive"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+type HealthCheckStatus string
+
+const (
+	HEALTH_CHECK_OK      HealthCheckStatus = "OK"
+	HEALTH_CHECK_ERROR   HealthCheckStatus = "ERROR"
+	HEALTH_CHECK_SKIPPED HealthCheckStatus = "SKIPPED"
+)
+
+const (
+	HEALTH_CHECK_NAME_VERSION = "version"
+	HEALTH_CHECK_NAME_PVS     = "matchingPVs"
+	HEALTH_CHECK_NAME_LIVE    = "liveUpdate"
+)
+
+type HealthCheckResult struct {
+	Name      string            `json:"name"`
+	Status    HealthCheckStatus `json:"status"`
+	Message   string            `json:"message,omitempty"`
+	LatencyMs int64             `json:"latencyMs"`
+}
+
+type HealthDetails struct {
+	Version   string              `json:"version"`
+	LatencyMs int64               `json:"latencyMs"`
+	Checks    []HealthCheckResult `json:"checks"`
+}
+
+type healthProbe func(ctx context.Context) (string, error)
+
+func CheckHealth(ctx context.Context, client Client, config models.DatasourceSettings) *backend.CheckHealthResult {
+	// Probe the appliance and the optional live update server, and report the result of each check in JSONDetails
+	details := HealthDetails{}
+
+	versionCheck, version := runHealthProbe(ctx, HEALTH_CHECK_NAME_VERSION, func(ctx context.Context) (string, error) {
+		return client.FetchVersion(ctx)
+	})
+	details.Version = version
+	details.LatencyMs = versionCheck.LatencyMs
+	details.Checks = append(details.Checks, versionCheck)
+
+	// A cheap regex search confirms that the retrieval endpoint also answers PV lookups
+	pvsCheck, _ := runHealthProbe(ctx, HEALTH_CHECK_NAME_PVS, func(ctx context.Context) (string, error) {
+		pvs, err := client.FetchRegexTargetPVs(ctx, ".*", 1)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d PV(s) found", len(pvs)), nil
+	})
+	details.Checks = append(details.Checks, pvsCheck)
+
+	var liveCheck HealthCheckResult
+	if config.UseLiveUpdate && config.LiveUpdateURI != "" {
+		liveCheck, _ = runHealthProbe(ctx, HEALTH_CHECK_NAME_LIVE, func(ctx context.Context) (string, error) {
+			return "", aalive.CheckConnection(ctx, config.LiveUpdateURI)
+		})
+	} else {
+		liveCheck = HealthCheckResult{Name: HEALTH_CHECK_NAME_LIVE, Status: HEALTH_CHECK_SKIPPED, Message: "live update is disabled"}
+	}
+	details.Checks = append(details.Checks, liveCheck)
+
+	status := backend.HealthStatusOk
+	var failures []string
+	for _, c := range details.Checks {
+		if c.Status == HEALTH_CHECK_ERROR {
+			status = backend.HealthStatusError
+			failures = append(failures, fmt.Sprintf("%s: %s", c.Name, c.Message))
+		}
+	}
+
+	var message string
+	if status == backend.HealthStatusOk {
+		message = "Data source is working"
+		if details.Version != "" {
+			message = fmt.Sprintf("Data source is working (%s)", details.Version)
+		}
+	} else {
+		message = fmt.Sprintf("Data source is not working: %s", strings.Join(failures, ", "))
+	}
+
+	jsonDetails, err := json.Marshal(details)
+	if err != nil {
+		log.DefaultLogger.Warn("Failed to marshal health check details", "Error", err)
+	}
+
+	return &backend.CheckHealthResult{
+		Status:      status,
+		Message:     message,
+		JSONDetails: jsonDetails,
+	}
+}
+
+func runHealthProbe(ctx context.Context, name string, probe healthProbe) (HealthCheckResult, string) {
+	start := time.Now()
+	msg, err := probe(ctx)
+	latency := time.Since(start).Milliseconds()
+
+	if err != nil {
+		return HealthCheckResult{Name: name, Status: HEALTH_CHECK_ERROR, Message: err.Error(), LatencyMs: latency}, ""
+	}
+
+	return HealthCheckResult{Name: name, Status: HEALTH_CHECK_OK, Message: msg, LatencyMs: latency}, msg
+}
@@ -0,0 +1,124 @@
+package archiverappliance
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+func newFakeApplianceServer(versionStatus int, versionBody string, pvsStatus int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/retrieval/bpl/getVersion", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(versionStatus)
+		w.Write([]byte(versionBody))
+	})
+	mux.HandleFunc("/retrieval/bpl/getMatchingPVs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(pvsStatus)
+		if pvsStatus == http.StatusOK {
+			w.Write([]byte(`["PV:NAME1"]`))
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCheckHealth(t *testing.T) {
+	var tests = []struct {
+		name          string
+		versionStatus int
+		versionBody   string
+		pvsStatus     int
+		config        models.DatasourceSettings
+		status        backend.HealthStatus
+		version       string
+		checks        []HealthCheckStatus
+	}{
+		{
+			name:          "appliance is working with JSON version",
+			versionStatus: http.StatusOK,
+			versionBody:   `{"retrieval_version": "Archiver Appliance Version 1.1.0"}`,
+			pvsStatus:     http.StatusOK,
+			status:        backend.HealthStatusOk,
+			version:       "Archiver Appliance Version 1.1.0",
+			checks:        []HealthCheckStatus{HEALTH_CHECK_OK, HEALTH_CHECK_OK, HEALTH_CHECK_SKIPPED},
+		},
+		{
+			name:          "appliance is working with plain text version",
+			versionStatus: http.StatusOK,
+			versionBody:   "Archiver Appliance Version 0.0.1\n",
+			pvsStatus:     http.StatusOK,
+			status:        backend.HealthStatusOk,
+			version:       "Archiver Appliance Version 0.0.1",
+			checks:        []HealthCheckStatus{HEALTH_CHECK_OK, HEALTH_CHECK_OK, HEALTH_CHECK_SKIPPED},
+		},
+		{
+			name:          "getVersion returns error status",
+			versionStatus: http.StatusNotFound,
+			pvsStatus:     http.StatusOK,
+			status:        backend.HealthStatusError,
+			checks:        []HealthCheckStatus{HEALTH_CHECK_ERROR, HEALTH_CHECK_OK, HEALTH_CHECK_SKIPPED},
+		},
+		{
+			name:          "getMatchingPVs returns error status",
+			versionStatus: http.StatusOK,
+			versionBody:   "Archiver Appliance Version 0.0.1",
+			pvsStatus:     http.StatusUnauthorized,
+			status:        backend.HealthStatusError,
+			version:       "Archiver Appliance Version 0.0.1",
+			checks:        []HealthCheckStatus{HEALTH_CHECK_OK, HEALTH_CHECK_ERROR, HEALTH_CHECK_SKIPPED},
+		},
+		{
+			name:          "live update server is unreachable",
+			versionStatus: http.StatusOK,
+			versionBody:   "Archiver Appliance Version 0.0.1",
+			pvsStatus:     http.StatusOK,
+			config:        models.DatasourceSettings{UseLiveUpdate: true, LiveUpdateURI: "ws://127.0.0.1:1/pvws/pv"},
+			status:        backend.HealthStatusError,
+			version:       "Archiver Appliance Version 0.0.1",
+			checks:        []HealthCheckStatus{HEALTH_CHECK_OK, HEALTH_CHECK_OK, HEALTH_CHECK_ERROR},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := newFakeApplianceServer(testCase.versionStatus, testCase.versionBody, testCase.pvsStatus)
+			defer server.Close()
+
+			ctx := context.Background()
+			httpOptions := httpclient.Options{Timeouts: &httpclient.TimeoutOptions{Timeout: 5 * time.Second}}
+			client, err := NewAAClient(ctx, server.URL+"/retrieval", httpOptions, RetryOptions{})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			result := CheckHealth(ctx, client, testCase.config)
+			if result.Status != testCase.status {
+				t.Errorf("Status differs - Wanted: %v Got: %v (%s)", testCase.status, result.Status, result.Message)
+			}
+
+			var details HealthDetails
+			if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+				t.Fatalf("Failed to unmarshal details: %v", err)
+			}
+
+			if details.Version != testCase.version {
+				t.Errorf("Version differs - Wanted: %v Got: %v", testCase.version, details.Version)
+			}
+
+			if len(details.Checks) != len(testCase.checks) {
+				t.Fatalf("Lengths differ - Wanted: %v Got: %v", len(testCase.checks), len(details.Checks))
+			}
+			for idx, c := range details.Checks {
+				if c.Status != testCase.checks[idx] {
+					t.Errorf("Check %s differs - Wanted: %v Got: %v", c.Name, testCase.checks[idx], c.Status)
+				}
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/functions"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
 )
 
@@ -36,6 +37,12 @@ func operatorValidator(input string) bool {
 		"skewness",
 		"raw",
 		"last",
+		"optimized",
+		"optimLastSample",
+		"linear",
+		"loess",
+		"caplotbinning",
+		"deadBand",
 	}
 	for _, entry := range RECOGNIZED_OPERATORS {
 		if entry == input {
@@ -56,27 +63,26 @@ func createOperatorQuery(qm models.ArchiverQueryModel) (string, error) {
 		return "", errors.New(errMsg)
 	}
 
-	// No operators are necessary in this case
-	if qm.Operator == "raw" || qm.Operator == "last" {
+	// The raw data is retrieved and the operator is applied in the backend
+	if functions.LocalBinning(qm) {
 		return "", nil
 	}
 
-	binInterval := qm.Interval
-
-	// interval is less than 1 second or interval is not updated from "zero value"
-	if binInterval < 1 {
-		if !qm.DisableAutoRaw {
-			return "", nil
-		}
+	// The parameter is the number of the points for the optimized operators, and the bin interval for the others
+	param := qm.BinInterval()
+	if models.IsPointCountOperator(qm.Operator) {
+		param = qm.PointCount()
+	}
 
-		// if DisableAutoRaw is enabled, binInterval should be 1 second
-		binInterval = 1
+	// No operators are necessary if the raw data is retrieved
+	if param == 0 {
+		return "", nil
 	}
 
 	var opBuilder strings.Builder
 	opBuilder.WriteString(qm.Operator)
 	opBuilder.WriteString("_")
-	opBuilder.WriteString(strconv.Itoa(binInterval))
+	opBuilder.WriteString(strconv.Itoa(param))
 
 	return opBuilder.String(), nil
 }
@@ -16,6 +16,8 @@ func TestOperatorValidator(t *testing.T) {
 		{input: "firstSample", output: true},
 		{input: "lastFill", output: true},
 		{input: "lastFill_16", output: false},
+		{input: "optimized", output: true},
+		{input: "deadBand", output: true},
 		{input: "snakes", output: false},
 	}
 	for idx, testCase := range tests {
@@ -86,6 +88,59 @@ func TestCreateOperatorQuery(t *testing.T) {
 			},
 			output: "",
 		},
+		{
+			name: "mean operator with local processing",
+			input: models.ArchiverQueryModel{
+				Operator:   "mean",
+				Interval:   10,
+				Processing: models.PROCESSING_LOCAL,
+			},
+			output: "",
+		},
+		{
+			name: "operator not supported by local processing",
+			input: models.ArchiverQueryModel{
+				Operator:   "jitter",
+				Interval:   10,
+				Processing: models.PROCESSING_LOCAL,
+			},
+			output: "jitter_10",
+		},
+		{
+			name: "optimized operator with max data points",
+			input: models.ArchiverQueryModel{
+				Operator:      "optimized",
+				Interval:      10,
+				MaxDataPoints: 1000,
+			},
+			output: "optimized_1000",
+		},
+		{
+			name: "optimLastSample operator with 0 second interval",
+			input: models.ArchiverQueryModel{
+				Operator:      "optimLastSample",
+				Interval:      0,
+				MaxDataPoints: 500,
+			},
+			output: "optimLastSample_500",
+		},
+		{
+			name: "optimized operator without max data points",
+			input: models.ArchiverQueryModel{
+				Operator: "optimized",
+				Interval: 10,
+			},
+			output: "",
+		},
+		{
+			name: "linear operator with 10 second interval",
+			input: models.ArchiverQueryModel{
+				Operator:      "linear",
+				Interval:      10,
+				MaxDataPoints: 1000,
+			},
+			output: "linear_10",
+		},
 	}
 	for _, testCase := range tests {
 		t.Run(testCase.name, func(t *testing.T) {
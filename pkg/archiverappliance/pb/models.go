@@ -8,6 +8,18 @@ type MetaFieldData interface {
 	GetStatus() int32
 }
 
+// Field values which are sent with samples when the PV fields change
+type FieldValuesData interface {
+	GetFieldvalues() []*FieldValue
+}
+
+// Metadata which is sent with each sample
+type SampleMetaData interface {
+	GetRepeatcount() uint32
+	GetFieldvalues() []*FieldValue
+	GetFieldactualchange() bool
+}
+
 // Scalar Data
 type NumericSamepleData interface {
 	GetSecondsintoyear() uint32
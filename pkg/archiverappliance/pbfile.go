@@ -0,0 +1,275 @@
+package archiverappliance
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pb"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/functions"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// PBFileClient reads the PB partition files of the appliance in the directory without a running appliance.
+// The directory has the same layout as the storage of the appliance, e.g. ROOM/TEMP/1:2024.pb for ROOM:TEMP-1.
+// The operators which can be applied in the backend are applied to the raw data like the local processing.
+// The other operators are not supported.
+type PBFileClient struct {
+	root string
+}
+
+func NewPBFileClient(root string) *PBFileClient {
+	return &PBFileClient{root: root}
+}
+
+// The separators of the PV name are replaced with "/" in the key like the default key converter of the appliance
+var pvKeyReplacer = strings.NewReplacer(":", "/", "-", "/")
+
+// pvKey returns the key of the PV which is the prefix of the partition file paths
+func pvKey(pvname string) string {
+	return pvKeyReplacer.Replace(pvname) + ":"
+}
+
+// Layouts of the partition names for the yearly, monthly, daily, hourly and 5/15/30 minutes partitions
+var partitionLayouts = []string{"2006", "2006_01", "2006_01_02", "2006_01_02_15", "2006_01_02_15_04"}
+
+// partition is a PB file which holds the samples of a PV after start
+type partition struct {
+	path  string
+	start time.Time
+}
+
+func parsePartitionName(name string) (time.Time, bool) {
+	for _, layout := range partitionLayouts {
+		if len(name) != len(layout) {
+			continue
+		}
+		t, err := time.ParseInLocation(layout, name, time.UTC)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+// partitions returns the partition files of the PV in the order of time
+func (c *PBFileClient) partitions(pvname string) ([]partition, error) {
+	dir, prefix := filepath.Split(filepath.Join(c.root, filepath.FromSlash(pvKey(pvname))))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var parts []partition
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".pb") {
+			continue
+		}
+
+		start, ok := parsePartitionName(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".pb"))
+		if !ok {
+			continue
+		}
+		parts = append(parts, partition{path: filepath.Join(dir, name), start: start})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].start.Before(parts[j].start) })
+
+	return parts, nil
+}
+
+// selectPartitions returns the partitions which cover [from, to].
+// The partition started before from is included because it has the value at from.
+func selectPartitions(parts []partition, from time.Time, to time.Time) []partition {
+	first := sort.Search(len(parts), func(i int) bool { return !parts[i].start.Before(from) }) - 1
+	first = max(first, 0)
+	last := sort.Search(len(parts), func(i int) bool { return parts[i].start.After(to) })
+	if last <= first {
+		return nil
+	}
+
+	return parts[first:last]
+}
+
+func (c *PBFileClient) FetchRegexTargetPVs(ctx context.Context, regex string, limit int) ([]string, error) {
+	// The appliance matches the whole PV name
+	re, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return nil, err
+	}
+
+	var pvs []string
+	seen := make(map[string]bool)
+	err = filepath.WalkDir(c.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".pb") {
+			return nil
+		}
+
+		// The partitions of a PV share the key
+		idx := strings.LastIndex(path, ":")
+		if idx < 0 || seen[path[:idx]] {
+			return nil
+		}
+		seen[path[:idx]] = true
+
+		pvname, err := readPVName(path)
+		if err != nil {
+			log.DefaultLogger.Warn("Failed to read the PB file", "path", path, "Error", err)
+			return nil
+		}
+
+		if re.MatchString(pvname) {
+			pvs = append(pvs, pvname)
+		}
+		if limit > 0 && len(pvs) >= limit {
+			return filepath.SkipAll
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pvs, nil
+}
+
+// readPVName reads the PV name from the header of the PB file
+func readPVName(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return "", errFailedToParsePBFormat
+	}
+
+	info := &pb.PayloadInfo{}
+	if err := proto.Unmarshal(unescapeLine(line[:len(line)-1]), info); err != nil || info.GetPvname() == "" {
+		return "", errFailedToParsePBFormat
+	}
+
+	return info.GetPvname(), nil
+}
+
+func (c *PBFileClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	// For liveOnly response
+	if qm.LiveOnly {
+		return models.SingleData{Name: target, PVname: target, Values: &models.Scalars{}}, nil
+	}
+
+	// The local processing bins the raw data after the query
+	binning := !functions.LocalBinning(qm) && (qm.BinInterval() > 0 || qm.PointCount() > 0)
+	if binning && !functions.IsLocalOperator(qm.Operator) {
+		return models.SingleData{}, fmt.Errorf("target = %q: operator %q is not supported by the PB files", target, qm.Operator)
+	}
+
+	parts, err := c.partitions(target)
+	if err != nil {
+		return models.SingleData{}, fmt.Errorf("target = %q: %w", target, err)
+	}
+
+	// last operator returns the value at the end of the time range
+	from, to := qm.TimeRange.From, qm.TimeRange.To
+	if qm.Operator == "last" {
+		from = to
+	}
+
+	parts = selectPartitions(parts, from, to)
+	if len(parts) == 0 {
+		return models.SingleData{}, fmt.Errorf("target = %q: %w", target, errEmptyResponse)
+	}
+
+	// The partitions are read as the chunks of a response which are separated by an empty line.
+	// Each chunk has the year of the partition in its header.
+	readers := make([]io.Reader, 0, 2*len(parts))
+	for idx, p := range parts {
+		f, err := os.Open(p.path)
+		if err != nil {
+			return models.SingleData{}, fmt.Errorf("target = %q: %w", target, err)
+		}
+		defer f.Close()
+
+		if idx > 0 {
+			readers = append(readers, strings.NewReader("\n"))
+		}
+		readers = append(readers, f)
+	}
+
+	parsedResponse, err := archiverPBSingleQueryParser(io.MultiReader(readers...), models.FieldName(qm.FieldName), qm.MaxDataPoints, qm.HideInvalid, false)
+	if err != nil {
+		return parsedResponse, fmt.Errorf("target = %q: %w", target, err)
+	}
+
+	// The partitions hold the samples out of the time range
+	if v, ok := parsedResponse.Values.(models.TimeSlicer); ok {
+		start, end := models.IndexByTime(v, from, to)
+		if start == end {
+			return models.SingleData{}, fmt.Errorf("target = %q: %w", target, errEmptyResponse)
+		}
+
+		parsedResponse.Values = v.Slice(start, end)
+		if parsedResponse.SampleMeta != nil {
+			parsedResponse.SampleMeta = parsedResponse.SampleMeta.Slice(start, end)
+		}
+	}
+
+	if binning {
+		local := qm
+		local.Processing = models.PROCESSING_LOCAL
+		parsedResponse = *functions.ApplyBinning([]*models.SingleData{&parsedResponse}, local)[0]
+	}
+
+	parsedResponse.Name = target
+	parsedResponse.PVname = target
+	parsedResponse.Endpoint = c.root
+	parsedResponse.Meta.UseAlarmThresholds = qm.AlarmThresholds
+	if !qm.SampleMetadata {
+		parsedResponse.SampleMeta = nil
+	}
+
+	return parsedResponse, nil
+}
+
+// ExecuteBatchQuery is not supported. The PVs are read one by one.
+func (c *PBFileClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	return nil, errBatchUnsupported
+}
+
+func (c *PBFileClient) FetchVersion(ctx context.Context) (string, error) {
+	info, err := os.Stat(c.root)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%q is not a directory", c.root)
+	}
+
+	return fmt.Sprintf("PB files in %s", c.root), nil
+}
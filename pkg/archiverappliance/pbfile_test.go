@@ -0,0 +1,198 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pb"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
+	"google.golang.org/protobuf/proto"
+)
+
+// writePartition writes the PB file of the PV with the samples at the days into the year
+func writePartition(t *testing.T, root string, pvname string, name string, year int32, days ...uint32) {
+	info := &pb.PayloadInfo{Type: pb.PayloadType_SCALAR_DOUBLE.Enum(), Pvname: proto.String(pvname), Year: proto.Int32(year)}
+	var samples []proto.Message
+	for _, d := range days {
+		samples = append(samples, &pb.ScalarDouble{Secondsintoyear: proto.Uint32(d * 86400), Nano: proto.Uint32(0), Val: proto.Float64(float64(d))})
+	}
+
+	path := filepath.Join(root, filepath.FromSlash(pvKey(pvname)+name+".pb"))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create the directory: %v", err)
+	}
+	if err := os.WriteFile(path, buildPBChunk(t, info, samples...), 0o644); err != nil {
+		t.Fatalf("Failed to write the PB file: %v", err)
+	}
+}
+
+func newTestPBFiles(t *testing.T) string {
+	root := t.TempDir()
+	writePartition(t, root, "ROOM:TEMP-1", "2022", 2022, 10, 20)
+	writePartition(t, root, "ROOM:TEMP-1", "2023", 2023, 100, 360)
+	writePartition(t, root, "ROOM:TEMP-1", "2024", 2024, 1, 10, 100)
+	writePartition(t, root, "ROOM:TEMP:2", "2024_01", 2024, 1, 2)
+	writePartition(t, root, "ROOM:TEMP:2", "2024_02", 2024, 40)
+	return root
+}
+
+func day(year int, d int) time.Time {
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, d)
+}
+
+func TestPVKey(t *testing.T) {
+	if key := pvKey("ROOM:TEMP-1"); key != "ROOM/TEMP/1:" {
+		t.Errorf("got %v, want %v", key, "ROOM/TEMP/1:")
+	}
+}
+
+func TestPBFileQuery(t *testing.T) {
+	root := newTestPBFiles(t)
+	client := NewPBFileClient(root)
+
+	var tests = []struct {
+		name     string
+		pvname   string
+		from     time.Time
+		to       time.Time
+		operator string
+		output   []time.Time
+	}{
+		{name: "across years", pvname: "ROOM:TEMP-1", from: day(2024, 5), to: day(2024, 200), output: []time.Time{day(2024, 1), day(2024, 10), day(2024, 100)}},
+		{name: "value at from in the previous year", pvname: "ROOM:TEMP-1", from: day(2023, 365), to: day(2024, 5), output: []time.Time{day(2023, 360), day(2024, 1)}},
+		{name: "older partition", pvname: "ROOM:TEMP-1", from: day(2022, 15), to: day(2022, 30), output: []time.Time{day(2022, 10), day(2022, 20)}},
+		{name: "monthly partitions", pvname: "ROOM:TEMP:2", from: day(2024, 0), to: day(2024, 60), output: []time.Time{day(2024, 1), day(2024, 2), day(2024, 40)}},
+		{name: "last operator", pvname: "ROOM:TEMP-1", from: day(2022, 0), to: day(2024, 50), operator: "last", output: []time.Time{day(2024, 10)}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			qm := models.ArchiverQueryModel{
+				Operator:      testCase.operator,
+				FieldName:     string(models.FIELD_NAME_VAL),
+				MaxDataPoints: 100,
+				TimeRange:     backend.TimeRange{From: testCase.from, To: testCase.to},
+			}
+			result, err := client.ExecuteSingleQuery(context.Background(), testCase.pvname, qm)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(testCase.output, result.Values.(*models.Scalars).Times); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+			if result.PVname != testCase.pvname || result.Endpoint != root {
+				t.Errorf("Unexpected result: %v, %v", result.PVname, result.Endpoint)
+			}
+		})
+	}
+}
+
+func TestPBFileQueryOperator(t *testing.T) {
+	client := NewPBFileClient(newTestPBFiles(t))
+
+	var tests = []struct {
+		name       string
+		operator   string
+		processing models.ProcessingOption
+		output     []float64
+	}{
+		{name: "count", operator: "count", output: []float64{3}},
+		{name: "max", operator: "max", output: []float64{40}},
+		{name: "local processing returns the raw data", operator: "max", processing: models.PROCESSING_LOCAL, output: []float64{1, 2, 40}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			// All samples are in the same bin of 100 days aligned to the Unix epoch
+			qm := models.ArchiverQueryModel{
+				Operator:      testCase.operator,
+				Processing:    testCase.processing,
+				Interval:      100 * 86400,
+				FieldName:     string(models.FIELD_NAME_VAL),
+				MaxDataPoints: 100,
+				TimeRange:     backend.TimeRange{From: day(2024, 0), To: day(2024, 60)},
+			}
+			result, err := client.ExecuteSingleQuery(context.Background(), "ROOM:TEMP:2", qm)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			values := result.Values.(*models.Scalars).Values
+			if diff := cmp.Diff(testhelper.InitFloat64SlicePointer(testCase.output), values); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPBFileQueryUnsupportedOperator(t *testing.T) {
+	client := NewPBFileClient(newTestPBFiles(t))
+
+	for _, operator := range []string{"variance", "optimized"} {
+		qm := models.ArchiverQueryModel{
+			Operator:      operator,
+			Interval:      86400,
+			FieldName:     string(models.FIELD_NAME_VAL),
+			MaxDataPoints: 100,
+			TimeRange:     backend.TimeRange{From: day(2024, 0), To: day(2024, 60)},
+		}
+		if _, err := client.ExecuteSingleQuery(context.Background(), "ROOM:TEMP:2", qm); err == nil {
+			t.Errorf("Error should be returned for %s", operator)
+		}
+	}
+}
+
+func TestPBFileQueryEmpty(t *testing.T) {
+	client := NewPBFileClient(newTestPBFiles(t))
+	qm := models.ArchiverQueryModel{FieldName: string(models.FIELD_NAME_VAL), TimeRange: backend.TimeRange{From: day(2020, 0), To: day(2020, 100)}}
+
+	for _, pvname := range []string{"ROOM:TEMP-1", "ROOM:NONE"} {
+		if _, err := client.ExecuteSingleQuery(context.Background(), pvname, qm); !errors.Is(err, errEmptyResponse) {
+			t.Errorf("Unexpected error for %s: %v", pvname, err)
+		}
+	}
+}
+
+func TestPBFileRegexSearch(t *testing.T) {
+	client := NewPBFileClient(newTestPBFiles(t))
+
+	var tests = []struct {
+		regex  string
+		limit  int
+		output []string
+	}{
+		{regex: "ROOM:.*", limit: 100, output: []string{"ROOM:TEMP-1", "ROOM:TEMP:2"}},
+		{regex: "ROOM:TEMP-.*", limit: 100, output: []string{"ROOM:TEMP-1"}},
+		{regex: "TEMP", limit: 100, output: nil},
+		{regex: ".*", limit: 1, output: []string{"ROOM:TEMP-1"}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.regex, func(t *testing.T) {
+			pvs, err := client.FetchRegexTargetPVs(context.Background(), testCase.regex, testCase.limit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.output, pvs); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPBFileFetchVersion(t *testing.T) {
+	if _, err := NewPBFileClient(t.TempDir()).FetchVersion(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := NewPBFileClient(filepath.Join(t.TempDir(), "none")).FetchVersion(context.Background()); err == nil {
+		t.Errorf("Error should be returned")
+	}
+}
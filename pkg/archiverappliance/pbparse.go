@@ -7,6 +7,7 @@ import (
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pb"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pvdata"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
 	"google.golang.org/protobuf/proto"
 )
@@ -29,6 +30,12 @@ const (
 	MessageType_String  MessageType = 1
 	MessageType_Array   MessageType = 2
 	MessageType_Enum    MessageType = 3
+	MessageType_Alarm   MessageType = 4
+
+	MessageType_StringArray MessageType = 5
+	MessageType_EnumArray   MessageType = 6
+	MessageType_V4          MessageType = 7
+	MessageType_Table       MessageType = 8
 )
 
 type EPICSSeverity int
@@ -40,8 +47,57 @@ const (
 	EPICSSeverity_INVALID  EPICSSeverity = 3
 )
 
-func archiverPBSingleQueryParser(in io.Reader, field models.FieldName, initialCapacity int, hideInvalid bool) (models.SingleData, error) {
-	var sD models.SingleData
+func archiverPBSingleQueryParser(in io.Reader, field models.FieldName, initialCapacity int, hideInvalid bool, statistics bool) (models.SingleData, error) {
+	sDs, err := archiverPBQueryParser(in, field, initialCapacity, hideInvalid, statistics)
+	if err != nil {
+		return models.SingleData{}, err
+	}
+
+	return sDs[0], nil
+}
+
+// Number of the elements of the samples returned by the optimized operators: mean or last sample, std, min, max and count
+const statisticsLength = 5
+
+// statisticsPayloadType is the payload type of the samples returned by the optimized operators
+const statisticsPayloadType = pb.PayloadType_WAVEFORM_DOUBLE
+
+// toStatistics converts the arrays of the statistics payload returned by the optimized operators into the statistics.
+// The appliance returns the raw samples if the time range has fewer samples than the points, and they are returned as is.
+func toStatistics(values models.Values, payloadType pb.PayloadType) models.Values {
+	arrays, ok := values.(*models.Arrays)
+	if !ok || payloadType != statisticsPayloadType || len(arrays.Values) == 0 {
+		return values
+	}
+
+	// Malformed statistics are returned as is instead of being converted partially
+	for _, row := range arrays.Values {
+		if len(row) != statisticsLength {
+			return values
+		}
+	}
+
+	stats := models.NewStatistics(len(arrays.Values))
+	for idx, row := range arrays.Values {
+		stats.Append(row[0], row[1], row[2], row[3], row[4], arrays.Times[idx])
+	}
+
+	return stats
+}
+
+// pbStream holds the samples of a PV in the response
+type pbStream struct {
+	pvname      string
+	payloadType pb.PayloadType
+	values      models.Values
+	headers     map[string]string
+	sampleMeta  *models.SampleMetadata
+}
+
+// archiverPBQueryParser parses the response which contains the chunks of one or more PVs.
+// The chunks are demultiplexed by the pvname of PayloadInfo and the data is returned in the order of the first chunk of each PV.
+// If statistics is true, the samples of the statistics payload are converted into the statistics.
+func archiverPBQueryParser(in io.Reader, field models.FieldName, initialCapacity int, hideInvalid bool, statistics bool) ([]models.SingleData, error) {
 	info := &pb.PayloadInfo{}
 	inChunk := false
 	var dataType pb.PayloadType = -1
@@ -55,19 +111,21 @@ func archiverPBSingleQueryParser(in io.Reader, field models.FieldName, initialCa
 	if err != nil {
 		// Peek(1) returns EOF error if its size is 0
 		if err == io.EOF {
-			return sD, errEmptyResponse
+			return nil, errEmptyResponse
 		}
 		// Other errors should be handled as parse errors
-		return sD, errFailedToParsePBFormat
+		return nil, errFailedToParsePBFormat
 	}
 
-	var values models.Values
+	var streams []*pbStream
+	streamIndex := make(map[string]*pbStream)
+	var stream *pbStream
 	for {
 		lineWithDelim, err := reader.ReadBytes('\n')
 		if err != nil {
 			if err != io.EOF {
 				log.DefaultLogger.Error("Failed to read pb message:", err)
-				return sD, errFailedToParsePBFormat
+				return nil, errFailedToParsePBFormat
 			}
 			break
 		}
@@ -92,59 +150,149 @@ func archiverPBSingleQueryParser(in io.Reader, field models.FieldName, initialCa
 			dataType = *info.Type
 			year = *info.Year
 
+			pvname := info.GetPvname()
+			if pvname == "" {
+				return nil, errFailedToParsePBFormat
+			}
+
+			var ok bool
+			stream, ok = streamIndex[pvname]
+			if !ok {
+				stream = &pbStream{pvname: pvname, payloadType: dataType, headers: make(map[string]string), sampleMeta: models.NewSampleMetadata()}
+				streamIndex[pvname] = stream
+				streams = append(streams, stream)
+			}
+
+			// Headers of the later chunk take precedence
+			for _, h := range info.GetHeaders() {
+				stream.headers[h.GetName()] = h.GetVal()
+			}
+
 			messageType, _ := getMessageType(dataType, field)
 
 			// values is already initialized
-			if values != nil {
+			if stream.values != nil {
 				continue
 			}
 
 			// Inialialize values
-			values, err = getInitializedValues(messageType, field, initialCapacity)
+			stream.values, err = getInitializedValues(messageType, field, initialCapacity)
 			if err != nil {
-				return sD, err
+				return nil, err
 			}
 
 			continue
 		}
 
 		// Handle chunk data
-		switch v := values.(type) {
+		message, err := unmarshalPBMessage(unescapedLine, dataType)
+		if err != nil {
+			return nil, err
+		}
+
+		// V4 samples are converted into the samples of the type which the structure represents.
+		// The type of the values is known after the first sample is decoded.
+		var v4Table *pvdata.Structure
+		if sample, ok := message.(*pb.V4GenericBytes); ok && isV4ValueField(field) {
+			var sampleType pb.PayloadType
+			message, sampleType, v4Table, err = convertV4Message(sample)
+			if err != nil {
+				return nil, err
+			}
+
+			if stream.values == nil {
+				messageType := MessageType_Table
+				if v4Table == nil {
+					messageType, _ = getMessageType(sampleType, field)
+				}
+				stream.values, err = getInitializedValues(messageType, field, initialCapacity)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// Changes of the PV fields are sent with the samples
+		var fieldValues map[string]string
+		if sample, ok := message.(pb.FieldValuesData); ok {
+			for _, fv := range sample.GetFieldvalues() {
+				stream.headers[fv.GetName()] = fv.GetVal()
+			}
+			fieldValues = getFieldValues(sample)
+		}
+
+		switch v := stream.values.(type) {
 		case *models.Scalars:
 			var value *float64
 			var sec, nano uint32
 			var err error
 
 			if field == models.FIELD_NAME_VAL {
-				value, sec, nano, err = getNumericValue(unescapedLine, dataType, hideInvalid)
+				value, sec, nano, err = getNumericValue(message, hideInvalid)
 			} else {
-				value, sec, nano, err = getMetaValue(unescapedLine, dataType, field)
+				value, sec, nano, err = getMetaValue(message, field)
 			}
 
 			if err != nil {
-				return sD, errFailedToParsePBFormat
+				return nil, errFailedToParsePBFormat
 			}
 			t := calcTime(year, sec, nano)
 			v.Append(value, t)
+		case *models.AlarmScalars:
+			value, sec, nano, err := getNumericValue(message, hideInvalid)
+			if err != nil {
+				return nil, errFailedToParsePBFormat
+			}
+			sevr, _, _, err := getMetaValue(message, models.FIELD_NAME_SEVR)
+			if err != nil {
+				return nil, errFailedToParsePBFormat
+			}
+			stat, _, _, err := getMetaValue(message, models.FIELD_NAME_STAT)
+			if err != nil {
+				return nil, errFailedToParsePBFormat
+			}
+			t := calcTime(year, sec, nano)
+			v.Append(value, int16(*sevr), int16(*stat), t)
 		case *models.Arrays:
-			value, sec, nano, err := getArrayValue(unescapedLine, dataType)
+			value, sec, nano, err := getArrayValue(message)
+			if err != nil {
+				return nil, errFailedToParsePBFormat
+			}
+			t := calcTime(year, sec, nano)
+			v.Append(value, t)
+		case *models.StringArrays:
+			value, sec, nano, err := getStringArrayValue(message)
+			if err != nil {
+				return nil, errFailedToParsePBFormat
+			}
+			t := calcTime(year, sec, nano)
+			v.Append(value, t)
+		case *models.EnumArrays:
+			value, sec, nano, err := getEnumArrayValue(message)
 			if err != nil {
-				return sD, errFailedToParsePBFormat
+				return nil, errFailedToParsePBFormat
 			}
 			t := calcTime(year, sec, nano)
 			v.Append(value, t)
+		case *models.Tables:
+			names, columns, sec, nano, err := getTableValue(message, v4Table)
+			if err != nil {
+				return nil, errFailedToParsePBFormat
+			}
+			t := calcTime(year, sec, nano)
+			v.Append(names, columns, t)
 		case *models.Strings:
-			value, sec, nano, err := getStringValue(unescapedLine)
+			value, sec, nano, err := getStringValue(message)
 			if err != nil {
-				return sD, errFailedToParsePBFormat
+				return nil, errFailedToParsePBFormat
 			}
 			t := calcTime(year, sec, nano)
 			v.Append(value, t)
 		case *models.Enums:
-			value, sec, nano, err := getMetaValue(unescapedLine, dataType, field)
+			value, sec, nano, err := getMetaValue(message, field)
 
 			if err != nil {
-				return sD, errFailedToParsePBFormat
+				return nil, errFailedToParsePBFormat
 			}
 			if value == nil {
 				continue
@@ -152,20 +300,81 @@ func archiverPBSingleQueryParser(in io.Reader, field models.FieldName, initialCa
 			t := calcTime(year, sec, nano)
 			v.Append(int16(*value), t)
 		default:
-			return sD, errIllegalPayloadType
+			return nil, errIllegalPayloadType
+		}
+
+		repeatCount, actualChange := getSampleMetaValue(message, fieldValues)
+		stream.sampleMeta.Append(repeatCount, actualChange, fieldValues)
+	}
+
+	if len(streams) == 0 {
+		return nil, errFailedToParsePBFormat
+	}
+
+	sDs := make([]models.SingleData, 0, len(streams))
+	for _, stream := range streams {
+		values := stream.values
+		if statistics {
+			values = toStatistics(values, stream.payloadType)
 		}
+		sDs = append(sDs, models.SingleData{
+			Name:       stream.pvname,
+			PVname:     stream.pvname,
+			Values:     values,
+			Meta:       getMetadata(stream.headers, field),
+			SampleMeta: stream.sampleMeta,
+		})
+	}
+
+	return sDs, nil
+}
+
+func getFieldValues(sample pb.FieldValuesData) map[string]string {
+	fvs := sample.GetFieldvalues()
+	if len(fvs) == 0 {
+		return nil
 	}
 
-	pvname := info.GetPvname()
-	if pvname == "" {
-		return sD, errFailedToParsePBFormat
+	fieldValues := make(map[string]string, len(fvs))
+	for _, fv := range fvs {
+		fieldValues[fv.GetName()] = fv.GetVal()
 	}
 
-	sD.Name = pvname
-	sD.PVname = pvname
-	sD.Values = values
+	return fieldValues
+}
+
+func getSampleMetaValue(message proto.Message, fieldValues map[string]string) (*int64, *bool) {
+	sample, ok := message.(pb.SampleMetaData)
+	if !ok {
+		return nil, nil
+	}
+
+	// repeatcount is set only if the appliance collapsed the repeated samples
+	var repeatCount *int64
+	if c := sample.GetRepeatcount(); c > 0 {
+		rc := int64(c)
+		repeatCount = &rc
+	}
+
+	// fieldactualchange is meaningful only with the field values
+	var actualChange *bool
+	if len(fieldValues) > 0 {
+		ac := sample.GetFieldactualchange()
+		actualChange = &ac
+	}
 
-	return sD, nil
+	return repeatCount, actualChange
+}
+
+func getMetadata(headers map[string]string, field models.FieldName) models.Metadata {
+	meta := models.NewMetadata(headers)
+
+	// EGU, PREC and the display limits describe VAL only
+	if field != models.FIELD_NAME_VAL && field != models.FIELD_NAME_VAL_WITH_ALARM {
+		return meta.DescriptionOnly()
+	}
+
+	return meta
 }
 
 func unescapeLine(line []byte) []byte {
@@ -204,19 +413,23 @@ func unescapeLine(line []byte) []byte {
 	return buf
 }
 
-func getMetaValue(line []byte, dataType pb.PayloadType, field models.FieldName) (val *float64, sec uint32, nano uint32, err error) {
+func unmarshalPBMessage(line []byte, dataType pb.PayloadType) (proto.Message, error) {
 	message := initPBMessage(dataType)
 
 	if message == nil {
-		return nil, 0, 0, errIllegalPayloadType
+		return nil, errIllegalPayloadType
 	}
 
 	if err := proto.Unmarshal(line, *message); err != nil {
 		log.DefaultLogger.Error("Failed to parse paylod data:", err)
-		return nil, 0, 0, errIllegalPayloadType
+		return nil, errFailedToParsePBFormat
 	}
 
-	sample, ok := (*message).(pb.MetaFieldData)
+	return *message, nil
+}
+
+func getMetaValue(message proto.Message, field models.FieldName) (val *float64, sec uint32, nano uint32, err error) {
+	sample, ok := message.(pb.MetaFieldData)
 
 	if !ok {
 		return nil, 0, 0, errIllegalPayloadType
@@ -241,19 +454,8 @@ func getMetaValue(line []byte, dataType pb.PayloadType, field models.FieldName)
 	return val, sec, nano, nil
 }
 
-func getNumericValue(line []byte, dataType pb.PayloadType, hideInvalid bool) (val *float64, sec uint32, nano uint32, err error) {
-	message := initPBMessage(dataType)
-
-	if message == nil {
-		return nil, 0, 0, errIllegalPayloadType
-	}
-
-	if err := proto.Unmarshal(line, *message); err != nil {
-		log.DefaultLogger.Error("Failed to parse paylod data:", err)
-		return nil, 0, 0, errIllegalPayloadType
-	}
-
-	sample, ok := (*message).(pb.NumericSamepleData)
+func getNumericValue(message proto.Message, hideInvalid bool) (val *float64, sec uint32, nano uint32, err error) {
+	sample, ok := message.(pb.NumericSamepleData)
 
 	if !ok {
 		return nil, 0, 0, errIllegalPayloadType
@@ -274,40 +476,56 @@ func getNumericValue(line []byte, dataType pb.PayloadType, hideInvalid bool) (va
 	return val, sec, nano, nil
 }
 
-func getStringValue(line []byte) (val string, sec uint32, nano uint32, err error) {
-	message := &pb.ScalarString{}
+func getStringValue(message proto.Message) (val string, sec uint32, nano uint32, err error) {
+	sample, ok := message.(*pb.ScalarString)
 
-	if err := proto.Unmarshal(line, message); err != nil {
-		log.DefaultLogger.Error("Failed to parse paylod data:", err)
+	if !ok {
 		return "", 0, 0, errIllegalPayloadType
 	}
 
-	val = message.GetVal()
-	sec = message.GetSecondsintoyear()
-	nano = message.GetNano()
+	val = sample.GetVal()
+	sec = sample.GetSecondsintoyear()
+	nano = sample.GetNano()
 
 	return val, sec, nano, nil
 }
 
-func getArrayValue(line []byte, dataType pb.PayloadType) (val []float64, sec uint32, nano uint32, err error) {
-	message := initPBMessage(dataType)
+func getArrayValue(message proto.Message) (val []float64, sec uint32, nano uint32, err error) {
+	sample, ok := message.(pb.ArraySamepleData)
 
-	if message == nil {
+	if !ok {
 		return []float64{}, 0, 0, errIllegalPayloadType
 	}
 
-	if err := proto.Unmarshal(line, *message); err != nil {
-		log.DefaultLogger.Error("Failed to parse paylod data:", err)
-		return []float64{}, 0, 0, errIllegalPayloadType
+	val = sample.GetValAsFloat64()
+	sec = sample.GetSecondsintoyear()
+	nano = sample.GetNano()
+
+	return val, sec, nano, nil
+}
+
+func getStringArrayValue(message proto.Message) (val []string, sec uint32, nano uint32, err error) {
+	sample, ok := message.(*pb.VectorString)
+
+	if !ok {
+		return []string{}, 0, 0, errIllegalPayloadType
 	}
 
-	sample, ok := (*message).(pb.ArraySamepleData)
+	val = sample.GetVal()
+	sec = sample.GetSecondsintoyear()
+	nano = sample.GetNano()
+
+	return val, sec, nano, nil
+}
+
+func getEnumArrayValue(message proto.Message) (val []int32, sec uint32, nano uint32, err error) {
+	sample, ok := message.(*pb.VectorEnum)
 
 	if !ok {
-		return []float64{}, 0, 0, errIllegalPayloadType
+		return []int32{}, 0, 0, errIllegalPayloadType
 	}
 
-	val = sample.GetValAsFloat64()
+	val = sample.GetVal()
 	sec = sample.GetSecondsintoyear()
 	nano = sample.GetNano()
 
@@ -346,6 +564,8 @@ func initPBMessage(dataType pb.PayloadType) *proto.Message {
 		m = &pb.VectorFloat{}
 	case pb.PayloadType_WAVEFORM_DOUBLE:
 		m = &pb.VectorDouble{}
+	case pb.PayloadType_V4_GENERIC_BYTES:
+		m = &pb.V4GenericBytes{}
 	default:
 		return nil
 	}
@@ -371,16 +591,30 @@ func getMessageType(dataType pb.PayloadType, field models.FieldName) (MessageTyp
 		pb.PayloadType_SCALAR_FLOAT,
 		pb.PayloadType_SCALAR_DOUBLE:
 		{
+			if field == models.FIELD_NAME_VAL_WITH_ALARM {
+				return MessageType_Alarm, nil
+			}
 			return MessageType_Numeric, nil
 		}
 	case pb.PayloadType_SCALAR_STRING:
 		{
 			return MessageType_String, nil
 		}
+	case pb.PayloadType_WAVEFORM_STRING:
+		{
+			return MessageType_StringArray, nil
+		}
+	case pb.PayloadType_V4_GENERIC_BYTES:
+		{
+			return MessageType_V4, nil
+		}
+	case pb.PayloadType_WAVEFORM_ENUM:
+		{
+			return MessageType_EnumArray, nil
+		}
 	case pb.PayloadType_WAVEFORM_BYTE,
 		pb.PayloadType_WAVEFORM_SHORT,
 		pb.PayloadType_WAVEFORM_INT,
-		pb.PayloadType_WAVEFORM_ENUM,
 		pb.PayloadType_WAVEFORM_FLOAT,
 		pb.PayloadType_WAVEFORM_DOUBLE:
 		{
@@ -399,6 +633,17 @@ func getInitializedValues(mtype MessageType, field models.FieldName, capacity in
 		values = models.NewStrings(capacity)
 	case MessageType_Array:
 		values = models.NewArrays(capacity)
+	case MessageType_Alarm:
+		values = models.NewAlarmScalars(capacity)
+	case MessageType_StringArray:
+		values = models.NewStringArrays(capacity)
+	case MessageType_EnumArray:
+		values = models.NewEnumArrays(capacity)
+	case MessageType_V4:
+		// Values are initialized with the first sample
+		values = nil
+	case MessageType_Table:
+		values = models.NewTables(capacity)
 	case MessageType_Enum:
 		switch field {
 		case models.FIELD_NAME_SEVR_AS_ENUM:
@@ -1,13 +1,18 @@
 package archiverappliance
 
 import (
+	"bytes"
 	"math"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pb"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/functions"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestParseScalarData(t *testing.T) {
@@ -120,7 +125,7 @@ func TestParseScalarData(t *testing.T) {
 
 			defer f.Close()
 
-			sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false)
+			sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false, false)
 			if err != nil {
 				t.Fatalf("Failed to parse the data: %v", err)
 			}
@@ -191,7 +196,7 @@ func TestParseSingleStringData(t *testing.T) {
 
 			defer f.Close()
 
-			sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false)
+			sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false, false)
 			if err != nil {
 				t.Fatalf("Failed to parse the data: %v", err)
 			}
@@ -266,12 +271,6 @@ func TestParseArrayData(t *testing.T) {
 			firstData: outputArray{-1, time.Date(2012, 1, 1, 9, 43, 37, 0, time.UTC), 0},
 			lastData:  outputArray{1, time.Date(2012, 1, 2, 9, 43, 37, 0, time.UTC), 1},
 		},
-		{
-			name:      "WAVEFORM_ENUM_sampledata",
-			length:    2,
-			firstData: outputArray{0, time.Date(2012, 1, 1, 9, 43, 37, 0, time.UTC), 1024},
-			lastData:  outputArray{1, time.Date(2012, 1, 2, 9, 43, 37, 0, time.UTC), 1024},
-		},
 		{
 			name:      "WAVEFORM_FLOAT_sampledata",
 			length:    2,
@@ -296,7 +295,7 @@ func TestParseArrayData(t *testing.T) {
 
 			defer f.Close()
 
-			sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false)
+			sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false, false)
 			if err != nil {
 				t.Fatalf("Failed to parse the data: %v", err)
 			}
@@ -390,7 +389,7 @@ func TestParseDataWithSeverity(t *testing.T) {
 
 			defer f.Close()
 
-			sD, err := archiverPBSingleQueryParser(f, testCase.field, 1000, false)
+			sD, err := archiverPBSingleQueryParser(f, testCase.field, 1000, false, false)
 			if err != nil {
 				t.Fatalf("Failed to parse the data: %v", err)
 			}
@@ -474,7 +473,7 @@ func TestParseDataWithSeverityEnum(t *testing.T) {
 
 			defer f.Close()
 
-			sD, err := archiverPBSingleQueryParser(f, testCase.field, 1000, false)
+			sD, err := archiverPBSingleQueryParser(f, testCase.field, 1000, false, false)
 			if err != nil {
 				t.Fatalf("Failed to parse the data: %v", err)
 			}
@@ -541,7 +540,7 @@ func TestParseSevInvalidData(t *testing.T) {
 
 			defer f.Close()
 
-			sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, true)
+			sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, true, false)
 			if err != nil {
 				t.Fatalf("Failed to parse the data: %v", err)
 			}
@@ -589,7 +588,7 @@ func TestParseEmptyData(t *testing.T) {
 
 	defer f.Close()
 
-	_, err = archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false)
+	_, err = archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false, false)
 	if err != errEmptyResponse {
 		t.Fatalf("parser should return response empty error: %v", err)
 	}
@@ -603,6 +602,676 @@ func BenchmarkPBparseOneday(b *testing.B) {
 			return
 		}
 		defer f.Close()
-		_, _ = archiverPBSingleQueryParser(f, "pvname", 1000, false)
+		_, _ = archiverPBSingleQueryParser(f, "pvname", 1000, false, false)
+	}
+}
+
+func TestParseEnumArrayData(t *testing.T) {
+	f, err := os.Open("../test_data/pb/WAVEFORM_ENUM_sampledata")
+	if err != nil {
+		t.Fatalf("Failed to load test data: %v", err)
+	}
+	defer f.Close()
+
+	sD, err := archiverPBSingleQueryParser(f, models.FIELD_NAME_VAL, 1000, false, false)
+	if err != nil {
+		t.Fatalf("Failed to parse the data: %v", err)
+	}
+
+	v, ok := sD.Values.(*models.EnumArrays)
+	if !ok {
+		t.Fatalf("Single data type is diffrent")
+	}
+
+	if len(v.Times) != 2 {
+		t.Fatalf("Lengths differ - Wanted: %v Got: %v", 2, len(v.Times))
+	}
+	if len(v.Values[0]) != 1024 || len(v.Values[1]) != 1024 {
+		t.Fatalf("Data Lengths differ - Wanted: %v Got: %v, %v", 1024, len(v.Values[0]), len(v.Values[1]))
+	}
+	if v.Values[0][0] != 0 || v.Values[1][0] != 1 {
+		t.Fatalf("Values differ - Wanted: 0, 1 Got: %v, %v", v.Values[0][0], v.Values[1][0])
+	}
+	if !v.Times[0].Equal(time.Date(2012, 1, 1, 9, 43, 37, 0, time.UTC)) {
+		t.Fatalf("First date differ - Got: %v", v.Times[0])
+	}
+
+	// The array functions are applied to the indices of the enum states
+	qm := models.ArchiverQueryModel{
+		Functions: []models.FunctionDescriptorQueryModel{
+			{Def: models.FuncDefQueryModel{Category: models.FUNC_CATEGORY_TOSCALAR, Name: "toScalarByMax"}},
+		},
+	}
+	result, err := functions.ApplyFunctions([]*models.SingleData{&sD}, qm)
+	if err != nil {
+		t.Fatalf("Failed to apply the functions: %v", err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Number of the results differ - Wanted: 1 Got: %v", len(result))
+	}
+	scalars, ok := result[0].Values.(*models.Scalars)
+	if !ok {
+		t.Fatalf("Single data type is diffrent")
+	}
+	want := v.ToArrays()
+	for idx, row := range want.Values {
+		m := row[0]
+		for _, val := range row {
+			m = math.Max(m, val)
+		}
+		if *scalars.Values[idx] != m {
+			t.Errorf("Max differs at %d - Wanted: %v Got: %v", idx, m, *scalars.Values[idx])
+		}
+	}
+	if diff := cmp.Diff(want.Times, scalars.Times); diff != "" {
+		t.Errorf("Times mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseStringAndEnumArrays(t *testing.T) {
+	var tests = []struct {
+		name    string
+		info    *pb.PayloadInfo
+		samples []proto.Message
+		format  models.FormatOption
+		names   []string
+		values  [][]interface{}
+		enum    []string
+	}{
+		{
+			name: "string array timeseries",
+			info: &pb.PayloadInfo{Type: pb.PayloadType_WAVEFORM_STRING.Enum(), Pvname: proto.String("PV:STRS"), Year: proto.Int32(2024)},
+			samples: []proto.Message{
+				&pb.VectorString{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: []string{"a", "b"}},
+				&pb.VectorString{Secondsintoyear: proto.Uint32(1), Nano: proto.Uint32(0), Val: []string{"c"}},
+			},
+			format: models.FormatOption(models.FORMAT_TIMESERIES),
+			names:  []string{"time", "PV:STRS[0]", "PV:STRS[1]"},
+			values: [][]interface{}{{"a", "c"}, {"b", ""}},
+		},
+		{
+			name: "string array dt-space",
+			info: &pb.PayloadInfo{Type: pb.PayloadType_WAVEFORM_STRING.Enum(), Pvname: proto.String("PV:STRS"), Year: proto.Int32(2024)},
+			samples: []proto.Message{
+				&pb.VectorString{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: []string{"a", "b"}},
+				&pb.VectorString{Secondsintoyear: proto.Uint32(1), Nano: proto.Uint32(0), Val: []string{"c"}},
+			},
+			format: models.FormatOption(models.FORMAT_DTSPACE),
+			names:  []string{"time", "PV:STRS"},
+			values: [][]interface{}{{"a", "b", "c"}},
+		},
+		{
+			name: "enum array index with state strings",
+			info: &pb.PayloadInfo{
+				Type:   pb.PayloadType_WAVEFORM_ENUM.Enum(),
+				Pvname: proto.String("PV:ENUMS"),
+				Year:   proto.Int32(2024),
+				Headers: []*pb.FieldValue{
+					{Name: proto.String("ZRST"), Val: proto.String("Off")},
+					{Name: proto.String("ONST"), Val: proto.String("On")},
+				},
+			},
+			samples: []proto.Message{
+				&pb.VectorEnum{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: []int32{0, 1, 2}},
+			},
+			format: models.FormatOption(models.FORMAT_INDEX),
+			names:  []string{"index", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Local().Format("2006-01-02T15:04:05.000Z07:00")},
+			values: [][]interface{}{{data.EnumItemIndex(0), data.EnumItemIndex(1), data.EnumItemIndex(2)}},
+			enum:   []string{"Off", "On", "2"},
+		},
+		{
+			name: "enum array timeseries without state strings",
+			info: &pb.PayloadInfo{Type: pb.PayloadType_WAVEFORM_ENUM.Enum(), Pvname: proto.String("PV:ENUMS"), Year: proto.Int32(2024)},
+			samples: []proto.Message{
+				&pb.VectorEnum{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: []int32{0, 1}},
+				&pb.VectorEnum{Secondsintoyear: proto.Uint32(1), Nano: proto.Uint32(0), Val: []int32{1, 0}},
+			},
+			format: models.FormatOption(models.FORMAT_TIMESERIES),
+			names:  []string{"time", "PV:ENUMS[0]", "PV:ENUMS[1]"},
+			values: [][]interface{}{{data.EnumItemIndex(0), data.EnumItemIndex(1)}, {data.EnumItemIndex(1), data.EnumItemIndex(0)}},
+			enum:   []string{"0", "1"},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			in := buildPBResponse(buildPBChunk(t, testCase.info, testCase.samples...))
+
+			sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, false)
+			if err != nil {
+				t.Fatalf("Failed to parse the data: %v", err)
+			}
+
+			frame := sD.ToFrame(testCase.format)
+
+			var names []string
+			for _, f := range frame.Fields {
+				names = append(names, f.Name)
+			}
+			if diff := cmp.Diff(testCase.names, names); diff != "" {
+				t.Fatalf("Field names mismatch (-want +got):\n%s", diff)
+			}
+
+			for idx, want := range testCase.values {
+				f := frame.Fields[idx+1]
+				var got []interface{}
+				for i := 0; i < f.Len(); i++ {
+					got = append(got, f.At(i))
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("Values of %s mismatch (-want +got):\n%s", f.Name, diff)
+				}
+
+				if testCase.enum == nil {
+					continue
+				}
+				if diff := cmp.Diff(testCase.enum, f.Config.TypeConfig.Enum.Text); diff != "" {
+					t.Errorf("Enum labels of %s mismatch (-want +got):\n%s", f.Name, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDataWithHeaders(t *testing.T) {
+	headers := []*pb.FieldValue{
+		{Name: proto.String("EGU"), Val: proto.String("mA")},
+		{Name: proto.String("PREC"), Val: proto.String("3")},
+		{Name: proto.String("HOPR"), Val: proto.String("500")},
+		{Name: proto.String("LOPR"), Val: proto.String("0")},
+		{Name: proto.String("DESC"), Val: proto.String("Beam current")},
+	}
+	samples := []proto.Message{
+		&pb.ScalarDouble{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: proto.Float64(1.5)},
+		&pb.ScalarDouble{Secondsintoyear: proto.Uint32(1), Nano: proto.Uint32(0), Val: proto.Float64(2.5)},
+	}
+
+	var tests = []struct {
+		name     string
+		field    models.FieldName
+		egu      string
+		prec     *uint16
+		hopr     *float64
+		lopr     *float64
+		desc     string
+		fieldMin *data.ConfFloat64
+		fieldMax *data.ConfFloat64
+	}{
+		{
+			name:     "VAL",
+			field:    models.FIELD_NAME_VAL,
+			egu:      "mA",
+			prec:     testUint16Pointer(3),
+			hopr:     testFloat64Pointer(500),
+			lopr:     testFloat64Pointer(0),
+			desc:     "Beam current",
+			fieldMin: testConfFloat64Pointer(0),
+			fieldMax: testConfFloat64Pointer(500),
+		},
+		{
+			name:  "SEVR",
+			field: models.FIELD_NAME_SEVR,
+			desc:  "Beam current",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			info := &pb.PayloadInfo{
+				Type:    pb.PayloadType_SCALAR_DOUBLE.Enum(),
+				Pvname:  proto.String("PV:CURRENT"),
+				Year:    proto.Int32(2024),
+				Headers: headers,
+			}
+			in := buildPBResponse(buildPBChunk(t, info, samples...))
+
+			sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), testCase.field, 1000, false, false)
+			if err != nil {
+				t.Fatalf("Failed to parse the data: %v", err)
+			}
+
+			if sD.Meta.EGU != testCase.egu {
+				t.Errorf("EGU differs - Wanted: %v Got: %v", testCase.egu, sD.Meta.EGU)
+			}
+			if diff := cmp.Diff(testCase.prec, sD.Meta.PREC); diff != "" {
+				t.Errorf("PREC mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.hopr, sD.Meta.HOPR); diff != "" {
+				t.Errorf("HOPR mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.lopr, sD.Meta.LOPR); diff != "" {
+				t.Errorf("LOPR mismatch (-want +got):\n%s", diff)
+			}
+			if sD.Meta.DESC != testCase.desc {
+				t.Errorf("DESC differs - Wanted: %v Got: %v", testCase.desc, sD.Meta.DESC)
+			}
+
+			frame := sD.ToFrame(models.FormatOption(models.FORMAT_TIMESERIES))
+			config := frame.Fields[1].Config
+			if config.Unit != testCase.egu {
+				t.Errorf("Unit differs - Wanted: %v Got: %v", testCase.egu, config.Unit)
+			}
+			if diff := cmp.Diff(testCase.prec, config.Decimals); diff != "" {
+				t.Errorf("Decimals mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.fieldMin, config.Min); diff != "" {
+				t.Errorf("Min mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.fieldMax, config.Max); diff != "" {
+				t.Errorf("Max mismatch (-want +got):\n%s", diff)
+			}
+			if config.Description != testCase.desc {
+				t.Errorf("Description differs - Wanted: %v Got: %v", testCase.desc, config.Description)
+			}
+		})
+	}
+}
+
+func TestParseDataWithSampleFieldValues(t *testing.T) {
+	info := &pb.PayloadInfo{
+		Type:   pb.PayloadType_SCALAR_DOUBLE.Enum(),
+		Pvname: proto.String("PV:CURRENT"),
+		Year:   proto.Int32(2024),
+		Headers: []*pb.FieldValue{
+			{Name: proto.String("HIHI"), Val: proto.String("90")},
+			{Name: proto.String("HIGH"), Val: proto.String("80")},
+		},
+	}
+	samples := []proto.Message{
+		&pb.ScalarDouble{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: proto.Float64(1.5)},
+		&pb.ScalarDouble{
+			Secondsintoyear: proto.Uint32(1),
+			Nano:            proto.Uint32(0),
+			Val:             proto.Float64(2.5),
+			Fieldvalues:     []*pb.FieldValue{{Name: proto.String("HIHI"), Val: proto.String("95")}},
+		},
+	}
+	in := buildPBResponse(buildPBChunk(t, info, samples...))
+
+	sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, false)
+	if err != nil {
+		t.Fatalf("Failed to parse the data: %v", err)
+	}
+
+	if sD.Meta.Alarm.HIHI == nil || sD.Meta.Alarm.HIHI.Value != 95 {
+		t.Errorf("HIHI should be updated by the field value in the sample: %v", sD.Meta.Alarm.HIHI)
+	}
+	if sD.Meta.Alarm.HIGH == nil || sD.Meta.Alarm.HIGH.Value != 80 {
+		t.Errorf("HIGH should be kept from the header: %v", sD.Meta.Alarm.HIGH)
+	}
+}
+
+func TestParseDataWithSampleMetadata(t *testing.T) {
+	info := &pb.PayloadInfo{
+		Type:   pb.PayloadType_SCALAR_DOUBLE.Enum(),
+		Pvname: proto.String("PV:CURRENT"),
+		Year:   proto.Int32(2024),
+	}
+	samples := []proto.Message{
+		&pb.ScalarDouble{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: proto.Float64(1.5)},
+		&pb.ScalarDouble{Secondsintoyear: proto.Uint32(1), Nano: proto.Uint32(0), Val: proto.Float64(1.5), Repeatcount: proto.Uint32(3)},
+		&pb.ScalarDouble{
+			Secondsintoyear:   proto.Uint32(2),
+			Nano:              proto.Uint32(0),
+			Val:               proto.Float64(2.5),
+			Fieldvalues:       []*pb.FieldValue{{Name: proto.String("EGU"), Val: proto.String("mA")}},
+			Fieldactualchange: proto.Bool(true),
+		},
+	}
+	in := buildPBResponse(buildPBChunk(t, info, samples...))
+
+	sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, false)
+	if err != nil {
+		t.Fatalf("Failed to parse the data: %v", err)
+	}
+
+	if sD.SampleMeta == nil {
+		t.Fatalf("Sample metadata should be set")
+	}
+
+	frame := sD.ToFrame(models.FormatOption(models.FORMAT_TIMESERIES))
+
+	var names []string
+	for _, f := range frame.Fields {
+		names = append(names, f.Name)
+	}
+	wantNames := []string{"time", "PV:CURRENT", "PV:CURRENT.repeatcount", "PV:CURRENT.fieldactualchange", "PV:CURRENT.EGU"}
+	if diff := cmp.Diff(wantNames, names); diff != "" {
+		t.Fatalf("Field names mismatch (-want +got):\n%s", diff)
+	}
+
+	repeatCount := int64(3)
+	actualChange := true
+	egu := "mA"
+	if diff := cmp.Diff([]*int64{nil, &repeatCount, nil}, frameFieldValues[*int64](frame.Fields[2])); diff != "" {
+		t.Errorf("repeatcount mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]*bool{nil, nil, &actualChange}, frameFieldValues[*bool](frame.Fields[3])); diff != "" {
+		t.Errorf("fieldactualchange mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]*string{nil, nil, &egu}, frameFieldValues[*string](frame.Fields[4])); diff != "" {
+		t.Errorf("EGU mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func frameFieldValues[T any](f *data.Field) []T {
+	vals := make([]T, f.Len())
+	for idx := range vals {
+		vals[idx] = f.At(idx).(T)
+	}
+	return vals
+}
+
+func TestParseDataWithAlarm(t *testing.T) {
+	info := &pb.PayloadInfo{
+		Type:   pb.PayloadType_SCALAR_DOUBLE.Enum(),
+		Pvname: proto.String("PV:CURRENT"),
+		Year:   proto.Int32(2024),
+	}
+	samples := []proto.Message{
+		&pb.ScalarDouble{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: proto.Float64(1.5)},
+		&pb.ScalarDouble{Secondsintoyear: proto.Uint32(1), Nano: proto.Uint32(0), Val: proto.Float64(85), Severity: proto.Int32(1), Status: proto.Int32(4)},
+		&pb.ScalarDouble{Secondsintoyear: proto.Uint32(2), Nano: proto.Uint32(0), Val: proto.Float64(95), Severity: proto.Int32(2), Status: proto.Int32(3)},
+	}
+	in := buildPBResponse(buildPBChunk(t, info, samples...))
+
+	sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL_WITH_ALARM, 1000, false, false)
+	if err != nil {
+		t.Fatalf("Failed to parse the data: %v", err)
+	}
+
+	v, ok := sD.Values.(*models.AlarmScalars)
+	if !ok {
+		t.Fatalf("Single data type is diffrent")
+	}
+
+	wantVals := []float64{1.5, 85, 95}
+	wantSevr := []data.EnumItemIndex{0, 1, 2}
+	wantStat := []data.EnumItemIndex{0, 4, 3}
+
+	if len(v.Scalars.Values) != len(wantVals) || len(v.Severity.Values) != len(wantSevr) || len(v.Status.Values) != len(wantStat) {
+		t.Fatalf("Lengths differ - Wanted: %v Got: %v, %v, %v", len(wantVals), len(v.Scalars.Values), len(v.Severity.Values), len(v.Status.Values))
+	}
+
+	for idx := range wantVals {
+		if *v.Scalars.Values[idx] != wantVals[idx] {
+			t.Errorf("Values at index %v differ - Wanted: %v Got: %v", idx, wantVals[idx], *v.Scalars.Values[idx])
+		}
+		if v.Severity.Values[idx] != wantSevr[idx] {
+			t.Errorf("Severities at index %v differ - Wanted: %v Got: %v", idx, wantSevr[idx], v.Severity.Values[idx])
+		}
+		if v.Status.Values[idx] != wantStat[idx] {
+			t.Errorf("Statuses at index %v differ - Wanted: %v Got: %v", idx, wantStat[idx], v.Status.Values[idx])
+		}
+	}
+
+	frame := sD.ToFrame(models.FormatOption(models.FORMAT_TIMESERIES))
+	wantNames := []string{"time", "PV:CURRENT", "PV:CURRENT.SEVR", "PV:CURRENT.STAT"}
+	if len(frame.Fields) != len(wantNames) {
+		t.Fatalf("Number of fields differ - Wanted: %v Got: %v", len(wantNames), len(frame.Fields))
+	}
+	for idx, name := range wantNames {
+		if frame.Fields[idx].Name != name {
+			t.Errorf("Field name differs - Wanted: %v Got: %v", name, frame.Fields[idx].Name)
+		}
+	}
+}
+
+// Test helpers
+
+func escapeLine(line []byte) []byte {
+	buf := make([]byte, 0, len(line))
+	for _, b := range line {
+		switch EscapeCharType(b) {
+		case EscapeCharType_ESCAPE_CHAR:
+			buf = append(buf, byte(EscapeCharType_ESCAPE_CHAR), byte(EscapeCharType_ESCAPE_ESCAPE_CHAR))
+		case EscapeCharType_NEWLINE_CHAR:
+			buf = append(buf, byte(EscapeCharType_ESCAPE_CHAR), byte(EscapeCharType_NEWLINE_ESCAPE_CHAR))
+		case EscapeCharType_CARRIAGERETURN_CHAR:
+			buf = append(buf, byte(EscapeCharType_ESCAPE_CHAR), byte(EscapeCharType_CARRIAGERETURN_ESCAPE_CHAR))
+		default:
+			buf = append(buf, b)
+		}
+	}
+	return buf
+}
+
+func buildPBChunk(t testing.TB, info *pb.PayloadInfo, samples ...proto.Message) []byte {
+	// Build a chunk in the same line-based format as the PB response from the appliance
+	var buf bytes.Buffer
+	for _, m := range append([]proto.Message{info}, samples...) {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatalf("Failed to marshal test data: %v", err)
+		}
+		buf.Write(escapeLine(b))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func buildPBResponse(chunks ...[]byte) []byte {
+	// Chunks are separated by an empty line
+	return bytes.Join(chunks, []byte("\n"))
+}
+
+func testUint16Pointer(v uint16) *uint16 {
+	return &v
+}
+
+func testFloat64Pointer(v float64) *float64 {
+	return &v
+}
+
+func testConfFloat64Pointer(v float64) *data.ConfFloat64 {
+	c := data.ConfFloat64(v)
+	return &c
+}
+
+func TestParseMultiplePVs(t *testing.T) {
+	scalar := func(sec uint32, val float64) proto.Message {
+		return &pb.ScalarDouble{Secondsintoyear: proto.Uint32(sec), Nano: proto.Uint32(0), Val: proto.Float64(val)}
+	}
+	infoA := func(year int32) *pb.PayloadInfo {
+		return &pb.PayloadInfo{
+			Type:    pb.PayloadType_SCALAR_DOUBLE.Enum(),
+			Pvname:  proto.String("PV:A"),
+			Year:    proto.Int32(year),
+			Headers: []*pb.FieldValue{{Name: proto.String("EGU"), Val: proto.String("mA")}},
+		}
+	}
+	infoB := &pb.PayloadInfo{
+		Type:   pb.PayloadType_SCALAR_STRING.Enum(),
+		Pvname: proto.String("PV:B"),
+		Year:   proto.Int32(2024),
+	}
+
+	// The chunks of PV:A are separated by the chunk of PV:B
+	in := buildPBResponse(
+		buildPBChunk(t, infoA(2023), scalar(0, 1)),
+		buildPBChunk(t, infoB, &pb.ScalarString{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: proto.String("on")}),
+		buildPBChunk(t, infoA(2024), scalar(0, 2), scalar(1, 3)),
+	)
+
+	sDs, err := archiverPBQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, false)
+	if err != nil {
+		t.Fatalf("Failed to parse the data: %v", err)
+	}
+
+	if len(sDs) != 2 || sDs[0].PVname != "PV:A" || sDs[1].PVname != "PV:B" {
+		t.Fatalf("PVs differ - Got: %v", sDs)
+	}
+
+	a, ok := sDs[0].Values.(*models.Scalars)
+	if !ok {
+		t.Fatalf("Single data type is diffrent")
+	}
+	var vals []float64
+	for _, v := range a.Values {
+		vals = append(vals, *v)
+	}
+	if diff := cmp.Diff([]float64{1, 2, 3}, vals); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+	if !a.Times[0].Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)) || !a.Times[2].Equal(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC)) {
+		t.Errorf("Times differ - Got: %v", a.Times)
+	}
+	if sDs[0].Meta.EGU != "mA" {
+		t.Errorf("EGU differs - Got: %v", sDs[0].Meta.EGU)
+	}
+
+	b, ok := sDs[1].Values.(*models.Strings)
+	if !ok {
+		t.Fatalf("Single data type is diffrent")
+	}
+	if diff := cmp.Diff([]string{"on"}, b.Values); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+	if sDs[1].Meta.EGU != "" {
+		t.Errorf("Headers of the other PV should not be shared: %v", sDs[1].Meta.EGU)
+	}
+}
+
+func TestParseOptimizedData(t *testing.T) {
+	info := &pb.PayloadInfo{
+		Type:   pb.PayloadType_WAVEFORM_DOUBLE.Enum(),
+		Pvname: proto.String("PV:CURRENT"),
+		Year:   proto.Int32(2024),
+	}
+	// mean, std, min, max and count of the bins
+	samples := []proto.Message{
+		&pb.VectorDouble{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: []float64{1.5, 0.5, 1, 2, 4}},
+		&pb.VectorDouble{Secondsintoyear: proto.Uint32(60), Nano: proto.Uint32(0), Val: []float64{3, 1, 2, 4, 3}},
+	}
+	in := buildPBResponse(buildPBChunk(t, info, samples...))
+
+	sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, true)
+	if err != nil {
+		t.Fatalf("Failed to parse the data: %v", err)
+	}
+
+	v, ok := sD.Values.(*models.Statistics)
+	if !ok {
+		t.Fatalf("Single data type is diffrent")
+	}
+
+	times := []time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)}
+	want := &models.Statistics{
+		Value: models.NewSclarsWithValues(times, []*float64{testFloat64Pointer(1.5), testFloat64Pointer(3)}),
+		Std:   models.NewSclarsWithValues(times, []*float64{testFloat64Pointer(0.5), testFloat64Pointer(1)}),
+		Min:   models.NewSclarsWithValues(times, []*float64{testFloat64Pointer(1), testFloat64Pointer(2)}),
+		Max:   models.NewSclarsWithValues(times, []*float64{testFloat64Pointer(2), testFloat64Pointer(4)}),
+		Count: models.NewSclarsWithValues(times, []*float64{testFloat64Pointer(4), testFloat64Pointer(3)}),
+	}
+	if diff := cmp.Diff(want, v); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+
+	frame := sD.ToFrame(models.FormatOption(models.FORMAT_TIMESERIES))
+	wantNames := []string{"time", "PV:CURRENT", "PV:CURRENT.min", "PV:CURRENT.max", "PV:CURRENT.std", "PV:CURRENT.count"}
+	if len(frame.Fields) != len(wantNames) {
+		t.Fatalf("Number of fields differ - Wanted: %v Got: %v", len(wantNames), len(frame.Fields))
+	}
+	for idx, name := range wantNames {
+		if frame.Fields[idx].Name != name {
+			t.Errorf("Field name differs - Wanted: %v Got: %v", name, frame.Fields[idx].Name)
+		}
+	}
+}
+
+func TestApplyFunctionsToOptimizedData(t *testing.T) {
+	info := &pb.PayloadInfo{
+		Type:   pb.PayloadType_WAVEFORM_DOUBLE.Enum(),
+		Pvname: proto.String("PV:CURRENT"),
+		Year:   proto.Int32(2024),
+	}
+	samples := []proto.Message{
+		&pb.VectorDouble{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: []float64{1.5, 0.5, 1, 2, 4}},
+		&pb.VectorDouble{Secondsintoyear: proto.Uint32(60), Nano: proto.Uint32(0), Val: []float64{3, 1, 2, 4, 3}},
+	}
+	in := buildPBResponse(buildPBChunk(t, info, samples...))
+
+	sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, true)
+	if err != nil {
+		t.Fatalf("Failed to parse the data: %v", err)
+	}
+
+	// The value of the statistics is scaled and the other statistics are dropped
+	qm := models.ArchiverQueryModel{
+		Functions: []models.FunctionDescriptorQueryModel{
+			{
+				Def: models.FuncDefQueryModel{
+					Category: "Transform",
+					Name:     "scale",
+					Params:   []models.FuncDefParamQueryModel{{Name: "factor", Type: "float"}},
+				},
+				Params: []string{"2"},
+			},
+		},
+	}
+	result, err := functions.ApplyFunctions([]*models.SingleData{&sD}, qm)
+	if err != nil {
+		t.Fatalf("Failed to apply the functions: %v", err)
+	}
+
+	times := []time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)}
+	want := models.NewSclarsWithValues(times, []*float64{testFloat64Pointer(3), testFloat64Pointer(6)})
+	if diff := cmp.Diff(want, result[0].Values); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseOptimizedWaveform(t *testing.T) {
+	// The appliance returns the raw samples of the waveform PV which have the same length as the statistics
+	info := &pb.PayloadInfo{
+		Type:   pb.PayloadType_WAVEFORM_INT.Enum(),
+		Pvname: proto.String("PV:WAVEFORM"),
+		Year:   proto.Int32(2024),
+	}
+	samples := []proto.Message{
+		&pb.VectorInt{Secondsintoyear: proto.Uint32(0), Nano: proto.Uint32(0), Val: []int32{1, 2, 3, 4, 5}},
+	}
+	in := buildPBResponse(buildPBChunk(t, info, samples...))
+
+	sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, true)
+	if err != nil {
+		t.Fatalf("Failed to parse the data: %v", err)
+	}
+
+	want := &models.Arrays{
+		Times:  []time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Values: [][]float64{{1, 2, 3, 4, 5}},
+	}
+	if diff := cmp.Diff(want, sD.Values); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestToStatistics(t *testing.T) {
+	times := []time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	var tests = []struct {
+		name        string
+		input       models.Values
+		payloadType pb.PayloadType
+		output      bool
+	}{
+		{name: "statistics", input: &models.Arrays{Times: times, Values: [][]float64{{1, 0, 1, 1, 1}}}, payloadType: pb.PayloadType_WAVEFORM_DOUBLE, output: true},
+		{name: "raw samples", input: models.NewSclarsWithValues(times, []*float64{testFloat64Pointer(1)}), payloadType: pb.PayloadType_SCALAR_DOUBLE, output: false},
+		{name: "waveform with the length of the statistics", input: &models.Arrays{Times: times, Values: [][]float64{{1, 2, 3, 4, 5}}}, payloadType: pb.PayloadType_WAVEFORM_INT, output: false},
+		{name: "malformed statistics", input: &models.Arrays{Times: times, Values: [][]float64{{1, 2, 3}}}, payloadType: pb.PayloadType_WAVEFORM_DOUBLE, output: false},
+		{name: "empty", input: models.NewArrays(0), payloadType: pb.PayloadType_WAVEFORM_DOUBLE, output: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := toStatistics(testCase.input, testCase.payloadType)
+			if _, ok := result.(*models.Statistics); ok != testCase.output {
+				t.Errorf("got %T, want statistics: %v", result, testCase.output)
+			}
+			if !testCase.output && result != testCase.input {
+				t.Errorf("Values should be returned as is")
+			}
+		})
 	}
 }
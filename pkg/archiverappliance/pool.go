@@ -0,0 +1,172 @@
+package archiverappliance
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+const (
+	// Maximum number of the concurrent requests to the appliance per datasource
+	DEFAULT_MAX_CONCURRENCY = 50
+	// Maximum number of the concurrent requests per query
+	DEFAULT_MAX_QUERY_CONCURRENCY = 10
+	// Maximum number of the PVs retrieved in one bulk request
+	BATCH_MAX_PVS = 50
+)
+
+var poolQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "archiverappliance",
+	Name:      "pool_queue_wait_seconds",
+	Help:      "Time the requests waited in the queue for a free worker of the datasource.",
+	Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+}, []string{"datasource"})
+
+var poolQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "archiverappliance",
+	Name:      "pool_queued_requests",
+	Help:      "Number of the requests waiting for a free worker of the datasource.",
+}, []string{"datasource"})
+
+type querySessionKey struct{}
+
+// querySession groups the requests of a query so that the pool serves the concurrent queries in turn
+type querySession struct {
+	waiters []chan struct{}
+	elem    *list.Element
+}
+
+// withQuerySession returns the context whose requests are queued together in the pool
+func withQuerySession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, querySessionKey{}, &querySession{})
+}
+
+// PooledClient limits the number of the concurrent requests to the appliance.
+// When all workers are busy, the requests are queued per query and the queries are served round-robin,
+// so that a query of many PVs doesn't block the other panels.
+type PooledClient struct {
+	Client
+	uid  string
+	size int
+
+	mu     sync.Mutex
+	active int
+	// sessions which have the waiting requests in the serving order
+	queue *list.List
+}
+
+func NewPooledClient(client Client, uid string, size int) *PooledClient {
+	if size <= 0 {
+		size = DEFAULT_MAX_CONCURRENCY
+	}
+
+	return &PooledClient{
+		Client: client,
+		uid:    uid,
+		size:   size,
+		queue:  list.New(),
+	}
+}
+
+func (c *PooledClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	if err := c.acquire(ctx); err != nil {
+		return models.SingleData{}, err
+	}
+	defer c.release()
+
+	return c.Client.ExecuteSingleQuery(ctx, target, qm)
+}
+
+// ExecuteBatchQuery occupies a worker for the request of all PVs
+func (c *PooledClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+
+	return c.Client.ExecuteBatchQuery(ctx, targets, qm)
+}
+
+func (c *PooledClient) acquire(ctx context.Context) error {
+	c.mu.Lock()
+	if c.active < c.size && c.queue.Len() == 0 {
+		c.active++
+		c.mu.Unlock()
+		poolQueueWait.WithLabelValues(c.uid).Observe(0)
+		return nil
+	}
+
+	// The requests without a query session are queued as a query of their own
+	s, ok := ctx.Value(querySessionKey{}).(*querySession)
+	if !ok {
+		s = &querySession{}
+	}
+
+	ch := make(chan struct{})
+	s.waiters = append(s.waiters, ch)
+	if s.elem == nil {
+		s.elem = c.queue.PushBack(s)
+	}
+	c.mu.Unlock()
+
+	poolQueued.WithLabelValues(c.uid).Inc()
+	defer poolQueued.WithLabelValues(c.uid).Dec()
+
+	start := time.Now()
+	select {
+	case <-ch:
+		poolQueueWait.WithLabelValues(c.uid).Observe(time.Since(start).Seconds())
+		return nil
+	case <-ctx.Done():
+	}
+
+	c.mu.Lock()
+	for idx, w := range s.waiters {
+		if w == ch {
+			s.waiters = append(s.waiters[:idx], s.waiters[idx+1:]...)
+			if len(s.waiters) == 0 {
+				c.queue.Remove(s.elem)
+				s.elem = nil
+			}
+			c.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	c.mu.Unlock()
+
+	// The worker was handed over while the context was canceled
+	c.release()
+
+	return ctx.Err()
+}
+
+// release hands the worker over to the next query in the queue or frees it
+func (c *PooledClient) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	front := c.queue.Front()
+	if front == nil {
+		c.active--
+		return
+	}
+
+	s := front.Value.(*querySession)
+	ch := s.waiters[0]
+	s.waiters = s.waiters[1:]
+
+	if len(s.waiters) == 0 {
+		c.queue.Remove(front)
+		s.elem = nil
+	} else {
+		// The next request of this query waits for the other queries
+		c.queue.MoveToBack(front)
+	}
+
+	close(ch)
+}
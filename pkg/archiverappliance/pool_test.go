@@ -0,0 +1,201 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+// blockingClient blocks the requests until release is closed and records the number of the concurrent requests
+type blockingClient struct {
+	fakeClient
+	release chan struct{}
+	started chan string
+
+	active    atomic.Int32
+	maxActive atomic.Int32
+}
+
+func newBlockingClient() *blockingClient {
+	return &blockingClient{release: make(chan struct{}), started: make(chan string, 1000)}
+}
+
+func (f *blockingClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	n := f.active.Add(1)
+	defer f.active.Add(-1)
+	for {
+		m := f.maxActive.Load()
+		if n <= m || f.maxActive.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	f.started <- target
+	<-f.release
+
+	return fakeClient{}.ExecuteSingleQuery(ctx, target, qm)
+}
+
+func waitStarted(t *testing.T, f *blockingClient) string {
+	t.Helper()
+	select {
+	case target := <-f.started:
+		return target
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Request was not started")
+	}
+	return ""
+}
+
+func TestPooledClientLimit(t *testing.T) {
+	f := newBlockingClient()
+	c := NewPooledClient(f, "uid", 2)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.ExecuteSingleQuery(context.Background(), "PV", models.ArchiverQueryModel{})
+		}()
+	}
+
+	waitStarted(t, f)
+	waitStarted(t, f)
+	close(f.release)
+	wg.Wait()
+
+	if m := f.maxActive.Load(); m != 2 {
+		t.Errorf("Maximum number of the concurrent requests: got %d, want 2", m)
+	}
+}
+
+func TestPooledClientFairness(t *testing.T) {
+	f := newBlockingClient()
+	c := NewPooledClient(f, "uid", 1)
+
+	// Occupy the worker
+	go c.ExecuteSingleQuery(context.Background(), "busy", models.ArchiverQueryModel{})
+	waitStarted(t, f)
+
+	// Query A queues 3 requests before query B queues 2 requests
+	queued := 0
+	enqueue := func(ctx context.Context, target string) {
+		go c.ExecuteSingleQuery(ctx, target, models.ArchiverQueryModel{})
+		queued++
+		for waitingCount(c) != queued {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	ctxA := withQuerySession(context.Background())
+	ctxB := withQuerySession(context.Background())
+	enqueue(ctxA, "A1")
+	enqueue(ctxA, "A2")
+	enqueue(ctxA, "A3")
+	enqueue(ctxB, "B1")
+	enqueue(ctxB, "B2")
+
+	var order []string
+	for range 5 {
+		f.release <- struct{}{}
+		order = append(order, waitStarted(t, f))
+	}
+	f.release <- struct{}{}
+
+	if diff := cmp.Diff([]string{"A1", "B1", "A2", "B2", "A3"}, order); diff != "" {
+		t.Errorf("Serving order mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func waitingCount(c *PooledClient) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for e := c.queue.Front(); e != nil; e = e.Next() {
+		n += len(e.Value.(*querySession).waiters)
+	}
+	return n
+}
+
+func TestPooledClientCancel(t *testing.T) {
+	f := newBlockingClient()
+	c := NewPooledClient(f, "uid", 1)
+
+	go c.ExecuteSingleQuery(context.Background(), "busy", models.ArchiverQueryModel{})
+	waitStarted(t, f)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errPipe := make(chan error)
+	go func() {
+		_, err := c.ExecuteSingleQuery(ctx, "canceled", models.ArchiverQueryModel{})
+		errPipe <- err
+	}()
+	for waitingCount(c) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	if err := <-errPipe; !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if n := waitingCount(c); n != 0 {
+		t.Errorf("Canceled request should be removed from the queue: %d", n)
+	}
+
+	// The worker is freed after the running request
+	close(f.release)
+	if _, err := c.ExecuteSingleQuery(context.Background(), "PV", models.ArchiverQueryModel{}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active != 0 {
+		t.Errorf("All workers should be freed: %d", c.active)
+	}
+}
+
+func TestQueryConcurrency(t *testing.T) {
+	f := newBlockingClient()
+	close(f.release)
+
+	// The PVs are split into 4 batches
+	var pvs []string
+	for idx := range 4 * BATCH_MAX_PVS {
+		pvs = append(pvs, fmt.Sprintf("PV%03d", idx))
+	}
+
+	qm := models.ArchiverQueryModel{
+		Target:       "(" + strings.Join(pvs, "|") + ")",
+		TimeRange:    backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		FormatOption: models.FormatOption(models.FORMAT_TIMESERIES),
+		Timeout:      30,
+	}
+	config := models.DatasourceSettings{MaxQueryConcurrency: 2}
+
+	res := singleQuery(context.Background(), qm, f, config)
+	if res.Error != nil {
+		t.Fatalf("Unexpected error: %v", res.Error)
+	}
+
+	var names []string
+	for _, frame := range res.Frames {
+		names = append(names, frame.Fields[1].Config.DisplayNameFromDS)
+	}
+	if diff := cmp.Diff(pvs, names); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+	if m := f.maxActive.Load(); m > 2 {
+		t.Errorf("Maximum number of the concurrent requests: got %d, want 2", m)
+	}
+}
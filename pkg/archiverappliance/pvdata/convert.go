@@ -0,0 +1,72 @@
+package pvdata
+
+// ToFloat64 converts a decoded numeric or boolean scalar into float64
+func ToFloat64(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+
+	return 0, false
+}
+
+// ToFloat64Array converts a decoded numeric or boolean array into []float64
+func ToFloat64Array(v interface{}) ([]float64, bool) {
+	switch x := v.(type) {
+	case []bool:
+		return convArray(x), true
+	case []int8:
+		return convArray(x), true
+	case []int16:
+		return convArray(x), true
+	case []int32:
+		return convArray(x), true
+	case []int64:
+		return convArray(x), true
+	case []uint8:
+		return convArray(x), true
+	case []uint16:
+		return convArray(x), true
+	case []uint32:
+		return convArray(x), true
+	case []uint64:
+		return convArray(x), true
+	case []float32:
+		return convArray(x), true
+	case []float64:
+		return x, true
+	}
+
+	return nil, false
+}
+
+func convArray[T any](value []T) []float64 {
+	f := make([]float64, len(value))
+	for i, v := range value {
+		f[i], _ = ToFloat64(v)
+	}
+	return f
+}
@@ -0,0 +1,517 @@
+// Package pvdata decodes the PVData serialization of pvAccess.
+// Archiver Appliance stores the PVs archived over pvAccess as V4 generic bytes,
+// which hold the type description of the structure followed by its data.
+package pvdata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	errShortBuffer     = errors.New("pvdata: unexpected end of data")
+	errUnknownTypeCode = errors.New("pvdata: unknown type code")
+	errUnknownTypeID   = errors.New("pvdata: unknown type id")
+)
+
+// Special type codes used in the type description
+const (
+	nullTypeCode     = 0xFF
+	onlyIDTypeCode   = 0xFE
+	fullWithIDCode   = 0xFD
+	sizeIntFollowing = 0xFE
+	sizeNull         = 0xFF
+)
+
+// Bit fields of the type code
+const (
+	kindMask     = 0xE0
+	kindBoolean  = 0x00
+	kindInteger  = 0x20
+	kindFloating = 0x40
+	kindString   = 0x60
+	kindComplex  = 0x80
+
+	arrayMask     = 0x18
+	arrayScalar   = 0x00
+	arrayVariable = 0x08
+	arrayBounded  = 0x10
+	arrayFixed    = 0x18
+
+	integerUnsigned = 0x04
+	integerSizeMask = 0x03
+
+	floatingFloat  = 0x02
+	floatingDouble = 0x03
+
+	complexMask          = 0x07
+	complexStructure     = 0x00
+	complexUnion         = 0x01
+	complexVariantUnion  = 0x02
+	complexBoundedString = 0x03
+)
+
+// Structure is a decoded PVStructure.
+// Fields are kept in the order of the type description.
+type Structure struct {
+	ID     string
+	Names  []string
+	Values []interface{}
+}
+
+// Get returns the value of the field. Nested fields are separated by dots such as "alarm.severity".
+func (s *Structure) Get(name string) (interface{}, bool) {
+	first, rest, nested := strings.Cut(name, ".")
+
+	for idx, n := range s.Names {
+		if n != first {
+			continue
+		}
+
+		if !nested {
+			return s.Values[idx], true
+		}
+
+		child, ok := s.Values[idx].(*Structure)
+		if !ok {
+			return nil, false
+		}
+		return child.Get(rest)
+	}
+
+	return nil, false
+}
+
+// NormativeType returns the name of the normative type without the namespace and the version,
+// e.g. "NTScalar" for "epics:nt/NTScalar:1.0".
+func (s *Structure) NormativeType() string {
+	id := s.ID
+	if !strings.HasPrefix(id, "epics:nt/") {
+		return ""
+	}
+
+	id = strings.TrimPrefix(id, "epics:nt/")
+	if idx := strings.Index(id, ":"); idx >= 0 {
+		id = id[:idx]
+	}
+
+	return id
+}
+
+type fieldDesc struct {
+	code   byte
+	id     string
+	names  []string
+	fields []*fieldDesc
+	// elem is the element of the structure and union arrays
+	elem *fieldDesc
+	// size is the size of the fixed arrays
+	size int
+}
+
+type decoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+	cache map[int16]*fieldDesc
+}
+
+// Decode decodes the type description and the data of a PVField.
+// Scalars are decoded into the Go types of the same size, arrays into the slices of them,
+// structures into *Structure, and unions into the value of the selected field.
+func Decode(b []byte) (interface{}, error) {
+	d := &decoder{
+		buf:   b,
+		order: binary.BigEndian,
+		cache: make(map[int16]*fieldDesc),
+	}
+
+	desc, err := d.readFieldDesc()
+	if err != nil {
+		return nil, err
+	}
+
+	if desc == nil {
+		return nil, nil
+	}
+
+	return d.readValue(desc)
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return nil, errShortBuffer
+	}
+
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+
+	return b, nil
+}
+
+func (d *decoder) readByte() (byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (d *decoder) readSize() (int, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch b {
+	case sizeNull:
+		return -1, nil
+	case sizeIntFollowing:
+		v, err := d.next(4)
+		if err != nil {
+			return 0, err
+		}
+		return int(int32(d.order.Uint32(v))), nil
+	}
+
+	return int(b), nil
+}
+
+func (d *decoder) readString() (string, error) {
+	n, err := d.readSize()
+	if err != nil {
+		return "", err
+	}
+
+	if n <= 0 {
+		return "", nil
+	}
+
+	b, err := d.next(n)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (d *decoder) readFieldDesc() (*fieldDesc, error) {
+	code, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch code {
+	case nullTypeCode:
+		return nil, nil
+	case onlyIDTypeCode:
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		desc, ok := d.cache[int16(d.order.Uint16(b))]
+		if !ok {
+			return nil, errUnknownTypeID
+		}
+		return desc, nil
+	case fullWithIDCode:
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		desc, err := d.readFieldDesc()
+		if err != nil {
+			return nil, err
+		}
+		d.cache[int16(d.order.Uint16(b))] = desc
+		return desc, nil
+	}
+
+	desc := &fieldDesc{code: code}
+
+	array := code & arrayMask
+	if array == arrayBounded || array == arrayFixed {
+		if desc.size, err = d.readSize(); err != nil {
+			return nil, err
+		}
+	}
+
+	if code&kindMask != kindComplex {
+		if code&kindMask > kindString {
+			return nil, fmt.Errorf("%w: 0x%02x", errUnknownTypeCode, code)
+		}
+		return desc, nil
+	}
+
+	complexType := code & complexMask
+
+	// Arrays of the structures and the unions are followed by the description of the element
+	if array != arrayScalar {
+		if complexType == complexStructure || complexType == complexUnion {
+			if desc.elem, err = d.readFieldDesc(); err != nil {
+				return nil, err
+			}
+		}
+		return desc, nil
+	}
+
+	switch complexType {
+	case complexStructure, complexUnion:
+		if desc.id, err = d.readString(); err != nil {
+			return nil, err
+		}
+
+		n, err := d.readSize()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < n; i++ {
+			name, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			f, err := d.readFieldDesc()
+			if err != nil {
+				return nil, err
+			}
+			desc.names = append(desc.names, name)
+			desc.fields = append(desc.fields, f)
+		}
+	case complexVariantUnion:
+	case complexBoundedString:
+		if desc.size, err = d.readSize(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: 0x%02x", errUnknownTypeCode, code)
+	}
+
+	return desc, nil
+}
+
+func (d *decoder) readValue(desc *fieldDesc) (interface{}, error) {
+	if desc == nil {
+		return nil, nil
+	}
+
+	if desc.code&arrayMask != arrayScalar {
+		return d.readArray(desc)
+	}
+
+	switch desc.code & kindMask {
+	case kindBoolean:
+		b, err := d.readByte()
+		return b != 0, err
+	case kindInteger:
+		return d.readInteger(desc.code)
+	case kindFloating:
+		return d.readFloating(desc.code)
+	case kindString:
+		return d.readString()
+	}
+
+	switch desc.code & complexMask {
+	case complexStructure:
+		s := &Structure{ID: desc.id, Names: desc.names, Values: make([]interface{}, len(desc.fields))}
+		for idx, f := range desc.fields {
+			v, err := d.readValue(f)
+			if err != nil {
+				return nil, err
+			}
+			s.Values[idx] = v
+		}
+		return s, nil
+	case complexUnion:
+		selector, err := d.readSize()
+		if err != nil {
+			return nil, err
+		}
+		if selector < 0 {
+			return nil, nil
+		}
+		if selector >= len(desc.fields) {
+			return nil, fmt.Errorf("pvdata: union selector %d is out of range", selector)
+		}
+		return d.readValue(desc.fields[selector])
+	case complexVariantUnion:
+		f, err := d.readFieldDesc()
+		if err != nil || f == nil {
+			return nil, err
+		}
+		return d.readValue(f)
+	case complexBoundedString:
+		return d.readString()
+	}
+
+	return nil, fmt.Errorf("%w: 0x%02x", errUnknownTypeCode, desc.code)
+}
+
+func (d *decoder) readInteger(code byte) (interface{}, error) {
+	size := 1 << (code & integerSizeMask)
+	b, err := d.next(size)
+	if err != nil {
+		return nil, err
+	}
+
+	unsigned := code&integerUnsigned != 0
+
+	switch size {
+	case 1:
+		if unsigned {
+			return b[0], nil
+		}
+		return int8(b[0]), nil
+	case 2:
+		if unsigned {
+			return d.order.Uint16(b), nil
+		}
+		return int16(d.order.Uint16(b)), nil
+	case 4:
+		if unsigned {
+			return d.order.Uint32(b), nil
+		}
+		return int32(d.order.Uint32(b)), nil
+	}
+
+	if unsigned {
+		return d.order.Uint64(b), nil
+	}
+	return int64(d.order.Uint64(b)), nil
+}
+
+func (d *decoder) readFloating(code byte) (interface{}, error) {
+	switch code & integerSizeMask {
+	case floatingFloat:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(d.order.Uint32(b)), nil
+	case floatingDouble:
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(d.order.Uint64(b)), nil
+	}
+
+	return nil, fmt.Errorf("%w: 0x%02x", errUnknownTypeCode, code)
+}
+
+func (d *decoder) readArray(desc *fieldDesc) (interface{}, error) {
+	n := desc.size
+	if desc.code&arrayMask != arrayFixed {
+		var err error
+		if n, err = d.readSize(); err != nil {
+			return nil, err
+		}
+	}
+
+	if n < 0 {
+		return nil, nil
+	}
+
+	// Check the length before allocating the slice
+	if n > len(d.buf)-d.pos {
+		return nil, errShortBuffer
+	}
+
+	elemDesc := &fieldDesc{code: desc.code &^ arrayMask}
+
+	switch desc.code & kindMask {
+	case kindBoolean:
+		return readArrayOf[bool](d, n, elemDesc)
+	case kindInteger:
+		switch elemDesc.code & (integerUnsigned | integerSizeMask) {
+		case 0x00:
+			return readArrayOf[int8](d, n, elemDesc)
+		case 0x01:
+			return readArrayOf[int16](d, n, elemDesc)
+		case 0x02:
+			return readArrayOf[int32](d, n, elemDesc)
+		case 0x03:
+			return readArrayOf[int64](d, n, elemDesc)
+		case 0x04:
+			return readArrayOf[uint8](d, n, elemDesc)
+		case 0x05:
+			return readArrayOf[uint16](d, n, elemDesc)
+		case 0x06:
+			return readArrayOf[uint32](d, n, elemDesc)
+		}
+		return readArrayOf[uint64](d, n, elemDesc)
+	case kindFloating:
+		if elemDesc.code&integerSizeMask == floatingFloat {
+			return readArrayOf[float32](d, n, elemDesc)
+		}
+		return readArrayOf[float64](d, n, elemDesc)
+	case kindString:
+		return readArrayOf[string](d, n, elemDesc)
+	}
+
+	switch desc.code & complexMask {
+	case complexStructure:
+		vals := make([]*Structure, n)
+		for i := range vals {
+			v, err := d.readNullableElement(desc.elem)
+			if err != nil {
+				return nil, err
+			}
+			vals[i], _ = v.(*Structure)
+		}
+		return vals, nil
+	case complexUnion:
+		vals := make([]interface{}, n)
+		for i := range vals {
+			v, err := d.readNullableElement(desc.elem)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return vals, nil
+	case complexVariantUnion:
+		vals := make([]interface{}, n)
+		for i := range vals {
+			v, err := d.readValue(&fieldDesc{code: kindComplex | complexVariantUnion})
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return vals, nil
+	case complexBoundedString:
+		return readArrayOf[string](d, n, &fieldDesc{code: kindString})
+	}
+
+	return nil, fmt.Errorf("%w: 0x%02x", errUnknownTypeCode, desc.code)
+}
+
+// readNullableElement reads an element of the structure and union arrays, which is preceded by the null flag
+func (d *decoder) readNullableElement(desc *fieldDesc) (interface{}, error) {
+	notNull, err := d.readByte()
+	if err != nil || notNull == 0 {
+		return nil, err
+	}
+
+	if desc == nil {
+		return nil, errShortBuffer
+	}
+
+	return d.readValue(desc)
+}
+
+func readArrayOf[T any](d *decoder, n int, elemDesc *fieldDesc) ([]T, error) {
+	vals := make([]T, n)
+	for i := range vals {
+		v, err := d.readValue(elemDesc)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v.(T)
+	}
+	return vals, nil
+}
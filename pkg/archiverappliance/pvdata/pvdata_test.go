@@ -0,0 +1,216 @@
+package pvdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testBuffer builds the serialized PVData for the tests
+type testBuffer struct {
+	bytes.Buffer
+}
+
+func (b *testBuffer) size(n int) *testBuffer {
+	if n < 254 {
+		b.WriteByte(byte(n))
+		return b
+	}
+	b.WriteByte(sizeIntFollowing)
+	binary.Write(b, binary.BigEndian, int32(n))
+	return b
+}
+
+func (b *testBuffer) str(s string) *testBuffer {
+	b.size(len(s))
+	b.WriteString(s)
+	return b
+}
+
+func (b *testBuffer) code(c byte) *testBuffer {
+	b.WriteByte(c)
+	return b
+}
+
+func (b *testBuffer) put(v interface{}) *testBuffer {
+	binary.Write(b, binary.BigEndian, v)
+	return b
+}
+
+func alarmDesc(b *testBuffer) {
+	b.str("alarm").code(0x80).str("alarm_t").size(3)
+	b.str("severity").code(0x22)
+	b.str("status").code(0x22)
+	b.str("message").code(0x60)
+}
+
+func TestDecode(t *testing.T) {
+	var tests = []struct {
+		name   string
+		input  func() []byte
+		output interface{}
+	}{
+		{
+			name: "NTScalar double",
+			input: func() []byte {
+				b := &testBuffer{}
+				b.code(0x80).str("epics:nt/NTScalar:1.0").size(2)
+				b.str("value").code(0x43)
+				alarmDesc(b)
+				b.put(1.5).put(int32(1)).put(int32(3)).str("HIGH")
+				return b.Bytes()
+			},
+			output: &Structure{
+				ID:    "epics:nt/NTScalar:1.0",
+				Names: []string{"value", "alarm"},
+				Values: []interface{}{
+					1.5,
+					&Structure{ID: "alarm_t", Names: []string{"severity", "status", "message"}, Values: []interface{}{int32(1), int32(3), "HIGH"}},
+				},
+			},
+		},
+		{
+			name: "NTScalarArray of unsigned short with the cached type",
+			input: func() []byte {
+				b := &testBuffer{}
+				b.code(fullWithIDCode).put(int16(1))
+				b.code(0x80).str("epics:nt/NTScalarArray:1.0").size(1)
+				b.str("value").code(0x25 | arrayVariable)
+				b.size(3).put(uint16(1)).put(uint16(2)).put(uint16(65535))
+				return b.Bytes()
+			},
+			output: &Structure{
+				ID:     "epics:nt/NTScalarArray:1.0",
+				Names:  []string{"value"},
+				Values: []interface{}{[]uint16{1, 2, 65535}},
+			},
+		},
+		{
+			name: "NTTable",
+			input: func() []byte {
+				b := &testBuffer{}
+				b.code(0x80).str("epics:nt/NTTable:1.0").size(2)
+				b.str("labels").code(0x60 | arrayVariable)
+				b.str("value").code(0x80).str("").size(2)
+				b.str("name").code(0x60 | arrayVariable)
+				b.str("current").code(0x42 | arrayVariable)
+				b.size(2).str("Name").str("Current")
+				b.size(2).str("A").str("B")
+				b.size(2).put(float32(0.5)).put(float32(1))
+				return b.Bytes()
+			},
+			output: &Structure{
+				ID:    "epics:nt/NTTable:1.0",
+				Names: []string{"labels", "value"},
+				Values: []interface{}{
+					[]string{"Name", "Current"},
+					&Structure{Names: []string{"name", "current"}, Values: []interface{}{[]string{"A", "B"}, []float32{0.5, 1}}},
+				},
+			},
+		},
+		{
+			name: "union, variant union and structure array",
+			input: func() []byte {
+				b := &testBuffer{}
+				b.code(0x80).str("s").size(3)
+				b.str("u").code(0x81).str("").size(2).str("i").code(0x22).str("s").code(0x60)
+				b.str("any").code(0x82)
+				b.str("arr").code(0x80 | arrayVariable).code(0x80).str("e").size(1).str("b").code(0x00)
+				// union selects the string
+				b.size(1).str("selected")
+				// variant union holds a long
+				b.code(0x23).put(int64(-1))
+				// structure array with a null element
+				b.size(2).code(1).code(1).code(0)
+				return b.Bytes()
+			},
+			output: &Structure{
+				ID:    "s",
+				Names: []string{"u", "any", "arr"},
+				Values: []interface{}{
+					"selected",
+					int64(-1),
+					[]*Structure{{ID: "e", Names: []string{"b"}, Values: []interface{}{true}}, nil},
+				},
+			},
+		},
+		{
+			name: "null",
+			input: func() []byte {
+				return []byte{nullTypeCode}
+			},
+			output: nil,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := Decode(testCase.input())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.output, result); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input []byte
+	}{
+		{name: "empty", input: []byte{}},
+		{name: "short data", input: []byte{0x43, 0x00, 0x01}},
+		{name: "unknown type code", input: []byte{0xA0}},
+		{name: "unknown type id", input: []byte{onlyIDTypeCode, 0x00, 0x01}},
+		{name: "array longer than data", input: []byte{0x43 | arrayVariable, 0x10, 0x00}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := Decode(testCase.input); err == nil {
+				t.Errorf("Error should be returned")
+			}
+		})
+	}
+}
+
+func TestNormativeType(t *testing.T) {
+	var tests = []struct {
+		id     string
+		output string
+	}{
+		{id: "epics:nt/NTScalar:1.0", output: "NTScalar"},
+		{id: "epics:nt/NTTable:1.0", output: "NTTable"},
+		{id: "structure", output: ""},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.id, func(t *testing.T) {
+			s := &Structure{ID: testCase.id}
+			if result := s.NormativeType(); result != testCase.output {
+				t.Errorf("got %v, want %v", result, testCase.output)
+			}
+		})
+	}
+}
+
+func TestToFloat64(t *testing.T) {
+	if v, ok := ToFloat64(uint64(math.MaxUint32)); !ok || v != math.MaxUint32 {
+		t.Errorf("got %v, %v", v, ok)
+	}
+	if v, ok := ToFloat64(true); !ok || v != 1 {
+		t.Errorf("got %v, %v", v, ok)
+	}
+	if _, ok := ToFloat64("1"); ok {
+		t.Errorf("string should not be converted")
+	}
+	if v, ok := ToFloat64Array([]int8{-1, 2}); !ok || !cmp.Equal(v, []float64{-1, 2}) {
+		t.Errorf("got %v, %v", v, ok)
+	}
+}
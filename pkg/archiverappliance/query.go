@@ -6,7 +6,8 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -27,43 +28,128 @@ func Query(ctx context.Context, q backend.DataQuery, c Client, config models.Dat
 		return res
 	}
 
+	if q.QueryType == models.QUERY_TYPE_ANNOTATION {
+		res = annotationQuery(ctx, qm, c, config)
+		return res
+	}
+
 	res = singleQuery(ctx, qm, c, config)
 
 	return res
 }
 
 type queryResponse struct {
+	target   string
 	response models.SingleData
 	err      error
 }
 
 func singleQuery(ctx context.Context, qm models.ArchiverQueryModel, client Client, config models.DatasourceSettings) backend.DataResponse {
+	responseData, timeoutNotice, responseErr := fetchTargetData(ctx, qm, client, config)
+
+	// Apply the operator to the raw data if the local processing is selected
+	responseData = functions.ApplyBinning(responseData, qm)
+
+	// Apply Alias to the data
+	var aliasErr error
+	responseData, aliasErr = applyAlias(responseData, qm)
+	if aliasErr != nil {
+		log.DefaultLogger.Warn("Error applying alias")
+	}
+
+	sort.Slice(responseData, func(i, j int) bool { return responseData[i].Name < responseData[j].Name })
+
+	// Apply Functions to the data
+	// The failed functions are reported in a notice because the data is still returned without them
+	var funcNotice *data.Notice
+	responseData, funcErr := functions.ApplyFunctions(responseData, qm)
+	if funcErr != nil {
+		log.DefaultLogger.Warn("Error applying functions", "error", funcErr)
+		funcNotice = &data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     funcErr.Error(),
+		}
+	}
+
+	// Extrapolate data as necessary
+	for idx, data := range responseData {
+		responseData[idx] = dataExtrapol(data, qm)
+	}
+
+	response := backend.DataResponse{}
 
+	// All series are joined into one frame with alignAll, and the values are held with the wide format.
+	// The joined frame isn't updated by the live feature.
+	if qm.AlignAll {
+		response.Frames = models.ToWideFrames(qm.RefId, responseData, qm.FormatOption, qm.FrameFormat == models.FRAME_FORMAT_WIDE)
+	} else {
+		response.Frames = toFrames(responseData, qm, config)
+	}
+
+	response.Frames = appendNotice(response.Frames, timeoutNotice)
+	response.Frames = appendNotice(response.Frames, funcNotice)
+	response.Error = responseErr
+
+	return response
+}
+
+// fetchTargetData retrieves the data of the target PVs with the limited number of workers and the bulk retrieval.
+// The requests are canceled after qm.Timeout, and the PVs which are not retrieved are reported in the returned notice.
+func fetchTargetData(ctx context.Context, qm models.ArchiverQueryModel, client Client, config models.DatasourceSettings) ([]*models.SingleData, *data.Notice, error) {
 	targetPvList := makeTargetPVList(ctx, client, qm.Target, qm.Regex, qm.MaxNumPVs)
 
 	// execute the individual queries
 	responseData := make([]*models.SingleData, 0, len(targetPvList))
-	responsePipe := make(chan queryResponse)
-
-	// Create timeout. If any request routines take longer than timeoutDurationSeconds to execute, they will be dropped.
-	timeoutDurationSeconds := 30 // units are seconds
-	timeoutDuration, _ := time.ParseDuration(strconv.Itoa(timeoutDurationSeconds) + "s")
-	timeoutPipe := time.After(timeoutDuration)
-
-	// create goroutines for individual requests
-	for _, targetPv := range targetPvList {
-		go func(targetPv string, pipe chan queryResponse) {
-			parsedResponse, err := client.ExecuteSingleQuery(ctx, targetPv, qm)
-			pipe <- queryResponse{response: parsedResponse, err: err}
-		}(targetPv, responsePipe)
+	// The pipe is buffered so that the workers don't block after the timeout
+	responsePipe := make(chan queryResponse, len(targetPvList))
+
+	// Create timeout. If any requests take longer than the timeout, they are canceled and the PVs are reported in the notice.
+	ctx, cancel := context.WithTimeout(withQuerySession(ctx), time.Duration(qm.Timeout)*time.Second)
+	defer cancel()
+
+	// create a limited number of workers for the requests of the PV batches.
+	// The PVs which are not retrieved in bulk are queued again one by one.
+	batches := splitTargets(targetPvList, BATCH_MAX_PVS)
+	targetPipe := make(chan []string, len(batches)+len(targetPvList))
+	var jobs sync.WaitGroup
+	jobs.Add(len(batches))
+	for _, batch := range batches {
+		targetPipe <- batch
+	}
+	go func() {
+		jobs.Wait()
+		close(targetPipe)
+	}()
+
+	for range queryConcurrency(config, len(targetPvList)) {
+		go func(pipe chan queryResponse) {
+			for batch := range targetPipe {
+				rest := fetchTargets(ctx, client, batch, qm, pipe)
+				jobs.Add(len(rest))
+				for _, targetPv := range rest {
+					targetPipe <- []string{targetPv}
+				}
+				jobs.Done()
+			}
+		}(responsePipe)
 	}
 
 	// Collect responses from the request goroutines
 	var responseErr error
+	pending := make(map[string]bool, len(targetPvList))
+	for _, targetPv := range targetPvList {
+		pending[targetPv] = true
+	}
 responseCollector:
 	for range targetPvList {
 		select {
 		case response := <-responsePipe:
+			// The request was canceled by the timeout
+			if errors.Is(response.err, context.DeadlineExceeded) {
+				continue
+			}
+			delete(pending, response.target)
+
 			if response.err != nil {
 				if qm.IgnoreEmptyErr && errors.Is(response.err, errEmptyResponse) {
 					continue
@@ -76,36 +162,48 @@ responseCollector:
 				continue
 			}
 			responseData = append(responseData, &response.response)
-		case <-timeoutPipe:
-			log.DefaultLogger.Warn("Timeout limit for query has been reached")
+		case <-ctx.Done():
 			break responseCollector
 		}
 	}
 
-	// Apply Alias to the data
-	var aliasErr error
-	responseData, aliasErr = applyAlias(responseData, qm)
-	if aliasErr != nil {
-		log.DefaultLogger.Warn("Error applying alias")
+	var timeoutNotice *data.Notice
+	if len(pending) > 0 {
+		timedOut := make([]string, 0, len(pending))
+		for targetPv := range pending {
+			timedOut = append(timedOut, targetPv)
+		}
+		sort.Strings(timedOut)
+
+		log.DefaultLogger.Warn("Timeout limit for query has been reached", "timeout", qm.Timeout, "pvs", timedOut)
+		timeoutNotice = &data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Data is incomplete. Query timed out after %d seconds for PVs: %s", qm.Timeout, strings.Join(timedOut, ", ")),
+		}
 	}
 
-	sort.Slice(responseData, func(i, j int) bool { return responseData[i].Name < responseData[j].Name })
+	return responseData, timeoutNotice, responseErr
+}
 
-	// Apply Functions to the data
-	var funcErr error
-	responseData, funcErr = functions.ApplyFunctions(responseData, qm)
-	if funcErr != nil {
-		log.DefaultLogger.Warn("Error applying functions")
+// appendNotice appends the notice to all frames. Panels show the notice to tell that the series are missing.
+func appendNotice(frames data.Frames, notice *data.Notice) data.Frames {
+	if notice == nil {
+		return frames
 	}
 
-	// Extrapolate data as necessary
-	for idx, data := range responseData {
-		responseData[idx] = dataExtrapol(data, qm)
+	if len(frames) == 0 {
+		frames = append(frames, data.NewFrame(""))
+	}
+	for _, frame := range frames {
+		frame.AppendNotices(*notice)
 	}
 
-	response := backend.DataResponse{}
+	return frames
+}
 
-	// for each query response, compile the data into response.Frames
+// toFrames compiles each query response into a frame
+func toFrames(responseData []*models.SingleData, qm models.ArchiverQueryModel, config models.DatasourceSettings) data.Frames {
+	frames := make(data.Frames, 0, len(responseData))
 	for _, singleResponse := range responseData {
 		frame := singleResponse.ToFrame(qm.FormatOption)
 
@@ -113,18 +211,72 @@ responseCollector:
 			channelFrame, err := createLiveChannel(singleResponse.PVname, frame, config.UID)
 			if err != nil {
 				log.DefaultLogger.Warn("Error applying live channel:", err)
+			} else if frame.Meta != nil {
+				frame.Meta.Channel = channelFrame.Channel
 			} else {
 				frame.SetMeta(channelFrame)
 			}
 		}
 
-		// add the frames to the response
-		response.Frames = append(response.Frames, frame)
+		frames = append(frames, frame)
 	}
 
-	response.Error = responseErr
+	return frames
+}
 
-	return response
+// splitTargets splits the PVs into the batches of at most size PVs
+func splitTargets(targets []string, size int) [][]string {
+	var batches [][]string
+	for start := 0; start < len(targets); start += size {
+		end := min(start+size, len(targets))
+		batches = append(batches, targets[start:end])
+	}
+
+	return batches
+}
+
+// fetchTargets retrieves the PVs and sends a response for each PV to the pipe.
+// Several PVs are retrieved in one bulk request and the PVs which are not in the bulk response are returned,
+// e.g. all PVs if the appliance doesn't support the bulk retrieval or the PVs without data or the aliases.
+func fetchTargets(ctx context.Context, client Client, targets []string, qm models.ArchiverQueryModel, pipe chan<- queryResponse) []string {
+	if len(targets) == 1 {
+		parsedResponse, err := client.ExecuteSingleQuery(ctx, targets[0], qm)
+		pipe <- queryResponse{target: targets[0], response: parsedResponse, err: err}
+		return nil
+	}
+
+	result, err := client.ExecuteBatchQuery(ctx, targets, qm)
+	if errors.Is(err, errBatchUnsupported) {
+		return targets
+	}
+	if err != nil {
+		for _, target := range targets {
+			pipe <- queryResponse{target: target, err: err}
+		}
+		return nil
+	}
+
+	var rest []string
+	for _, target := range targets {
+		sD, ok := result[target]
+		if !ok {
+			rest = append(rest, target)
+			continue
+		}
+		pipe <- queryResponse{target: target, response: sD}
+	}
+
+	return rest
+}
+
+// queryConcurrency returns the number of the workers for a query
+func queryConcurrency(config models.DatasourceSettings, numPVs int) int {
+	limit := config.MaxQueryConcurrency
+	if limit <= 0 {
+		limit = DEFAULT_MAX_QUERY_CONCURRENCY
+	}
+
+	return min(limit, numPVs)
 }
 
 func applyAlias(sD []*models.SingleData, qm models.ArchiverQueryModel) ([]*models.SingleData, error) {
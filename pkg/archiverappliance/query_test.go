@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,6 +23,8 @@ func (f fakeClient) FetchRegexTargetPVs(ctx context.Context, regex string, limit
 		return []string{"PV:NAME1"}, nil
 	} else if regex == ".*2" {
 		return []string{"PV:NAME2"}, nil
+	} else if regex == "invalid" {
+		return nil, errors.New("test error")
 	} else {
 		return []string{}, nil
 	}
@@ -41,6 +44,12 @@ func (f fakeClient) ExecuteSingleQuery(ctx context.Context, target string, qm mo
 	case "string":
 		s := []string{"test1", "test2", "test3"}
 		v = &models.Strings{Times: testhelper.TimeArrayHelper(0, 3), Values: s}
+	case "alarm":
+		e := models.NewSevirityEnums(4)
+		for idx, sevr := range []int16{0, 2, 2, 1} {
+			e.Append(sevr, testhelper.TimeHelper(idx))
+		}
+		v = e
 	case "PV:NAME1":
 		values := testhelper.InitFloat64SlicePointer([]float64{0, 1, 2})
 		v = &models.Scalars{Times: testhelper.TimeArrayHelper(0, 3), Values: values}
@@ -57,6 +66,14 @@ func (f fakeClient) ExecuteSingleQuery(ctx context.Context, target string, qm mo
 	return sd, nil
 }
 
+func (f fakeClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	return nil, errBatchUnsupported
+}
+
+func (f fakeClient) FetchVersion(ctx context.Context) (string, error) {
+	return "Archiver Appliance Version fake", nil
+}
+
 func TestQuery(t *testing.T) {
 	TIME_FORMAT := "2006-01-02T15:04:05.000-07:00"
 	var tests = []struct {
@@ -68,6 +85,7 @@ func TestQuery(t *testing.T) {
 	}{
 		{
 			name: "test",
+			ctx:  context.Background(),
 			req: backend.DataQuery{
 				Interval: testhelper.MultiReturnHelperParseDuration(time.ParseDuration("0s")),
 				JSON: json.RawMessage(`{
@@ -165,6 +183,7 @@ func TestQuery(t *testing.T) {
 		},
 		{
 			name: "test without sour function",
+			ctx:  context.Background(),
 			req: backend.DataQuery{
 				Interval: testhelper.MultiReturnHelperParseDuration(time.ParseDuration("0s")),
 				JSON: json.RawMessage(`{
@@ -239,6 +258,7 @@ func TestQuery(t *testing.T) {
 		},
 		{
 			name: "test string response",
+			ctx:  context.Background(),
 			req: backend.DataQuery{
 				Interval: testhelper.MultiReturnHelperParseDuration(time.ParseDuration("0s")),
 				JSON: json.RawMessage(`{
@@ -345,6 +365,7 @@ func TestLiveQuery(t *testing.T) {
 	}{
 		{
 			name: "Live Test",
+			ctx:  context.Background(),
 			req: backend.DataQuery{
 				Interval: testhelper.MultiReturnHelperParseDuration(time.ParseDuration("0s")),
 				JSON: json.RawMessage(`{
@@ -401,6 +422,7 @@ func TestQueryWithInvalidResponse(t *testing.T) {
 	}{
 		{
 			name: "invalid response",
+			ctx:  context.Background(),
 			req: backend.DataQuery{
 				Interval: testhelper.MultiReturnHelperParseDuration(time.ParseDuration("0s")),
 				JSON: json.RawMessage(`{
@@ -458,6 +480,7 @@ func TestQueryWithEmptyResponse(t *testing.T) {
 	}{
 		{
 			name: "invalid response",
+			ctx:  context.Background(),
 			req: backend.DataQuery{
 				Interval: testhelper.MultiReturnHelperParseDuration(time.ParseDuration("0s")),
 				JSON: json.RawMessage(`{
@@ -796,3 +819,234 @@ func TestMakeTargetPVList(t *testing.T) {
 		})
 	}
 }
+
+// batchClient returns the data of the PVs except for the missing ones in the bulk request
+type batchClient struct {
+	fakeClient
+	missing string
+	err     error
+}
+
+func (f *batchClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	result := make(map[string]models.SingleData)
+	for _, target := range targets {
+		if target != f.missing {
+			result[target] = models.SingleData{Name: target, PVname: target, Values: &models.Scalars{}}
+		}
+	}
+	return result, nil
+}
+
+func TestFetchTargets(t *testing.T) {
+	testErr := errors.New("test error")
+	var tests = []struct {
+		name      string
+		client    *batchClient
+		targets   []string
+		responses int
+		errs      int
+		rest      []string
+	}{
+		{name: "bulk request", client: &batchClient{}, targets: []string{"PV:A", "PV:B"}, responses: 2},
+		{name: "missing PV", client: &batchClient{missing: "PV:B"}, targets: []string{"PV:A", "PV:B"}, responses: 1, rest: []string{"PV:B"}},
+		{name: "unsupported", client: &batchClient{err: errBatchUnsupported}, targets: []string{"PV:A", "PV:B"}, rest: []string{"PV:A", "PV:B"}},
+		{name: "error", client: &batchClient{err: testErr}, targets: []string{"PV:A", "PV:B"}, responses: 2, errs: 2},
+		{name: "single PV", client: &batchClient{}, targets: []string{"PV:A"}, responses: 1},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			pipe := make(chan queryResponse, len(testCase.targets))
+			rest := fetchTargets(context.Background(), testCase.client, testCase.targets, models.ArchiverQueryModel{}, pipe)
+			close(pipe)
+
+			responses, errs := 0, 0
+			for r := range pipe {
+				responses++
+				if r.err != nil {
+					errs++
+				}
+			}
+			if responses != testCase.responses || errs != testCase.errs {
+				t.Errorf("got %d responses and %d errors, want %d and %d", responses, errs, testCase.responses, testCase.errs)
+			}
+			if diff := cmp.Diff(testCase.rest, rest); diff != "" {
+				t.Errorf("Rest PVs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSplitTargets(t *testing.T) {
+	result := splitTargets([]string{"A", "B", "C", "D", "E"}, 2)
+	if diff := cmp.Diff([][]string{{"A", "B"}, {"C", "D"}, {"E"}}, result); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// slowClient blocks the requests of the slow PVs until the context is canceled
+type slowClient struct {
+	fakeClient
+	canceled chan string
+}
+
+func (f slowClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	if strings.HasPrefix(target, "slow") {
+		<-ctx.Done()
+		f.canceled <- target
+		return models.SingleData{}, ctx.Err()
+	}
+	return f.fakeClient.ExecuteSingleQuery(ctx, target, qm)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	f := slowClient{canceled: make(chan string, 2)}
+	qm := models.ArchiverQueryModel{
+		Target:       "(PV1|slow2|slow1)",
+		TimeRange:    backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		FormatOption: models.FormatOption(models.FORMAT_TIMESERIES),
+		Timeout:      1,
+	}
+
+	res := singleQuery(context.Background(), qm, f, models.DatasourceSettings{})
+	if res.Error != nil {
+		t.Fatalf("Unexpected error: %v", res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(res.Frames))
+	}
+
+	wantNotices := []data.Notice{{
+		Severity: data.NoticeSeverityWarning,
+		Text:     "Data is incomplete. Query timed out after 1 seconds for PVs: slow1, slow2",
+	}}
+	if diff := cmp.Diff(wantNotices, res.Frames[0].Meta.Notices); diff != "" {
+		t.Errorf("Notices mismatch (-want +got):\n%s", diff)
+	}
+
+	// The pending requests are canceled
+	for range 2 {
+		select {
+		case <-f.canceled:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Request was not canceled")
+		}
+	}
+}
+
+func TestQueryTimeoutWithoutData(t *testing.T) {
+	f := slowClient{canceled: make(chan string, 1)}
+	qm := models.ArchiverQueryModel{
+		Target:    "slow",
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		Timeout:   1,
+	}
+
+	res := singleQuery(context.Background(), qm, f, models.DatasourceSettings{})
+	if len(res.Frames) != 1 || len(res.Frames[0].Fields) != 0 {
+		t.Fatalf("An empty frame should be returned: %v", res.Frames)
+	}
+	if len(res.Frames[0].Meta.Notices) != 1 {
+		t.Errorf("Notice should be set: %v", res.Frames[0].Meta)
+	}
+}
+
+func TestQueryFunctionError(t *testing.T) {
+	qm := models.ArchiverQueryModel{
+		Target:       "PV:NAME1",
+		TimeRange:    backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(5)},
+		FormatOption: models.FormatOption(models.FORMAT_TIMESERIES),
+		Timeout:      10,
+		Functions: []models.FunctionDescriptorQueryModel{
+			{
+				Def: models.FuncDefQueryModel{
+					Category:      "Transform",
+					DefaultParams: testhelper.InitRawMsg(`["1m", "step"]`),
+					Name:          "resample",
+					Params: []models.FuncDefParamQueryModel{
+						{Name: "interval", Type: "string"},
+						{Name: "method", Type: "string"},
+					},
+				},
+				Params: []string{"1ns", "step"},
+			},
+		},
+	}
+
+	res := singleQuery(context.Background(), qm, fakeClient{}, models.DatasourceSettings{})
+	if res.Error != nil {
+		t.Fatalf("Unexpected error: %v", res.Error)
+	}
+	if len(res.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(res.Frames))
+	}
+
+	// The data is returned without the failed function
+	wantNotices := []data.Notice{{
+		Severity: data.NoticeSeverityWarning,
+		Text:     "function resample has failed: interval 1ns is too short for the time range",
+	}}
+	if diff := cmp.Diff(wantNotices, res.Frames[0].Meta.Notices); diff != "" {
+		t.Errorf("Notices mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryAlignAll(t *testing.T) {
+	qm := models.ArchiverQueryModel{
+		Target:          "(PV:NAME1|PV:NAME2|string)",
+		RefId:           "A",
+		TimeRange:       backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(5)},
+		FormatOption:    models.FormatOption(models.FORMAT_TIMESERIES),
+		DisableExtrapol: true,
+		AlignAll:        true,
+		Timeout:         10,
+	}
+
+	res := singleQuery(context.Background(), qm, fakeClient{}, models.DatasourceSettings{})
+	if res.Error != nil {
+		t.Fatalf("Unexpected error: %v", res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(res.Frames))
+	}
+
+	var names []string
+	for _, f := range res.Frames[0].Fields {
+		names = append(names, f.Name)
+	}
+	if diff := cmp.Diff([]string{"time", "PV:NAME1", "PV:NAME2"}, names); diff != "" {
+		t.Errorf("Fields mismatch (-want +got):\n%s", diff)
+	}
+	if res.Frames[0].Name != "A" || res.Frames[1].Name != "string" {
+		t.Errorf("Unexpected frame names: %v, %v", res.Frames[0].Name, res.Frames[1].Name)
+	}
+}
+
+func TestQueryWideFrameFormat(t *testing.T) {
+	qm := models.ArchiverQueryModel{
+		Target:       "(PV:NAME1|PV:NAME2)",
+		RefId:        "A",
+		TimeRange:    backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(5)},
+		FormatOption: models.FormatOption(models.FORMAT_TIMESERIES),
+		AlignAll:     true,
+		FrameFormat:  models.FRAME_FORMAT_WIDE,
+		Timeout:      10,
+	}
+
+	res := singleQuery(context.Background(), qm, fakeClient{}, models.DatasourceSettings{})
+	if res.Error != nil {
+		t.Fatalf("Unexpected error: %v", res.Error)
+	}
+	if len(res.Frames) != 1 || len(res.Frames[0].Fields) != 3 {
+		t.Fatalf("One wide frame should be returned: %v", res.Frames)
+	}
+
+	// The extrapolated samples share the timestamp at the end of the time range
+	if l := res.Frames[0].Fields[0].Len(); l != 4 {
+		t.Errorf("got %d rows, want 4", l)
+	}
+}
@@ -0,0 +1,137 @@
+package archiverappliance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const (
+	RESOURCE_PVS_DEFAULT_LIMIT = 100
+)
+
+type VariableItem struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+func PVNamesHandler(client Client) http.HandlerFunc {
+	// Handle "/pvs?regex=...&limit=...&pattern=..." and return the matching PV names as variable items
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		params := r.URL.Query()
+
+		limit := RESOURCE_PVS_DEFAULT_LIMIT
+		if l := params.Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.Atoi(l)
+			if err != nil || limit <= 0 {
+				http.Error(w, fmt.Sprintf("invalid limit: %q", l), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var rep *regexp.Regexp
+		if p := params.Get("pattern"); p != "" {
+			var err error
+			rep, err = regexp.Compile(p)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid pattern: %s", err.Error()), http.StatusBadRequest)
+				return
+			}
+		}
+
+		items, err := findPVNames(r.Context(), client, params.Get("regex"), limit, rep)
+		if err != nil {
+			log.DefaultLogger.Warn("Failed to fetch PV names", "Error", err)
+			http.Error(w, fmt.Sprintf("failed to fetch PV names: %s", err.Error()), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(items); err != nil {
+			log.DefaultLogger.Warn("Failed to write PV names", "Error", err)
+		}
+	}
+}
+
+func findPVNames(ctx context.Context, client Client, regex string, limit int, rep *regexp.Regexp) ([]VariableItem, error) {
+	items := make([]VariableItem, 0)
+	if regex == "" {
+		return items, nil
+	}
+
+	// The errors of the appliance are returned unlike makeTargetPVList, so that an outage isn't shown as no PVs
+	var pvnames []string
+	for _, v := range isolateBasicQuery(regex) {
+		pvs, err := client.FetchRegexTargetPVs(ctx, v, limit)
+		if err != nil {
+			return nil, err
+		}
+		pvnames = append(pvnames, pvs...)
+	}
+
+	m := map[string]bool{}
+	for _, pvname := range pvnames {
+		item, ok := extractVariableItem(pvname, rep)
+		if !ok || m[item.Value] {
+			continue
+		}
+		m[item.Value] = true
+		items = append(items, item)
+
+		if len(items) >= limit {
+			break
+		}
+	}
+
+	return items, nil
+}
+
+func extractVariableItem(pvname string, rep *regexp.Regexp) (VariableItem, bool) {
+	// Without pattern, PV name is used for both text and value
+	if rep == nil {
+		return VariableItem{Text: pvname, Value: pvname}, true
+	}
+
+	match := rep.FindStringSubmatch(pvname)
+	if match == nil {
+		return VariableItem{}, false
+	}
+
+	// Named groups "text" and "value" take precedence over the first capture group
+	var text, value string
+	for idx, name := range rep.SubexpNames() {
+		switch name {
+		case "text":
+			text = match[idx]
+		case "value":
+			value = match[idx]
+		}
+	}
+
+	if text == "" && value == "" {
+		if len(match) > 1 {
+			text = match[1]
+		} else {
+			text = pvname
+		}
+	}
+	if text == "" {
+		text = value
+	}
+	if value == "" {
+		value = text
+	}
+
+	return VariableItem{Text: text, Value: value}, true
+}
@@ -0,0 +1,96 @@
+package archiverappliance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPVNamesHandler(t *testing.T) {
+	var tests = []struct {
+		name   string
+		params url.Values
+		status int
+		output []VariableItem
+	}{
+		{
+			name:   "regex with multiple phrases",
+			params: url.Values{"regex": []string{".*(1|2)"}},
+			status: http.StatusOK,
+			output: []VariableItem{{Text: "PV:NAME1", Value: "PV:NAME1"}, {Text: "PV:NAME2", Value: "PV:NAME2"}},
+		},
+		{
+			name:   "limit",
+			params: url.Values{"regex": []string{".*(1|2)"}, "limit": []string{"1"}},
+			status: http.StatusOK,
+			output: []VariableItem{{Text: "PV:NAME1", Value: "PV:NAME1"}},
+		},
+		{
+			name:   "first capture group",
+			params: url.Values{"regex": []string{".*(1|2)"}, "pattern": []string{"PV:(.*)"}},
+			status: http.StatusOK,
+			output: []VariableItem{{Text: "NAME1", Value: "NAME1"}, {Text: "NAME2", Value: "NAME2"}},
+		},
+		{
+			name:   "named capture groups",
+			params: url.Values{"regex": []string{".*(1|2)"}, "pattern": []string{"(?P<value>PV):NAME(?P<text>.*)"}},
+			status: http.StatusOK,
+			output: []VariableItem{{Text: "1", Value: "PV"}},
+		},
+		{
+			name:   "unmatched names are dropped",
+			params: url.Values{"regex": []string{".*(1|2)"}, "pattern": []string{"NAME2$"}},
+			status: http.StatusOK,
+			output: []VariableItem{{Text: "PV:NAME2", Value: "PV:NAME2"}},
+		},
+		{
+			name:   "empty regex",
+			params: url.Values{},
+			status: http.StatusOK,
+			output: []VariableItem{},
+		},
+		{
+			name:   "invalid limit",
+			params: url.Values{"regex": []string{".*1"}, "limit": []string{"a"}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "appliance error",
+			params: url.Values{"regex": []string{"invalid"}},
+			status: http.StatusBadGateway,
+		},
+		{
+			name:   "invalid pattern",
+			params: url.Values{"regex": []string{".*1"}, "pattern": []string{"("}},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/pvs?"+testCase.params.Encode(), nil)
+			rec := httptest.NewRecorder()
+
+			PVNamesHandler(fakeClient{}).ServeHTTP(rec, req)
+
+			if rec.Code != testCase.status {
+				t.Fatalf("Status differs - Wanted: %v Got: %v", testCase.status, rec.Code)
+			}
+			if testCase.status != http.StatusOK {
+				return
+			}
+
+			var result []VariableItem
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if diff := cmp.Diff(testCase.output, result); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const (
+	DEFAULT_MAX_RETRIES     = 3
+	DEFAULT_RETRY_MAX_DELAY = 10 * time.Second
+	// Delay before the first retry. The delay is doubled for each retry.
+	RETRY_BASE_DELAY = 500 * time.Millisecond
+)
+
+// RetryOptions configures the retry of the requests to the appliance
+type RetryOptions struct {
+	// MaxRetries is the maximum number of the retries. No retry is made if it is 0.
+	MaxRetries int
+	// MaxDelay is the maximum delay between the retries
+	MaxDelay time.Duration
+}
+
+// retryTransport retries the idempotent requests on the transient failures
+// such as connection errors, timeouts and 502, 503 or 504 responses.
+// The delay grows exponentially with jitter, and Retry-After header of the response is honored.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	maxDelay   time.Duration
+	baseDelay  time.Duration
+}
+
+func newRetryTransport(next http.RoundTripper, opts RetryOptions) *retryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	maxDelay := opts.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DEFAULT_RETRY_MAX_DELAY
+	}
+
+	return &retryTransport{
+		next:       next,
+		maxRetries: opts.MaxRetries,
+		maxDelay:   maxDelay,
+		baseDelay:  RETRY_BASE_DELAY,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		res, err := t.next.RoundTrip(req)
+		if attempt >= t.maxRetries || !isRetryable(ctx, res, err) {
+			return res, err
+		}
+
+		delay := t.backoff(attempt)
+		if res != nil {
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				// The appliance won't be ready within the acceptable delay
+				if retryAfter > t.maxDelay {
+					return res, err
+				}
+				delay = max(delay, retryAfter)
+			}
+
+			// Drain the body to reuse the connection
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		log.DefaultLogger.Debug("Retrying request", "url", req.URL.String(), "attempt", attempt+1, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the retry with the jitter in [d/2, d]
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.baseDelay << attempt
+	if d > t.maxDelay || d <= 0 {
+		d = t.maxDelay
+	}
+
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+func isRetryable(ctx context.Context, res *http.Response, err error) bool {
+	// The request was canceled by the caller
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// parseRetryAfter parses Retry-After header which is either the seconds or the HTTP date
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(t.Sub(now), 0), true
+}
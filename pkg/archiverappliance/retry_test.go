@@ -0,0 +1,176 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingServer fails the first failures requests with the status code and succeeds after that
+func failingServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) > failures {
+				w.Write([]byte("ok"))
+				return
+			}
+
+			// Connection error
+			if status == 0 {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Fatalf("Failed to hijack the connection: %v", err)
+				}
+				conn.Close()
+				return
+			}
+
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func newTestRetryClient(maxRetries int, maxDelay time.Duration) *http.Client {
+	transport := newRetryTransport(nil, RetryOptions{MaxRetries: maxRetries, MaxDelay: maxDelay})
+	transport.baseDelay = time.Millisecond
+	return &http.Client{Transport: transport}
+}
+
+func TestRetryTransport(t *testing.T) {
+	var tests = []struct {
+		name       string
+		failures   int32
+		status     int
+		maxRetries int
+		requests   int32
+		success    bool
+	}{
+		{name: "503 recovers", failures: 2, status: http.StatusServiceUnavailable, maxRetries: 3, requests: 3, success: true},
+		{name: "502 recovers", failures: 1, status: http.StatusBadGateway, maxRetries: 3, requests: 2, success: true},
+		{name: "504 recovers", failures: 1, status: http.StatusGatewayTimeout, maxRetries: 3, requests: 2, success: true},
+		{name: "connection error recovers", failures: 2, status: 0, maxRetries: 3, requests: 3, success: true},
+		{name: "retries are exhausted", failures: 5, status: http.StatusServiceUnavailable, maxRetries: 3, requests: 4, success: false},
+		{name: "retry is disabled", failures: 1, status: http.StatusServiceUnavailable, maxRetries: 0, requests: 1, success: false},
+		{name: "404 is not retried", failures: 1, status: http.StatusNotFound, maxRetries: 3, requests: 1, success: false},
+		{name: "500 is not retried", failures: 1, status: http.StatusInternalServerError, maxRetries: 3, requests: 1, success: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			server, requests := failingServer(t, testCase.failures, testCase.status, nil)
+			client := newTestRetryClient(testCase.maxRetries, time.Second)
+
+			res, err := archiverSingleQuery(context.Background(), server.URL, client)
+			if testCase.success {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				res.Close()
+			} else if err == nil {
+				t.Errorf("Error should be returned")
+			}
+
+			if n := requests.Load(); n != testCase.requests {
+				t.Errorf("got %d requests, want %d", n, testCase.requests)
+			}
+		})
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	t.Run("wait for Retry-After", func(t *testing.T) {
+		server, requests := failingServer(t, 1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"1"}})
+		client := newTestRetryClient(3, 2*time.Second)
+
+		start := time.Now()
+		res, err := archiverSingleQuery(context.Background(), server.URL, client)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		res.Close()
+
+		if elapsed := time.Since(start); elapsed < time.Second {
+			t.Errorf("Retry-After is not honored: %v", elapsed)
+		}
+		if n := requests.Load(); n != 2 {
+			t.Errorf("got %d requests, want 2", n)
+		}
+	})
+
+	t.Run("Retry-After exceeds max delay", func(t *testing.T) {
+		server, requests := failingServer(t, 1, http.StatusServiceUnavailable, http.Header{"Retry-After": {"60"}})
+		client := newTestRetryClient(3, time.Second)
+
+		if _, err := archiverSingleQuery(context.Background(), server.URL, client); err == nil {
+			t.Errorf("Error should be returned")
+		}
+		if n := requests.Load(); n != 1 {
+			t.Errorf("got %d requests, want 1", n)
+		}
+	})
+}
+
+func TestRetryTransportCanceled(t *testing.T) {
+	server, _ := failingServer(t, 5, http.StatusServiceUnavailable, http.Header{"Retry-After": {"2"}})
+	client := newTestRetryClient(3, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := archiverSingleQuery(ctx, server.URL, client)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Retry should stop when the context is done: %v", elapsed)
+	}
+}
+
+func TestRegexQueryRetry(t *testing.T) {
+	server, requests := failingServer(t, 1, http.StatusServiceUnavailable, nil)
+	client := newTestRetryClient(3, time.Second)
+
+	if _, err := archiverRegexQuery(context.Background(), server.URL, client); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		input  string
+		output time.Duration
+		ok     bool
+	}{
+		{input: "", ok: false},
+		{input: "5", output: 5 * time.Second, ok: true},
+		{input: "-1", ok: false},
+		{input: "Mon, 01 Jan 2024 00:00:10 GMT", output: 10 * time.Second, ok: true},
+		{input: "Sun, 31 Dec 2023 23:59:00 GMT", output: 0, ok: true},
+		{input: "soon", ok: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.input, func(t *testing.T) {
+			result, ok := parseRetryAfter(testCase.input, now)
+			if result != testCase.output || ok != testCase.ok {
+				t.Errorf("got %v, %v, want %v, %v", result, ok, testCase.output, testCase.ok)
+			}
+		})
+	}
+}
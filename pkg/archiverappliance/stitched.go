@@ -0,0 +1,204 @@
+package archiverappliance
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+// StitchedClient retrieves the data before the cutover from the secondary source, e.g. a legacy archive,
+// and the data after the cutover from the primary appliance.
+// The data of both sources is stitched into one series.
+type StitchedClient struct {
+	Client
+	secondary Client
+	cutover   time.Time
+}
+
+func NewStitchedClient(primary Client, secondary Client, cutover time.Time) *StitchedClient {
+	return &StitchedClient{
+		Client:    primary,
+		secondary: secondary,
+		cutover:   cutover,
+	}
+}
+
+// FetchRegexTargetPVs searches the PVs in both sources so that the PVs only in the secondary source are also found
+func (c *StitchedClient) FetchRegexTargetPVs(ctx context.Context, regex string, limit int) ([]string, error) {
+	results := fetchAll(ctx, []FederatedMember{{Client: c.Client}, {Client: c.secondary}}, func(ctx context.Context, client Client) ([]string, error) {
+		return client.FetchRegexTargetPVs(ctx, regex, limit)
+	})
+
+	primary, secondary := results[0], results[1]
+	if primary.err != nil {
+		return nil, primary.err
+	}
+	if secondary.err != nil {
+		log.DefaultLogger.Warn("Failed to search PVs in the secondary source", "Error", secondary.err)
+		return primary.value, nil
+	}
+
+	pvs := primary.value
+	seen := make(map[string]bool, len(pvs))
+	for _, pv := range pvs {
+		seen[pv] = true
+	}
+	for _, pv := range secondary.value {
+		if !seen[pv] {
+			seen[pv] = true
+			pvs = append(pvs, pv)
+		}
+	}
+
+	if limit > 0 && len(pvs) > limit {
+		pvs = pvs[:limit]
+	}
+
+	return pvs, nil
+}
+
+// source tells which sources the time range of the query covers
+func (c *StitchedClient) source(qm models.ArchiverQueryModel) (primary bool, secondary bool) {
+	if qm.LiveOnly {
+		return true, false
+	}
+
+	// last operator retrieves the data at the end of the time range
+	from := qm.TimeRange.From
+	if qm.Operator == "last" {
+		from = qm.TimeRange.To
+	}
+
+	return qm.TimeRange.To.After(c.cutover), from.Before(c.cutover)
+}
+
+// split returns the query models of the primary and the secondary source divided at the cutover
+func (c *StitchedClient) split(qm models.ArchiverQueryModel) (models.ArchiverQueryModel, models.ArchiverQueryModel) {
+	pqm, sqm := qm, qm
+	pqm.TimeRange.From = c.cutover
+	sqm.TimeRange.To = c.cutover
+
+	return pqm, sqm
+}
+
+func (c *StitchedClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	primary, secondary := c.source(qm)
+	if !secondary {
+		return c.Client.ExecuteSingleQuery(ctx, target, qm)
+	}
+	if !primary {
+		return c.secondary.ExecuteSingleQuery(ctx, target, qm)
+	}
+
+	pqm, sqm := c.split(qm)
+
+	var wg sync.WaitGroup
+	var sD models.SingleData
+	var sErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sD, sErr = c.secondary.ExecuteSingleQuery(ctx, target, sqm)
+	}()
+	pD, pErr := c.Client.ExecuteSingleQuery(ctx, target, pqm)
+	wg.Wait()
+
+	switch {
+	case pErr != nil && sErr != nil:
+		return pD, pErr
+	case sErr != nil:
+		if !errors.Is(sErr, errEmptyResponse) {
+			log.DefaultLogger.Warn("Failed to retrieve the data from the secondary source", "target", target, "Error", sErr)
+		}
+		return pD, nil
+	case pErr != nil:
+		if !errors.Is(pErr, errEmptyResponse) {
+			return pD, pErr
+		}
+		return sD, nil
+	}
+
+	return c.stitch(pD, sD), nil
+}
+
+// ExecuteBatchQuery retrieves the PVs from both sources in bulk if the time range covers the cutover.
+// The PVs which are not in both results are not in the result so that they are retrieved one by one.
+func (c *StitchedClient) ExecuteBatchQuery(ctx context.Context, targets []string, qm models.ArchiverQueryModel) (map[string]models.SingleData, error) {
+	primary, secondary := c.source(qm)
+	if !secondary {
+		return c.Client.ExecuteBatchQuery(ctx, targets, qm)
+	}
+	if !primary {
+		return c.secondary.ExecuteBatchQuery(ctx, targets, qm)
+	}
+
+	pqm, sqm := c.split(qm)
+
+	var wg sync.WaitGroup
+	var sResult map[string]models.SingleData
+	var sErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sResult, sErr = c.secondary.ExecuteBatchQuery(ctx, targets, sqm)
+	}()
+	pResult, pErr := c.Client.ExecuteBatchQuery(ctx, targets, pqm)
+	wg.Wait()
+
+	result := make(map[string]models.SingleData, len(targets))
+	if pErr != nil || sErr != nil {
+		return result, nil
+	}
+
+	for target, pD := range pResult {
+		if sD, ok := sResult[target]; ok {
+			result[target] = c.stitch(pD, sD)
+		}
+	}
+
+	return result, nil
+}
+
+// stitch joins the data of the secondary source before the cutover and the data of the primary source.
+// The samples at the same timestamp are deduplicated and the sample of the primary source is used.
+func (c *StitchedClient) stitch(pD models.SingleData, sD models.SingleData) models.SingleData {
+	p, pOk := pD.Values.(models.TimeSlicer)
+	s, sOk := sD.Values.(models.TimeSlicer)
+	if !pOk || !sOk {
+		log.DefaultLogger.Warn("Data of the secondary source can't be stitched", "target", pD.PVname)
+		return pD
+	}
+
+	// The primary source returns the last sample before the cutover as the value at the cutover.
+	// The sample is used unless the secondary source has the later sample.
+	at := c.cutover
+	if idx := sort.Search(p.Len(), func(i int) bool { return !p.TimeAt(i).Before(c.cutover) }); idx > 0 {
+		last := p.TimeAt(idx - 1)
+		sEnd := sort.Search(s.Len(), func(i int) bool { return !s.TimeAt(i).Before(c.cutover) })
+		if sEnd == 0 || !s.TimeAt(sEnd-1).After(last) {
+			at = last
+		}
+	}
+
+	v, err := models.MergeByTime(s, p, at)
+	if err != nil {
+		log.DefaultLogger.Warn("Data of the secondary source can't be stitched", "target", pD.PVname, "Error", err)
+		return pD
+	}
+
+	stitched := pD
+	stitched.Values = v
+	if len(pD.Meta.Fields) == 0 {
+		stitched.Meta = sD.Meta
+	}
+	if pD.Endpoint != "" && sD.Endpoint != "" {
+		stitched.Endpoint = sD.Endpoint + ", " + pD.Endpoint
+	}
+
+	return stitched
+}
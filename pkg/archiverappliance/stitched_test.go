@@ -0,0 +1,149 @@
+package archiverappliance
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
+)
+
+// sourceClient archives the samples at the minutes and records the requested time ranges.
+// The last sample before the time range is also returned like the appliance.
+type sourceClient struct {
+	fakeClient
+	minutes []int
+	pvs     []string
+
+	mu     sync.Mutex
+	ranges []backend.TimeRange
+}
+
+func (f *sourceClient) FetchRegexTargetPVs(ctx context.Context, regex string, limit int) ([]string, error) {
+	return f.pvs, nil
+}
+
+func (f *sourceClient) ExecuteSingleQuery(ctx context.Context, target string, qm models.ArchiverQueryModel) (models.SingleData, error) {
+	f.mu.Lock()
+	f.ranges = append(f.ranges, qm.TimeRange)
+	f.mu.Unlock()
+
+	v := &models.Scalars{}
+	for _, m := range f.minutes {
+		v.Times = append(v.Times, testhelper.TimeHelper(m))
+		v.Values = append(v.Values, testhelper.InitFloat64SlicePointer([]float64{float64(m)})...)
+	}
+	values := models.SliceByTime(v, qm.TimeRange.From, qm.TimeRange.To).(*models.Scalars)
+	if len(values.Times) == 0 {
+		return models.SingleData{}, errEmptyResponse
+	}
+
+	return models.SingleData{Name: target, PVname: target, Values: values}, nil
+}
+
+func minutesOf(v models.Values) []int {
+	var minutes []int
+	for _, t := range v.(*models.Scalars).Times {
+		minutes = append(minutes, int(t.Sub(testhelper.TimeHelper(0))/time.Minute))
+	}
+	return minutes
+}
+
+func TestStitchedQuery(t *testing.T) {
+	var tests = []struct {
+		name     string
+		primary  []int
+		from     int
+		to       int
+		output   []int
+		primaryQ int
+		secondQ  int
+		operator string
+	}{
+		{name: "before cutover", primary: []int{9, 11, 13}, from: 1, to: 5, output: []int{0, 2, 4}, secondQ: 1},
+		{name: "after cutover", primary: []int{9, 11, 13}, from: 11, to: 14, output: []int{9, 11, 13}, primaryQ: 1},
+		{name: "last operator after cutover", primary: []int{9, 11, 13}, from: 1, to: 14, operator: "last", output: []int{9, 11, 13}, primaryQ: 1},
+		{name: "value at cutover from primary", primary: []int{9, 11, 13}, from: 1, to: 14, output: []int{0, 2, 4, 6, 8, 9, 11, 13}, primaryQ: 1, secondQ: 1},
+		{name: "duplicated timestamp", primary: []int{8, 11, 13}, from: 1, to: 14, output: []int{0, 2, 4, 6, 8, 11, 13}, primaryQ: 1, secondQ: 1},
+		{name: "later sample in secondary", primary: []int{5, 11, 13}, from: 1, to: 14, output: []int{0, 2, 4, 6, 8, 11, 13}, primaryQ: 1, secondQ: 1},
+		{name: "no data in primary", primary: []int{}, from: 1, to: 14, output: []int{0, 2, 4, 6, 8, 10}, primaryQ: 1, secondQ: 1},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			primary := &sourceClient{minutes: testCase.primary}
+			// The legacy archive kept running a little after the cutover
+			secondary := &sourceClient{minutes: []int{0, 2, 4, 6, 8, 10, 12}}
+			c := NewStitchedClient(primary, secondary, testhelper.TimeHelper(10))
+
+			qm := models.ArchiverQueryModel{
+				Operator:  testCase.operator,
+				TimeRange: backend.TimeRange{From: testhelper.TimeHelper(testCase.from), To: testhelper.TimeHelper(testCase.to)},
+			}
+			result, err := c.ExecuteSingleQuery(context.Background(), "PV", qm)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(testCase.output, minutesOf(result.Values)); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+			if len(primary.ranges) != testCase.primaryQ || len(secondary.ranges) != testCase.secondQ {
+				t.Errorf("got %d and %d queries, want %d and %d", len(primary.ranges), len(secondary.ranges), testCase.primaryQ, testCase.secondQ)
+			}
+
+			// The time range is divided at the cutover
+			if testCase.primaryQ > 0 && testCase.secondQ > 0 {
+				if !primary.ranges[0].From.Equal(testhelper.TimeHelper(10)) || !secondary.ranges[0].To.Equal(testhelper.TimeHelper(10)) {
+					t.Errorf("Unexpected time ranges: %v, %v", primary.ranges[0], secondary.ranges[0])
+				}
+			}
+		})
+	}
+}
+
+func TestStitchedQueryEmpty(t *testing.T) {
+	c := NewStitchedClient(&sourceClient{}, &sourceClient{}, testhelper.TimeHelper(10))
+	qm := models.ArchiverQueryModel{TimeRange: backend.TimeRange{From: testhelper.TimeHelper(1), To: testhelper.TimeHelper(14)}}
+
+	if _, err := c.ExecuteSingleQuery(context.Background(), "PV", qm); err != errEmptyResponse {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestStitchedBatchQuery(t *testing.T) {
+	c := NewStitchedClient(&sourceClient{}, &sourceClient{}, testhelper.TimeHelper(10))
+
+	// The PVs are retrieved one by one if the secondary source doesn't support the bulk retrieval
+	qm := models.ArchiverQueryModel{TimeRange: backend.TimeRange{From: testhelper.TimeHelper(1), To: testhelper.TimeHelper(14)}}
+	result, err := c.ExecuteBatchQuery(context.Background(), []string{"PV:A", "PV:B"}, qm)
+	if err != nil || len(result) != 0 {
+		t.Errorf("got %v, %v, want empty result", result, err)
+	}
+
+	// The time range after the cutover is retrieved from the primary source
+	qm.TimeRange.From = testhelper.TimeHelper(11)
+	if _, err := c.ExecuteBatchQuery(context.Background(), []string{"PV:A", "PV:B"}, qm); err != errBatchUnsupported {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestStitchedRegexSearch(t *testing.T) {
+	primary := &sourceClient{pvs: []string{"PV:A", "PV:B"}}
+	secondary := &sourceClient{pvs: []string{"PV:B", "PV:LEGACY"}}
+	c := NewStitchedClient(primary, secondary, testhelper.TimeHelper(10))
+
+	pvs, err := c.FetchRegexTargetPVs(context.Background(), "PV:.*", 100)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sort.Strings(pvs)
+	if diff := cmp.Diff([]string{"PV:A", "PV:B", "PV:LEGACY"}, pvs); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
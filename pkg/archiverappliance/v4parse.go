@@ -0,0 +1,138 @@
+package archiverappliance
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pb"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pvdata"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// Normative types of pvAccess
+const (
+	NT_TABLE = "NTTable"
+	NT_ENUM  = "NTEnum"
+)
+
+// isV4ValueField returns true if the field requires the value in the V4 structure
+func isV4ValueField(field models.FieldName) bool {
+	return field == models.FIELD_NAME_VAL || field == models.FIELD_NAME_VAL_WITH_ALARM
+}
+
+// convertV4Message converts the V4 generic bytes into the message of the type which the structure represents.
+// NTScalar and NTEnum are converted into scalars, and NTScalarArray into vectors.
+// NTTable doesn't have a corresponding message. The sample is returned as is with the decoded structure.
+func convertV4Message(sample *pb.V4GenericBytes) (proto.Message, pb.PayloadType, *pvdata.Structure, error) {
+	v, err := pvdata.Decode(sample.GetVal())
+	if err != nil {
+		log.DefaultLogger.Error("Failed to decode V4 generic bytes:", err)
+		return nil, 0, nil, errFailedToParsePBFormat
+	}
+
+	s, ok := v.(*pvdata.Structure)
+	if !ok {
+		return nil, 0, nil, errIllegalPayloadType
+	}
+
+	switch s.NormativeType() {
+	case NT_TABLE:
+		return sample, pb.PayloadType_V4_GENERIC_BYTES, s, nil
+	case NT_ENUM:
+		index, _ := s.Get("value.index")
+		if i, ok := pvdata.ToFloat64(index); ok {
+			m := &pb.ScalarEnum{Val: proto.Int32(int32(i))}
+			copyV4SampleMeta(sample, m)
+			return m, pb.PayloadType_SCALAR_ENUM, nil, nil
+		}
+		return nil, 0, nil, errIllegalPayloadType
+	}
+
+	// NTScalar, NTScalarArray and the other structures which have the value field
+	value, ok := s.Get("value")
+	if !ok {
+		return nil, 0, nil, errIllegalPayloadType
+	}
+
+	switch x := value.(type) {
+	case string:
+		m := &pb.ScalarString{Val: proto.String(x)}
+		copyV4SampleMeta(sample, m)
+		return m, pb.PayloadType_SCALAR_STRING, nil, nil
+	case []string:
+		m := &pb.VectorString{Val: x}
+		copyV4SampleMeta(sample, m)
+		return m, pb.PayloadType_WAVEFORM_STRING, nil, nil
+	}
+
+	if f, ok := pvdata.ToFloat64(value); ok {
+		m := &pb.ScalarDouble{Val: proto.Float64(f)}
+		copyV4SampleMeta(sample, m)
+		return m, pb.PayloadType_SCALAR_DOUBLE, nil, nil
+	}
+
+	if a, ok := pvdata.ToFloat64Array(value); ok {
+		m := &pb.VectorDouble{Val: a}
+		copyV4SampleMeta(sample, m)
+		return m, pb.PayloadType_WAVEFORM_DOUBLE, nil, nil
+	}
+
+	return nil, 0, nil, errIllegalPayloadType
+}
+
+// copyV4SampleMeta copies the timestamp, the alarm and the metadata of the V4 sample into the converted message
+func copyV4SampleMeta(sample *pb.V4GenericBytes, m proto.Message) {
+	switch x := m.(type) {
+	case *pb.ScalarEnum:
+		x.Secondsintoyear, x.Nano, x.Severity, x.Status = sample.Secondsintoyear, sample.Nano, sample.Severity, sample.Status
+		x.Repeatcount, x.Fieldvalues, x.Fieldactualchange = sample.Repeatcount, sample.Fieldvalues, sample.Fieldactualchange
+	case *pb.ScalarString:
+		x.Secondsintoyear, x.Nano, x.Severity, x.Status = sample.Secondsintoyear, sample.Nano, sample.Severity, sample.Status
+		x.Repeatcount, x.Fieldvalues, x.Fieldactualchange = sample.Repeatcount, sample.Fieldvalues, sample.Fieldactualchange
+	case *pb.VectorString:
+		x.Secondsintoyear, x.Nano, x.Severity, x.Status = sample.Secondsintoyear, sample.Nano, sample.Severity, sample.Status
+		x.Repeatcount, x.Fieldvalues, x.Fieldactualchange = sample.Repeatcount, sample.Fieldvalues, sample.Fieldactualchange
+	case *pb.ScalarDouble:
+		x.Secondsintoyear, x.Nano, x.Severity, x.Status = sample.Secondsintoyear, sample.Nano, sample.Severity, sample.Status
+		x.Repeatcount, x.Fieldvalues, x.Fieldactualchange = sample.Repeatcount, sample.Fieldvalues, sample.Fieldactualchange
+	case *pb.VectorDouble:
+		x.Secondsintoyear, x.Nano, x.Severity, x.Status = sample.Secondsintoyear, sample.Nano, sample.Severity, sample.Status
+		x.Repeatcount, x.Fieldvalues, x.Fieldactualchange = sample.Repeatcount, sample.Fieldvalues, sample.Fieldactualchange
+	}
+}
+
+func getTableValue(message proto.Message, table *pvdata.Structure) (names []string, columns []interface{}, sec uint32, nano uint32, err error) {
+	sample, ok := message.(pb.MetaFieldData)
+	if !ok || table == nil {
+		return nil, nil, 0, 0, errIllegalPayloadType
+	}
+
+	value, ok := table.Get("value")
+	if !ok {
+		return nil, nil, 0, 0, errIllegalPayloadType
+	}
+	s, ok := value.(*pvdata.Structure)
+	if !ok {
+		return nil, nil, 0, 0, errIllegalPayloadType
+	}
+
+	// Labels are the names of the columns to display
+	names = s.Names
+	if l, ok := table.Get("labels"); ok {
+		if labels, ok := l.([]string); ok && len(labels) == len(s.Names) {
+			names = labels
+		}
+	}
+
+	columns = make([]interface{}, len(s.Values))
+	for idx, v := range s.Values {
+		if strs, ok := v.([]string); ok {
+			columns[idx] = strs
+			continue
+		}
+		if nums, ok := pvdata.ToFloat64Array(v); ok {
+			columns[idx] = nums
+		}
+	}
+
+	return names, columns, sample.GetSecondsintoyear(), sample.GetNano(), nil
+}
@@ -0,0 +1,178 @@
+package archiverappliance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance/pb"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// pvaBuffer builds the serialized PVData of V4 generic bytes
+type pvaBuffer struct {
+	bytes.Buffer
+}
+
+func (b *pvaBuffer) str(s string) *pvaBuffer {
+	b.WriteByte(byte(len(s)))
+	b.WriteString(s)
+	return b
+}
+
+func (b *pvaBuffer) code(c byte) *pvaBuffer {
+	b.WriteByte(c)
+	return b
+}
+
+func (b *pvaBuffer) put(v interface{}) *pvaBuffer {
+	binary.Write(b, binary.BigEndian, v)
+	return b
+}
+
+func ntScalarBytes(v float64) []byte {
+	b := &pvaBuffer{}
+	b.code(0x80).str("epics:nt/NTScalar:1.0").code(1).str("value").code(0x43)
+	b.put(v)
+	return b.Bytes()
+}
+
+func ntScalarArrayBytes(v []int32) []byte {
+	b := &pvaBuffer{}
+	b.code(0x80).str("epics:nt/NTScalarArray:1.0").code(1).str("value").code(0x2A)
+	b.code(byte(len(v))).put(v)
+	return b.Bytes()
+}
+
+func ntTableBytes(names []string, currents []float64) []byte {
+	b := &pvaBuffer{}
+	b.code(0x80).str("epics:nt/NTTable:1.0").code(2)
+	b.str("labels").code(0x68)
+	b.str("value").code(0x80).str("").code(2).str("name").code(0x68).str("current").code(0x4B)
+	b.code(2).str("Name").str("Current")
+	b.code(byte(len(names)))
+	for _, n := range names {
+		b.str(n)
+	}
+	b.code(byte(len(currents))).put(currents)
+	return b.Bytes()
+}
+
+func v4Sample(sec uint32, val []byte) *pb.V4GenericBytes {
+	return &pb.V4GenericBytes{Secondsintoyear: proto.Uint32(sec), Nano: proto.Uint32(0), Val: val}
+}
+
+func TestParseV4GenericBytes(t *testing.T) {
+	info := &pb.PayloadInfo{
+		Type:   pb.PayloadType_V4_GENERIC_BYTES.Enum(),
+		Pvname: proto.String("PV:V4"),
+		Year:   proto.Int32(2024),
+	}
+
+	t.Run("NTScalar", func(t *testing.T) {
+		in := buildPBResponse(buildPBChunk(t, info, v4Sample(0, ntScalarBytes(1.5)), v4Sample(1, ntScalarBytes(-2))))
+
+		sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, false)
+		if err != nil {
+			t.Fatalf("Failed to parse the data: %v", err)
+		}
+
+		v, ok := sD.Values.(*models.Scalars)
+		if !ok {
+			t.Fatalf("Single data type is diffrent")
+		}
+		if len(v.Values) != 2 || *v.Values[0] != 1.5 || *v.Values[1] != -2 {
+			t.Errorf("Values differ - Got: %v", v.Values)
+		}
+		if !v.Times[1].Equal(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC)) {
+			t.Errorf("Time differs - Got: %v", v.Times[1])
+		}
+	})
+
+	t.Run("NTScalarArray", func(t *testing.T) {
+		in := buildPBResponse(buildPBChunk(t, info, v4Sample(0, ntScalarArrayBytes([]int32{1, 2, 3}))))
+
+		sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, false)
+		if err != nil {
+			t.Fatalf("Failed to parse the data: %v", err)
+		}
+
+		v, ok := sD.Values.(*models.Arrays)
+		if !ok {
+			t.Fatalf("Single data type is diffrent")
+		}
+		if diff := cmp.Diff([][]float64{{1, 2, 3}}, v.Values); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("NTTable", func(t *testing.T) {
+		in := buildPBResponse(buildPBChunk(t, info,
+			v4Sample(0, ntTableBytes([]string{"A", "B"}, []float64{0.5, 1})),
+			v4Sample(1, ntTableBytes([]string{"C"}, []float64{2})),
+		))
+
+		sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, false)
+		if err != nil {
+			t.Fatalf("Failed to parse the data: %v", err)
+		}
+
+		v, ok := sD.Values.(*models.Tables)
+		if !ok {
+			t.Fatalf("Single data type is diffrent")
+		}
+
+		if len(v.Times) != 3 || !v.Times[2].Equal(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC)) {
+			t.Fatalf("Times differ - Got: %v", v.Times)
+		}
+		if len(v.Columns) != 2 || v.Columns[0].Name != "Name" || v.Columns[1].Name != "Current" {
+			t.Fatalf("Columns differ - Got: %v", v.Columns)
+		}
+
+		var names []string
+		for _, s := range v.Columns[0].Strings {
+			names = append(names, *s)
+		}
+		var currents []float64
+		for _, f := range v.Columns[1].Numbers {
+			currents = append(currents, *f)
+		}
+		if diff := cmp.Diff([]string{"A", "B", "C"}, names); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff([]float64{0.5, 1, 2}, currents); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("SEVR", func(t *testing.T) {
+		sample := v4Sample(0, []byte{0xFF})
+		sample.Severity = proto.Int32(2)
+		in := buildPBResponse(buildPBChunk(t, info, sample))
+
+		sD, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_SEVR, 1000, false, false)
+		if err != nil {
+			t.Fatalf("Failed to parse the data: %v", err)
+		}
+
+		v, ok := sD.Values.(*models.Scalars)
+		if !ok {
+			t.Fatalf("Single data type is diffrent")
+		}
+		if len(v.Values) != 1 || *v.Values[0] != 2 {
+			t.Errorf("Values differ - Got: %v", v.Values)
+		}
+	})
+
+	t.Run("broken bytes", func(t *testing.T) {
+		in := buildPBResponse(buildPBChunk(t, info, v4Sample(0, []byte{0x43, 0x00})))
+
+		_, err := archiverPBSingleQueryParser(bytes.NewReader(in), models.FIELD_NAME_VAL, 1000, false, false)
+		if err == nil {
+			t.Errorf("Error should be returned")
+		}
+	})
+}
@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"github.com/grafana/grafana-plugin-sdk-go/experimental/concurrent"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/aalive"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/archiverappliance"
@@ -14,10 +19,13 @@ import (
 )
 
 type ArchiverDatasource struct {
-	// Structure defined by grafana-plugin-sdk-go. Implements QueryData and CheckHealth.
+	// Structure defined by grafana-plugin-sdk-go. Implements QueryData, CheckHealth and CallResource.
 	//im instancemgmt.InstanceManager
-	config models.DatasourceSettings
-	client archiverappliance.Client
+	config          models.DatasourceSettings
+	client          archiverappliance.Client
+	resourceHandler backend.CallResourceHandler
+	// dispose stops the background work of the client
+	dispose func()
 }
 
 func newArchiverDataSource(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
@@ -26,12 +34,132 @@ func newArchiverDataSource(ctx context.Context, settings backend.DataSourceInsta
 		return nil, err
 	}
 
-	client, err := archiverappliance.NewAAClient(ctx, config.URL, config.HttpOptions)
+	aaClient, dispose, err := newApplianceClient(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
-	return &ArchiverDatasource{config: *config, client: client}, nil
+	var client archiverappliance.Client = archiverappliance.NewPooledClient(aaClient, config.UID, config.MaxConcurrency)
+	if config.CacheTTL > 0 {
+		client = archiverappliance.NewCachedClient(client, config.UID, time.Duration(config.CacheTTL)*time.Second, config.CacheMaxSize)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pvs", archiverappliance.PVNamesHandler(client))
+
+	return &ArchiverDatasource{config: *config, client: client, resourceHandler: httpadapter.New(mux), dispose: dispose}, nil
+}
+
+// Dispose is called when the datasource settings are changed or the datasource is deleted
+func (td *ArchiverDatasource) Dispose() {
+	if td.dispose != nil {
+		td.dispose()
+	}
+}
+
+// newApplianceClient returns the client of the appliance, which is federated with the other appliances
+// and stitched with the secondary source if they are configured. The returned function stops the health probing of the replicas.
+func newApplianceClient(ctx context.Context, config *models.DatasourceSettings) (archiverappliance.Client, func(), error) {
+	var client archiverappliance.Client
+	dispose := func() {}
+
+	if dir, ok := pbDirectory(config.URL); ok {
+		client = archiverappliance.NewPBFileClient(dir)
+	} else {
+		urls := []string{config.URL}
+		for _, url := range config.ReplicaURLs {
+			if url != "" && url != config.URL {
+				urls = append(urls, url)
+			}
+		}
+
+		aaClient, err := archiverappliance.NewFailoverAAClient(ctx, urls, config.HttpOptions, retryOptions(config), archiverappliance.DEFAULT_FAILOVER_PROBE_INTERVAL)
+		if err != nil {
+			return nil, nil, err
+		}
+		client, dispose = aaClient, aaClient.Close
+	}
+
+	client, err := withFederation(ctx, client, config)
+	if err != nil {
+		dispose()
+		return nil, nil, err
+	}
+
+	client, err = withSecondarySource(ctx, client, config)
+	if err != nil {
+		dispose()
+		return nil, nil, err
+	}
+
+	return client, dispose, nil
+}
+
+// newSourceClient returns the client of the appliance or the PB files in the directory of the file URL
+func newSourceClient(ctx context.Context, url string, config *models.DatasourceSettings) (archiverappliance.Client, error) {
+	if dir, ok := pbDirectory(url); ok {
+		return archiverappliance.NewPBFileClient(dir), nil
+	}
+
+	return archiverappliance.NewAAClient(ctx, url, config.HttpOptions, retryOptions(config))
+}
+
+// pbDirectory returns the directory of the file URL, e.g. file:///data/lts
+func pbDirectory(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+
+	return filepath.FromSlash(u.Path), true
+}
+
+// withFederation federates the client with the other appliances
+func withFederation(ctx context.Context, client archiverappliance.Client, config *models.DatasourceSettings) (archiverappliance.Client, error) {
+	if len(config.ApplianceURLs) == 0 {
+		return client, nil
+	}
+
+	members := []archiverappliance.FederatedMember{{URL: config.URL, Client: client}}
+	for _, url := range config.ApplianceURLs {
+		if url == "" || url == config.URL {
+			continue
+		}
+
+		c, err := newSourceClient(ctx, url, config)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, archiverappliance.FederatedMember{URL: url, Client: c})
+	}
+
+	return archiverappliance.NewFederatedClient(members), nil
+}
+
+// withSecondarySource stitches the data of the secondary source before the cutover to the data of the client
+func withSecondarySource(ctx context.Context, client archiverappliance.Client, config *models.DatasourceSettings) (archiverappliance.Client, error) {
+	if config.SecondaryURL == "" {
+		return client, nil
+	}
+
+	secondary, err := newSourceClient(ctx, config.SecondaryURL, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return archiverappliance.NewStitchedClient(client, secondary, config.Cutover), nil
+}
+
+func retryOptions(config *models.DatasourceSettings) archiverappliance.RetryOptions {
+	opts := archiverappliance.RetryOptions{
+		MaxRetries: archiverappliance.DEFAULT_MAX_RETRIES,
+		MaxDelay:   time.Duration(config.RetryMaxDelay) * time.Second,
+	}
+	if config.MaxRetries != nil {
+		opts.MaxRetries = *config.MaxRetries
+	}
+
+	return opts
 }
 
 func (td *ArchiverDatasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
@@ -43,14 +171,11 @@ func (td *ArchiverDatasource) handleSingleQueryData(ctx context.Context, q concu
 }
 
 func (td *ArchiverDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
-	// the frontend health check is still used at this time
-	var status = backend.HealthStatusOk
-	var message = "This is a fake success message"
+	return archiverappliance.CheckHealth(ctx, td.client, td.config), nil
+}
 
-	return &backend.CheckHealthResult{
-		Status:  status,
-		Message: message,
-	}, nil
+func (td *ArchiverDatasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return td.resourceHandler.CallResource(ctx, req, sender)
 }
 
 func (td *ArchiverDatasource) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
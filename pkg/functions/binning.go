@@ -0,0 +1,188 @@
+package functions
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+// binAggregator computes the value of a bin from the non-null values of the samples in the bin.
+// nil means the bin has a null value.
+type binAggregator func(values []float64) *float64
+
+// localOperators are the operators which can be applied in the backend
+var localOperators = map[string]binAggregator{
+	"mean":        nullIfEmpty(binMean),
+	"min":         nullIfEmpty(binMin),
+	"max":         nullIfEmpty(binMax),
+	"count":       binCount,
+	"std":         nullIfEmpty(binStd),
+	"firstSample": nullIfEmpty(binFirst),
+	"lastSample":  nullIfEmpty(binLast),
+	"median":      nullIfEmpty(binMedian),
+}
+
+// nullIfEmpty returns the aggregator which gives a null value for the bins only with null values
+func nullIfEmpty(f func(values []float64) float64) binAggregator {
+	return func(values []float64) *float64 {
+		if len(values) == 0 {
+			return nil
+		}
+		v := f(values)
+		return &v
+	}
+}
+
+// LocalBinning reports whether the operator of the query is applied in the backend instead of the appliance.
+// The operators which are not supported in the backend are applied by the appliance.
+func LocalBinning(qm models.ArchiverQueryModel) bool {
+	if qm.Processing != models.PROCESSING_LOCAL {
+		return false
+	}
+
+	return IsLocalOperator(qm.Operator)
+}
+
+// IsLocalOperator reports whether the operator can be applied in the backend.
+func IsLocalOperator(operator string) bool {
+	_, ok := localOperators[operator]
+	return ok
+}
+
+// ApplyBinning aggregates the raw samples into the bins of the interval if the local processing is selected.
+// Only the scalar data is aggregated like the appliance. The severity and status of "VAL with Alarm" are dropped.
+func ApplyBinning(responseData []*models.SingleData, qm models.ArchiverQueryModel) []*models.SingleData {
+	if !LocalBinning(qm) {
+		return responseData
+	}
+
+	interval := qm.BinInterval()
+	if interval == 0 {
+		return responseData
+	}
+
+	agg := localOperators[qm.Operator]
+	for _, sD := range responseData {
+		// The alarms and the metadata of the raw samples don't match the bins
+		v, ok := transformScalars(sD, true)
+		if !ok {
+			continue
+		}
+
+		sD.Values = binScalars(v, time.Duration(interval)*time.Second, qm.TimeRange.From, agg)
+	}
+
+	return responseData
+}
+
+// binStart returns the start of the bin of t. The bins are aligned to the Unix epoch like the appliance.
+func binStart(t time.Time, interval time.Duration) time.Time {
+	n := t.UnixNano()
+	return time.Unix(0, n-n%int64(interval)).In(t.Location())
+}
+
+// binScalars aggregates the samples into the bins which are timestamped with the start of the bin.
+// The bins without samples are omitted, and the bins only with null values have a null value except for count.
+func binScalars(v *models.Scalars, interval time.Duration, from time.Time, agg binAggregator) *models.Scalars {
+	result := &models.Scalars{}
+
+	first := binStart(from, interval)
+	var bin []float64
+	var start time.Time
+	inBin := false
+
+	flush := func() {
+		if !inBin {
+			return
+		}
+		result.Times = append(result.Times, start)
+		result.Values = append(result.Values, agg(bin))
+	}
+
+	for idx, t := range v.Times {
+		// The sample before the time range is only used as the value at the start
+		s := binStart(t, interval)
+		if s.Before(first) {
+			continue
+		}
+
+		if !inBin || !s.Equal(start) {
+			flush()
+			bin = bin[:0]
+			start = s
+			inBin = true
+		}
+
+		if v.Values[idx] != nil {
+			bin = append(bin, *v.Values[idx])
+		}
+	}
+	flush()
+
+	return result
+}
+
+func binMean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func binMin(values []float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		m = math.Min(m, v)
+	}
+	return m
+}
+
+func binMax(values []float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		m = math.Max(m, v)
+	}
+	return m
+}
+
+// binCount returns the number of the non-null values. The count of the bin only with null values is 0 like the appliance.
+func binCount(values []float64) *float64 {
+	c := float64(len(values))
+	return &c
+}
+
+// binStd returns the sample standard deviation like the appliance
+func binStd(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	mean := binMean(values)
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+func binFirst(values []float64) float64 {
+	return values[0]
+}
+
+func binLast(values []float64) float64 {
+	return values[len(values)-1]
+}
+
+func binMedian(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
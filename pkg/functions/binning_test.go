@@ -0,0 +1,133 @@
+package functions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
+)
+
+func TestApplyBinning(t *testing.T) {
+	// Samples at 0, 1, ..., 7 minutes and a null value at 8 minutes
+	values := func() *models.Scalars {
+		v := &models.Scalars{
+			Times:  testhelper.TimeArrayHelper(-1, 8),
+			Values: testhelper.InitFloat64SlicePointer([]float64{1, 3, 2, 8, 4, 4, 10, 6, 0}),
+		}
+		v.Values[8] = nil
+		return v
+	}
+
+	var tests = []struct {
+		operator   string
+		processing models.ProcessingOption
+		from       int
+		times      []time.Time
+		output     []*float64
+	}{
+		{operator: "mean", processing: models.PROCESSING_LOCAL, output: testhelper.InitFloat64SlicePointer([]float64{2, 16.0 / 3, 8})},
+		{operator: "min", processing: models.PROCESSING_LOCAL, output: testhelper.InitFloat64SlicePointer([]float64{1, 4, 6})},
+		{operator: "max", processing: models.PROCESSING_LOCAL, output: testhelper.InitFloat64SlicePointer([]float64{3, 8, 10})},
+		{operator: "count", processing: models.PROCESSING_LOCAL, output: testhelper.InitFloat64SlicePointer([]float64{3, 3, 2})},
+		{operator: "std", processing: models.PROCESSING_LOCAL, output: testhelper.InitFloat64SlicePointer([]float64{1, 2.309401076758503, 2.8284271247461903})},
+		{operator: "firstSample", processing: models.PROCESSING_LOCAL, output: testhelper.InitFloat64SlicePointer([]float64{1, 8, 10})},
+		{operator: "lastSample", processing: models.PROCESSING_LOCAL, output: testhelper.InitFloat64SlicePointer([]float64{2, 4, 6})},
+		{operator: "median", processing: models.PROCESSING_LOCAL, output: testhelper.InitFloat64SlicePointer([]float64{2, 4, 8})},
+		// The samples before the bin of from are not aggregated
+		{operator: "max", processing: models.PROCESSING_LOCAL, from: 4, times: []time.Time{testhelper.TimeHelper(3), testhelper.TimeHelper(6)}, output: testhelper.InitFloat64SlicePointer([]float64{8, 10})},
+		// The operator is applied by the appliance
+		{operator: "mean", processing: models.PROCESSING_APPLIANCE, times: testhelper.TimeArrayHelper(-1, 8), output: values().Values},
+		{operator: "jitter", processing: models.PROCESSING_LOCAL, times: testhelper.TimeArrayHelper(-1, 8), output: values().Values},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.operator+"/"+string(testCase.processing), func(t *testing.T) {
+			qm := models.ArchiverQueryModel{
+				Operator:   testCase.operator,
+				Processing: testCase.processing,
+				Interval:   180,
+				TimeRange:  backend.TimeRange{From: testhelper.TimeHelper(testCase.from), To: testhelper.TimeHelper(9)},
+			}
+			sD := &models.SingleData{Values: values()}
+
+			result := ApplyBinning([]*models.SingleData{sD}, qm)
+			v := result[0].Values.(*models.Scalars)
+
+			times := testCase.times
+			if times == nil {
+				times = []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(3), testhelper.TimeHelper(6)}
+			}
+			if diff := cmp.Diff(times, v.Times); diff != "" {
+				t.Errorf("Times mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.output, v.Values); diff != "" {
+				t.Errorf("Values mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyBinningAlarmScalars(t *testing.T) {
+	v := &models.AlarmScalars{
+		Scalars: &models.Scalars{
+			Times:  testhelper.TimeArrayHelper(-1, 5),
+			Values: testhelper.InitFloat64SlicePointer([]float64{1, 3, 2, 8, 4, 6}),
+		},
+		Severity: &models.Enums{Times: testhelper.TimeArrayHelper(-1, 5), Values: []data.EnumItemIndex{0, 1, 0, 2, 0, 0}},
+		Status:   &models.Enums{Times: testhelper.TimeArrayHelper(-1, 5), Values: []data.EnumItemIndex{0, 3, 0, 4, 0, 0}},
+	}
+	qm := models.ArchiverQueryModel{
+		Operator:   "mean",
+		Processing: models.PROCESSING_LOCAL,
+		Interval:   180,
+		TimeRange:  backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(6)},
+	}
+
+	result := ApplyBinning([]*models.SingleData{{Values: v}}, qm)
+	output := &models.Scalars{
+		Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(3)},
+		Values: testhelper.InitFloat64SlicePointer([]float64{2, 6}),
+	}
+	if diff := cmp.Diff(output, result[0].Values); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyBinningNullBin(t *testing.T) {
+	var tests = []struct {
+		operator string
+		output   []*float64
+	}{
+		{operator: "mean", output: []*float64{nil, testhelper.InitFloat64SlicePointer([]float64{5})[0]}},
+		// The count of the bin only with null values is 0 like the appliance
+		{operator: "count", output: testhelper.InitFloat64SlicePointer([]float64{0, 1})},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.operator, func(t *testing.T) {
+			v := &models.Scalars{
+				Times:  testhelper.TimeArrayHelper(-1, 3),
+				Values: []*float64{nil, nil, nil, testhelper.InitFloat64SlicePointer([]float64{5})[0]},
+			}
+			qm := models.ArchiverQueryModel{
+				Operator:   testCase.operator,
+				Processing: models.PROCESSING_LOCAL,
+				Interval:   180,
+				TimeRange:  backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(4)},
+			}
+
+			result := ApplyBinning([]*models.SingleData{{Values: v}}, qm)
+			output := &models.Scalars{
+				Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(3)},
+				Values: testCase.output,
+			}
+			if diff := cmp.Diff(output, result[0].Values); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
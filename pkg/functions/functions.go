@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
 )
@@ -17,12 +19,33 @@ type singleDataOrder struct {
 	rank float64
 }
 
-func filterIndexer(allData []*models.SingleData, value string) ([]float64, error) {
+// transformScalars returns the scalar values of the data to be transformed.
+// If the transform changes the timestamps, the severity and status of "VAL with Alarm" and the metadata of the samples
+// are dropped from the data because they don't match the transformed values.
+// The min, max, std and count of the statistics are always dropped because they aren't transformed with the value.
+func transformScalars(sD *models.SingleData, changesTimes bool) (*models.Scalars, bool) {
+	values, ok := models.ScalarsOf(sD.Values)
+	if !ok {
+		return nil, false
+	}
+
+	if _, stats := sD.Values.(*models.Statistics); stats || changesTimes {
+		sD.Values = values
+	}
+	if changesTimes {
+		sD.SampleMeta = nil
+	}
+	return values, true
+}
+
+func filterIndexer(allData []*models.SingleData, value string, threshold float64, timeRange backend.TimeRange) ([]float64, error) {
 	// determine a single value for each SingleData. Useful for sorting or ranking SingleData
+	// threshold is only used by timeAboveThreshold and dutyCycle
+	// timeRange is only used by the time-weighted values
 	rank := make([]float64, len(allData))
 	for idx, sData := range allData {
 
-		values, ok := sData.Values.(*models.Scalars)
+		values, ok := models.ScalarsOf(sData.Values)
 		if !ok {
 			continue
 		}
@@ -43,6 +66,12 @@ func filterIndexer(allData []*models.SingleData, value string) ([]float64, error
 			v, err = values.Rank(models.RANKTYPE_ABSOLUTEMAX)
 		case "sum":
 			v, err = values.Rank(models.RANKTYPE_SUM)
+		case "timeWeightedAvg":
+			v, err = values.TimeWeightedRank(models.RANKTYPE_TIMEWEIGHTEDAVG, threshold, timeRange.From, timeRange.To)
+		case "timeAboveThreshold":
+			v, err = values.TimeWeightedRank(models.RANKTYPE_TIMEABOVETHRESHOLD, threshold, timeRange.From, timeRange.To)
+		case "dutyCycle":
+			v, err = values.TimeWeightedRank(models.RANKTYPE_DUTYCYCLE, threshold, timeRange.From, timeRange.To)
 		default:
 			errMsg := fmt.Sprintf("Value %v not recognized", value)
 			return rank, errors.New(errMsg)
@@ -56,12 +85,12 @@ func filterIndexer(allData []*models.SingleData, value string) ([]float64, error
 	return rank, nil
 }
 
-func sortCore(allData []*models.SingleData, value string, order string) ([]*models.SingleData, error) {
+func sortCore(allData []*models.SingleData, value string, order string, threshold float64, timeRange backend.TimeRange) ([]*models.SingleData, error) {
 	// Sort allData
 	// The order parameter chooses whether the order of the sort is ascending or descending
 	// The value parameter determines how the rank of each SingleData entry is measured
 	newData := make([]*models.SingleData, 0, len(allData))
-	rank, idxErr := filterIndexer(allData, value)
+	rank, idxErr := filterIndexer(allData, value, threshold, timeRange)
 	if idxErr != nil {
 		return allData, idxErr
 	}
@@ -101,7 +130,7 @@ func sortCore(allData []*models.SingleData, value string, order string) ([]*mode
 
 func scale(allData []*models.SingleData, factor float64) []*models.SingleData {
 	for _, oneData := range allData {
-		values, ok := oneData.Values.(*models.Scalars)
+		values, ok := transformScalars(oneData, false)
 		if !ok {
 			continue
 		}
@@ -112,7 +141,7 @@ func scale(allData []*models.SingleData, factor float64) []*models.SingleData {
 
 func offset(allData []*models.SingleData, delta float64) []*models.SingleData {
 	for _, oneData := range allData {
-		values, ok := oneData.Values.(*models.Scalars)
+		values, ok := transformScalars(oneData, false)
 		if !ok {
 			continue
 		}
@@ -123,7 +152,7 @@ func offset(allData []*models.SingleData, delta float64) []*models.SingleData {
 
 func delta(allData []*models.SingleData) []*models.SingleData {
 	for _, oneData := range allData {
-		values, ok := oneData.Values.(*models.Scalars)
+		values, ok := transformScalars(oneData, true)
 		if !ok {
 			continue
 		}
@@ -134,7 +163,7 @@ func delta(allData []*models.SingleData) []*models.SingleData {
 
 func fluctuation(allData []*models.SingleData) []*models.SingleData {
 	for _, oneData := range allData {
-		values, ok := oneData.Values.(*models.Scalars)
+		values, ok := transformScalars(oneData, false)
 		if !ok {
 			continue
 		}
@@ -145,7 +174,7 @@ func fluctuation(allData []*models.SingleData) []*models.SingleData {
 
 func movingAverage(allData []*models.SingleData, windowSize int) []*models.SingleData {
 	for _, oneData := range allData {
-		values, ok := oneData.Values.(*models.Scalars)
+		values, ok := transformScalars(oneData, false)
 		if !ok {
 			continue
 		}
@@ -154,12 +183,139 @@ func movingAverage(allData []*models.SingleData, windowSize int) []*models.Singl
 	return allData
 }
 
+// parseUnit parses the unit of the time for derivative and integral, e.g. 1s, 1m or 1h
+func parseUnit(unit string) (time.Duration, error) {
+	d, err := time.ParseDuration(unit)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("unit %s not recognized", unit)
+	}
+	return d, nil
+}
+
+func derivative(allData []*models.SingleData, unit string, nonNegative bool) ([]*models.SingleData, error) {
+	d, err := parseUnit(unit)
+	if err != nil {
+		return allData, err
+	}
+
+	for _, oneData := range allData {
+		values, ok := transformScalars(oneData, true)
+		if !ok {
+			continue
+		}
+		values.Derivative(d, nonNegative)
+	}
+	return allData, nil
+}
+
+func integral(allData []*models.SingleData, unit string) ([]*models.SingleData, error) {
+	d, err := parseUnit(unit)
+	if err != nil {
+		return allData, err
+	}
+
+	for _, oneData := range allData {
+		values, ok := transformScalars(oneData, false)
+		if !ok {
+			continue
+		}
+		values.Integral(d)
+	}
+	return allData, nil
+}
+
+func cumulativeSum(allData []*models.SingleData) []*models.SingleData {
+	for _, oneData := range allData {
+		values, ok := transformScalars(oneData, false)
+		if !ok {
+			continue
+		}
+		values.CumulativeSum()
+	}
+	return allData
+}
+
+// RESAMPLE_ALIGN_FIRST is the interval of resample to align the series to the timestamps of the first series
+const RESAMPLE_ALIGN_FIRST = "first"
+
+// Maximum number of the points of a resampled series to protect the backend from a too short interval
+const RESAMPLE_MAX_POINTS = 100000
+
+func resample(allData []*models.SingleData, interval string, method models.ResampleMethod, timeRange backend.TimeRange) ([]*models.SingleData, error) {
+	// The method and times are validated before the series are modified so that the data is unaltered on error
+	if err := method.Validate(); err != nil {
+		return allData, err
+	}
+
+	var times []time.Time
+	if interval == RESAMPLE_ALIGN_FIRST {
+		var first *models.Scalars
+		for _, oneData := range allData {
+			if values, ok := models.ScalarsOf(oneData.Values); ok {
+				first = values
+				break
+			}
+		}
+		if first == nil {
+			return allData, nil
+		}
+
+		times = append([]time.Time{}, first.Times...)
+	} else {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return allData, fmt.Errorf("interval %s not recognized", interval)
+		}
+
+		times, err = resampleTimes(timeRange, d)
+		if err != nil {
+			return allData, err
+		}
+	}
+
+	for _, oneData := range allData {
+		values, ok := transformScalars(oneData, true)
+		if !ok {
+			continue
+		}
+		if err := values.Resample(times, method); err != nil {
+			return allData, err
+		}
+	}
+
+	return allData, nil
+}
+
+// resampleTimes returns the times of the interval in the time range.
+// The times are aligned to the Unix epoch so that the times don't depend on the start of the time range.
+func resampleTimes(timeRange backend.TimeRange, interval time.Duration) ([]time.Time, error) {
+	if !timeRange.To.After(timeRange.From) {
+		return nil, errors.New("time range is empty")
+	}
+
+	start := binStart(timeRange.From, interval)
+	if start.Before(timeRange.From) {
+		start = start.Add(interval)
+	}
+
+	if n := timeRange.To.Sub(start) / interval; n >= RESAMPLE_MAX_POINTS {
+		return nil, fmt.Errorf("interval %s is too short for the time range", interval)
+	}
+
+	var times []time.Time
+	for t := start; !t.After(timeRange.To); t = t.Add(interval) {
+		times = append(times, t)
+	}
+
+	return times, nil
+}
+
 // Array to Scalar Functions
 
 // Filter Series Functions
 
-func top(allData []*models.SingleData, number int, value string) ([]*models.SingleData, error) {
-	result, sortErr := sortCore(allData, value, "desc")
+func top(allData []*models.SingleData, number int, value string, threshold float64, timeRange backend.TimeRange) ([]*models.SingleData, error) {
+	result, sortErr := sortCore(allData, value, "desc", threshold, timeRange)
 	if sortErr != nil {
 		return allData, sortErr
 	}
@@ -169,8 +325,8 @@ func top(allData []*models.SingleData, number int, value string) ([]*models.Sing
 	return result, nil
 }
 
-func bottom(allData []*models.SingleData, number int, value string) ([]*models.SingleData, error) {
-	result, sortErr := sortCore(allData, value, "asc")
+func bottom(allData []*models.SingleData, number int, value string, threshold float64, timeRange backend.TimeRange) ([]*models.SingleData, error) {
+	result, sortErr := sortCore(allData, value, "asc", threshold, timeRange)
 	if sortErr != nil {
 		return allData, sortErr
 	}
@@ -218,7 +374,7 @@ func exclude(allData []*models.SingleData, pattern string) ([]*models.SingleData
 // Sort Functions
 
 func sortByAvg(allData []*models.SingleData, order string) ([]*models.SingleData, error) {
-	result, sortErr := sortCore(allData, "avg", order)
+	result, sortErr := sortCore(allData, "avg", order, 0, backend.TimeRange{})
 	if sortErr != nil {
 		return allData, sortErr
 	}
@@ -226,7 +382,7 @@ func sortByAvg(allData []*models.SingleData, order string) ([]*models.SingleData
 }
 
 func sortByMax(allData []*models.SingleData, order string) ([]*models.SingleData, error) {
-	result, sortErr := sortCore(allData, "max", order)
+	result, sortErr := sortCore(allData, "max", order, 0, backend.TimeRange{})
 	if sortErr != nil {
 		return allData, sortErr
 	}
@@ -234,7 +390,7 @@ func sortByMax(allData []*models.SingleData, order string) ([]*models.SingleData
 }
 
 func sortByMin(allData []*models.SingleData, order string) ([]*models.SingleData, error) {
-	result, sortErr := sortCore(allData, "min", order)
+	result, sortErr := sortCore(allData, "min", order, 0, backend.TimeRange{})
 	if sortErr != nil {
 		return allData, sortErr
 	}
@@ -242,7 +398,7 @@ func sortByMin(allData []*models.SingleData, order string) ([]*models.SingleData
 }
 
 func sortBySum(allData []*models.SingleData, order string) ([]*models.SingleData, error) {
-	result, sortErr := sortCore(allData, "sum", order)
+	result, sortErr := sortCore(allData, "sum", order, 0, backend.TimeRange{})
 	if sortErr != nil {
 		return allData, sortErr
 	}
@@ -250,7 +406,7 @@ func sortBySum(allData []*models.SingleData, order string) ([]*models.SingleData
 }
 
 func sortByAbsMax(allData []*models.SingleData, order string) ([]*models.SingleData, error) {
-	result, sortErr := sortCore(allData, "absoluteMax", order)
+	result, sortErr := sortCore(allData, "absoluteMax", order, 0, backend.TimeRange{})
 	if sortErr != nil {
 		return allData, sortErr
 	}
@@ -258,7 +414,15 @@ func sortByAbsMax(allData []*models.SingleData, order string) ([]*models.SingleD
 }
 
 func sortByAbsMin(allData []*models.SingleData, order string) ([]*models.SingleData, error) {
-	result, sortErr := sortCore(allData, "absoluteMin", order)
+	result, sortErr := sortCore(allData, "absoluteMin", order, 0, backend.TimeRange{})
+	if sortErr != nil {
+		return allData, sortErr
+	}
+	return result, nil
+}
+
+func sortByTimeWeightedAvg(allData []*models.SingleData, order string, timeRange backend.TimeRange) ([]*models.SingleData, error) {
+	result, sortErr := sortCore(allData, "timeWeightedAvg", order, 0, timeRange)
 	if sortErr != nil {
 		return allData, sortErr
 	}
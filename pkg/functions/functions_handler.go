@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/montanaflynn/stats"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
@@ -17,10 +18,13 @@ func ApplyFunctions(responseData []*models.SingleData, qm models.ArchiverQueryMo
 	newData := responseData
 	newData = applyArrayFunctions(newData, qm)
 
+	// Apply "Math" functions which calculate new series from the series
+	newData, mathErr := applyMathFunctions(newData, qm)
+
 	// Apply normal functions: Transform, Filter, Sort
-	newData = applyScalarFunctions(newData, qm)
+	newData, err := applyScalarFunctions(newData, qm)
 
-	return newData, nil
+	return newData, errors.Join(mathErr, err)
 }
 
 func applyArrayFunctions(responseData []*models.SingleData, qm models.ArchiverQueryModel) []*models.SingleData {
@@ -41,20 +45,24 @@ func applyArrayFunctions(responseData []*models.SingleData, qm models.ArchiverQu
 	return newData
 }
 
-func applyScalarFunctions(responseData []*models.SingleData, qm models.ArchiverQueryModel) []*models.SingleData {
+// applyScalarFunctions applies the functions in order. The data is passed to the next function unaltered
+// if a function fails, and the errors of the failed functions are returned together.
+func applyScalarFunctions(responseData []*models.SingleData, qm models.ArchiverQueryModel) ([]*models.SingleData, error) {
 	functions := qm.PickFuncsByCategories([]models.FunctionCategory{models.FUNC_CATEGORY_TRANSFORM, models.FUNC_CATEGORY_FILTER, models.FUNC_CATEGORY_SORT})
 	newData := responseData
 
+	var errs []error
 	for _, fdqm := range functions {
 		var err error
-		newData, err = functionSelector(newData, fdqm)
+		newData, err = functionSelector(newData, fdqm, qm.TimeRange)
 		if err != nil {
 			errMsg := fmt.Sprintf("Function %v has failed", fdqm.Def.Name)
-			log.DefaultLogger.Warn(errMsg)
+			log.DefaultLogger.Warn(errMsg, "error", err)
+			errs = append(errs, fmt.Errorf("function %v has failed: %w", fdqm.Def.Name, err))
 		}
 	}
 
-	return newData
+	return newData, errors.Join(errs...)
 }
 
 func arrayFunctionSelector(responseData []*models.SingleData, fdqm models.FunctionDescriptorQueryModel) ([]*models.SingleData, error) {
@@ -89,7 +97,7 @@ func arrayFunctionSelector(responseData []*models.SingleData, fdqm models.Functi
 
 	var newData []*models.SingleData
 	for _, oneData := range responseData {
-		values, ok := oneData.Values.(*models.Arrays)
+		values, ok := arraysOf(oneData.Values)
 		if !ok {
 			continue
 		}
@@ -113,7 +121,18 @@ func arrayFunctionSelector(responseData []*models.SingleData, fdqm models.Functi
 	return newData, nil
 }
 
-func functionSelector(responseData []*models.SingleData, fdqm models.FunctionDescriptorQueryModel) ([]*models.SingleData, error) {
+// arraysOf returns the numeric arrays of the data. The enum waveforms are converted into the indices of the states.
+func arraysOf(v models.Values) (*models.Arrays, bool) {
+	switch v := v.(type) {
+	case *models.Arrays:
+		return v, true
+	case *models.EnumArrays:
+		return v.ToArrays(), true
+	}
+	return nil, false
+}
+
+func functionSelector(responseData []*models.SingleData, fdqm models.FunctionDescriptorQueryModel, timeRange backend.TimeRange) ([]*models.SingleData, error) {
 	// Based on the name (as a string) of the function, select the actual function to be used
 	// If the function fails to apply, the data will be returned unaltered
 	name := fdqm.Def.Name
@@ -146,6 +165,43 @@ func functionSelector(responseData []*models.SingleData, fdqm models.FunctionDes
 		}
 		newData := movingAverage(responseData, windowSize)
 		return newData, nil
+	case "derivative", "nonNegativeDerivative":
+		unit, unitErr := fdqm.ExtractParamString("unit")
+		if unitErr != nil {
+			return responseData, unitErr
+		}
+		newData, err := derivative(responseData, unit, name == "nonNegativeDerivative")
+		if err != nil {
+			return responseData, err
+		}
+		return newData, nil
+	case "integral":
+		unit, unitErr := fdqm.ExtractParamString("unit")
+		if unitErr != nil {
+			return responseData, unitErr
+		}
+		newData, err := integral(responseData, unit)
+		if err != nil {
+			return responseData, err
+		}
+		return newData, nil
+	case "cumulativeSum":
+		newData := cumulativeSum(responseData)
+		return newData, nil
+	case "resample":
+		interval, intervalErr := fdqm.ExtractParamString("interval")
+		if intervalErr != nil {
+			return responseData, intervalErr
+		}
+		method, methodErr := fdqm.ExtractParamString("method")
+		if methodErr != nil {
+			return responseData, methodErr
+		}
+		newData, err := resample(responseData, interval, models.ResampleMethod(method), timeRange)
+		if err != nil {
+			return responseData, err
+		}
+		return newData, nil
 	case "top":
 		number, numberErr := fdqm.ExtractParamInt("number")
 		if numberErr != nil {
@@ -155,7 +211,11 @@ func functionSelector(responseData []*models.SingleData, fdqm models.FunctionDes
 		if valueErr != nil {
 			return responseData, valueErr
 		}
-		newData, err := top(responseData, number, value)
+		threshold, thresholdErr := extractThreshold(fdqm)
+		if thresholdErr != nil {
+			return responseData, thresholdErr
+		}
+		newData, err := top(responseData, number, value, threshold, timeRange)
 		if err != nil {
 			return responseData, err
 		}
@@ -169,7 +229,11 @@ func functionSelector(responseData []*models.SingleData, fdqm models.FunctionDes
 		if valueErr != nil {
 			return responseData, valueErr
 		}
-		newData, err := bottom(responseData, number, value)
+		threshold, thresholdErr := extractThreshold(fdqm)
+		if thresholdErr != nil {
+			return responseData, thresholdErr
+		}
+		newData, err := bottom(responseData, number, value, threshold, timeRange)
 		if err != nil {
 			return responseData, err
 		}
@@ -244,6 +308,16 @@ func functionSelector(responseData []*models.SingleData, fdqm models.FunctionDes
 			return responseData, err
 		}
 		return newData, nil
+	case "sortByTimeWeightedAvg":
+		order, orderErr := fdqm.ExtractParamString("order")
+		if orderErr != nil {
+			return responseData, orderErr
+		}
+		newData, err := sortByTimeWeightedAvg(responseData, order, timeRange)
+		if err != nil {
+			return responseData, err
+		}
+		return newData, nil
 	default:
 		errMsg := fmt.Sprintf("Function %v is not a recognized function", name)
 		log.DefaultLogger.Warn(errMsg)
@@ -253,3 +327,12 @@ func functionSelector(responseData []*models.SingleData, fdqm models.FunctionDes
 	// this should never be reached
 	// return responseData, nil
 }
+
+// extractThreshold returns the threshold of top and bottom.
+// The functions saved before the threshold was added don't have it, so it defaults to 0.
+func extractThreshold(fdqm models.FunctionDescriptorQueryModel) (float64, error) {
+	if _, err := fdqm.GetParamTypeByName("threshold"); err != nil {
+		return 0, nil
+	}
+	return fdqm.ExtractParamFloat64("threshold")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
 )
@@ -234,6 +235,57 @@ func TestApplyFunctions(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Top with threshold test",
+			inputSd: []*models.SingleData{
+				{
+					Values: &models.Scalars{
+						Times:  testhelper.TimeArrayHelper(0, 6),
+						Values: testhelper.InitFloat64SlicePointer([]float64{1, 1, 2, 3, 5, 8}),
+					},
+				},
+				{
+					Values: &models.Scalars{
+						Times:  testhelper.TimeArrayHelper(0, 6),
+						Values: testhelper.InitFloat64SlicePointer([]float64{4, 4, 4, 4, 4, 4}),
+					},
+				},
+			},
+			inputAqm: models.ArchiverQueryModel{
+				Functions: []models.FunctionDescriptorQueryModel{
+					{
+						Def: models.FuncDefQueryModel{
+							Category: "Filter Series",
+							Name:     "top",
+							Params: []models.FuncDefParamQueryModel{
+								{
+									Name: "number",
+									Type: "int",
+								},
+								{
+									Name: "value",
+									Type: "string",
+								},
+								{
+									Name: "threshold",
+									Type: "float",
+								},
+							},
+						},
+						Params: []string{"1", "dutyCycle", "3.5"},
+					},
+				},
+				TimeRange: backend.TimeRange{From: testhelper.TimeHelper(1), To: testhelper.TimeHelper(6)},
+			},
+			output: []*models.SingleData{
+				{
+					Values: &models.Scalars{
+						Times:  testhelper.TimeArrayHelper(0, 6),
+						Values: testhelper.InitFloat64SlicePointer([]float64{4, 4, 4, 4, 4, 4}),
+					},
+				},
+			},
+		},
 	}
 
 	for tdx, testCase := range tests {
@@ -466,7 +518,7 @@ func TestFunctionSelector(t *testing.T) {
 	for tdx, testCase := range tests {
 		testName := fmt.Sprintf("case %d: %v", tdx, testCase.output)
 		t.Run(testName, func(t *testing.T) {
-			result, err := functionSelector(tests[tdx].inputSd, testCase.inputFdqm)
+			result, err := functionSelector(tests[tdx].inputSd, testCase.inputFdqm, backend.TimeRange{})
 			if err != nil {
 				t.Errorf("An error has been generated")
 			}
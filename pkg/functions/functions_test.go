@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
 	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
 )
@@ -550,7 +552,7 @@ func TestTop(t *testing.T) {
 	for tdx, testCase := range tests {
 		testName := fmt.Sprintf("case %d: %v", tdx, testCase.value)
 		t.Run(testName, func(t *testing.T) {
-			result, err := top(testCase.inputSd, testCase.number, testCase.value)
+			result, err := top(testCase.inputSd, testCase.number, testCase.value, 0, backend.TimeRange{})
 			if err != nil {
 				t.Errorf("Error not expected %v", err)
 			}
@@ -744,7 +746,7 @@ func TestBottom(t *testing.T) {
 	for tdx, testCase := range tests {
 		testName := fmt.Sprintf("case %d: %v", tdx, testCase.value)
 		t.Run(testName, func(t *testing.T) {
-			result, err := bottom(testCase.inputSd, testCase.number, testCase.value)
+			result, err := bottom(testCase.inputSd, testCase.number, testCase.value, 0, backend.TimeRange{})
 			if err != nil {
 				t.Errorf("Error not expected %v", err)
 			}
@@ -1351,3 +1353,422 @@ func TestSorByAbsMin(t *testing.T) {
 		})
 	}
 }
+
+func TestResample(t *testing.T) {
+	// Samples at 0, 2 and 3 minutes and a null value at 5 minutes
+	input := func() *models.Scalars {
+		return &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(2), testhelper.TimeHelper(3), testhelper.TimeHelper(5)},
+			Values: append(testhelper.InitFloat64SlicePointer([]float64{0, 4, 10}), nil),
+		}
+	}
+	// The first series has samples at 1 and 4 minutes
+	first := &models.Scalars{
+		Times:  []time.Time{testhelper.TimeHelper(1), testhelper.TimeHelper(4)},
+		Values: testhelper.InitFloat64SlicePointer([]float64{1, 1}),
+	}
+	p := func(v float64) *float64 { return &v }
+	// The times of the interval are placed over the time range out of the samples
+	timeRange := backend.TimeRange{From: testhelper.TimeHelper(-1), To: testhelper.TimeHelper(6)}
+
+	var tests = []struct {
+		interval string
+		method   models.ResampleMethod
+		output   *models.Scalars
+	}{
+		{
+			interval: "1m",
+			method:   models.RESAMPLE_STEP,
+			output:   &models.Scalars{Times: testhelper.TimeArrayHelper(-2, 6), Values: []*float64{nil, p(0), p(0), p(4), p(10), p(10), nil, nil}},
+		},
+		{
+			interval: "1m",
+			method:   models.RESAMPLE_LINEAR,
+			output:   &models.Scalars{Times: testhelper.TimeArrayHelper(-2, 6), Values: []*float64{nil, p(0), p(2), p(4), p(10), nil, nil, nil}},
+		},
+		{
+			interval: "90s",
+			method:   models.RESAMPLE_NEAREST,
+			output:   &models.Scalars{Times: []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(0).Add(90 * time.Second), testhelper.TimeHelper(3), testhelper.TimeHelper(3).Add(90 * time.Second), testhelper.TimeHelper(6)}, Values: []*float64{p(0), p(4), p(10), nil, nil}},
+		},
+		{
+			interval: "first",
+			method:   models.RESAMPLE_STEP,
+			output:   &models.Scalars{Times: first.Times, Values: []*float64{p(0), p(10)}},
+		},
+		{
+			interval: "first",
+			method:   models.RESAMPLE_LINEAR,
+			output:   &models.Scalars{Times: first.Times, Values: []*float64{p(2), nil}},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.interval+"/"+string(testCase.method), func(t *testing.T) {
+			firstSd := &models.SingleData{Name: "FIRST", Values: &models.Scalars{Times: first.Times, Values: first.Values}}
+			sD := &models.SingleData{Name: "TEST:PV:NAME", Values: input()}
+
+			result, err := resample([]*models.SingleData{firstSd, sD}, testCase.interval, testCase.method, timeRange)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.output, result[1].Values); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResampleError(t *testing.T) {
+	timeRange := backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(3)}
+
+	var tests = []struct {
+		name      string
+		interval  string
+		method    models.ResampleMethod
+		timeRange backend.TimeRange
+	}{
+		{name: "bad interval", interval: "10", method: models.RESAMPLE_STEP, timeRange: timeRange},
+		{name: "negative interval", interval: "-1m", method: models.RESAMPLE_STEP, timeRange: timeRange},
+		{name: "too many points", interval: "1ns", method: models.RESAMPLE_STEP, timeRange: timeRange},
+		{name: "bad method", interval: "1m", method: "cubic", timeRange: timeRange},
+		{name: "empty time range", interval: "1m", method: models.RESAMPLE_STEP, timeRange: backend.TimeRange{}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			values := models.NewAlarmScalars(3)
+			for idx, val := range []float64{1, 2, 3} {
+				values.Append(&val, 0, 0, testhelper.TimeHelper(idx))
+			}
+			sD := &models.SingleData{Name: "TEST:PV:NAME", Values: values, SampleMeta: models.NewSampleMetadata()}
+
+			if _, err := resample([]*models.SingleData{sD}, testCase.interval, testCase.method, testCase.timeRange); err == nil {
+				t.Errorf("Error should be returned")
+			}
+			// The severity, status and metadata of the samples are kept
+			if sD.Values != values || sD.SampleMeta == nil {
+				t.Errorf("The data should be unaltered: %T, %v", sD.Values, sD.SampleMeta)
+			}
+			if diff := cmp.Diff(testhelper.TimeArrayHelper(-1, 2), values.Scalars.Times); diff != "" {
+				t.Errorf("The data should be unaltered (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDerivative(t *testing.T) {
+	p := func(v float64) *float64 { return &v }
+	// Samples at 0, 1, 3 and 4 minutes with a null value at 5 minutes and a reset at 6 minutes
+	input := func() *models.Scalars {
+		return &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(1), testhelper.TimeHelper(3), testhelper.TimeHelper(4), testhelper.TimeHelper(5), testhelper.TimeHelper(6)},
+			Values: []*float64{p(0), p(60), p(180), p(120), nil, p(0)},
+		}
+	}
+	times := []time.Time{testhelper.TimeHelper(1), testhelper.TimeHelper(3), testhelper.TimeHelper(4), testhelper.TimeHelper(5), testhelper.TimeHelper(6)}
+
+	var tests = []struct {
+		name        string
+		unit        string
+		nonNegative bool
+		output      *models.Scalars
+	}{
+		{name: "per second", unit: "1s", output: &models.Scalars{Times: times, Values: []*float64{p(1), p(1), p(-1), nil, p(-1)}}},
+		{name: "per minute", unit: "1m", output: &models.Scalars{Times: times, Values: []*float64{p(60), p(60), p(-60), nil, p(-60)}}},
+		{name: "non negative", unit: "1s", nonNegative: true, output: &models.Scalars{Times: times, Values: []*float64{p(1), p(1), nil, nil, nil}}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			sD := &models.SingleData{Name: "TEST:PV:NAME", Values: input()}
+			result, err := derivative([]*models.SingleData{sD}, testCase.unit, testCase.nonNegative)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.output, result[0].Values); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := derivative([]*models.SingleData{{Values: input()}}, "second", false); err == nil {
+		t.Errorf("Error should be returned for a bad unit")
+	}
+}
+
+func TestIntegral(t *testing.T) {
+	p := func(v float64) *float64 { return &v }
+	// A beam current of 1 to 3 mA for 3 minutes with a null value at 2 minutes
+	input := func() *models.Scalars {
+		return &models.Scalars{
+			Times:  testhelper.TimeArrayHelper(-1, 4),
+			Values: []*float64{p(1), p(3), nil, p(3), p(1)},
+		}
+	}
+
+	var tests = []struct {
+		unit   string
+		output []*float64
+	}{
+		{unit: "1s", output: []*float64{p(0), p(120), p(120), p(120), p(240)}},
+		{unit: "1h", output: []*float64{p(0), p(120.0 / 3600), p(120.0 / 3600), p(120.0 / 3600), p(240.0 / 3600)}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.unit, func(t *testing.T) {
+			sD := &models.SingleData{Name: "TEST:PV:NAME", Values: input()}
+			result, err := integral([]*models.SingleData{sD}, testCase.unit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			output := &models.Scalars{Times: testhelper.TimeArrayHelper(-1, 4), Values: testCase.output}
+			if diff := cmp.Diff(output, result[0].Values); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCumulativeSum(t *testing.T) {
+	p := func(v float64) *float64 { return &v }
+	sD := &models.SingleData{
+		Name:   "TEST:PV:NAME",
+		Values: &models.Scalars{Times: testhelper.TimeArrayHelper(0, 4), Values: []*float64{p(1), nil, p(2), p(-4)}},
+	}
+
+	result := cumulativeSum([]*models.SingleData{sD})
+	output := &models.Scalars{Times: testhelper.TimeArrayHelper(0, 4), Values: []*float64{p(1), nil, p(3), p(-1)}}
+	if diff := cmp.Diff(output, result[0].Values); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFilterIndexerTimeWeighted(t *testing.T) {
+	// The flickering series is 0 for 10 minutes and then 10 for 2 minutes in total
+	flicker := &models.SingleData{
+		Values: &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(10), testhelper.TimeHelper(11), testhelper.TimeHelper(12), testhelper.TimeHelper(13)},
+			Values: testhelper.InitFloat64SlicePointer([]float64{0, 10, 0, 10, 0}),
+		},
+	}
+	withNull := &models.SingleData{
+		Values: &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(1), testhelper.TimeHelper(3)},
+			Values: []*float64{testhelper.InitFloat64SlicePointer([]float64{5})[0], nil, testhelper.InitFloat64SlicePointer([]float64{1})[0]},
+		},
+	}
+	single := &models.SingleData{
+		Values: &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(0)},
+			Values: testhelper.InitFloat64SlicePointer([]float64{7}),
+		},
+	}
+	// The first sample before the time range only counts from the start of the time range
+	beforeFrom := &models.SingleData{
+		Values: &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(-10), testhelper.TimeHelper(3)},
+			Values: testhelper.InitFloat64SlicePointer([]float64{100, 1}),
+		},
+	}
+	allData := []*models.SingleData{flicker, withNull, single, beforeFrom}
+	// The last samples are held until the end of the time range
+	timeRange := backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(13)}
+
+	var tests = []struct {
+		value     string
+		threshold float64
+		output    []float64
+	}{
+		{value: "avg", output: []float64{4, 3, 7, 50.5}},
+		{value: "timeWeightedAvg", output: []float64{1200.0 / 780, 900.0 / 660, 7, 18600.0 / 780}},
+		{value: "timeAboveThreshold", threshold: 5, output: []float64{120, 0, 780, 180}},
+		{value: "timeAboveThreshold", threshold: 0, output: []float64{120, 660, 780, 780}},
+		{value: "dutyCycle", threshold: 5, output: []float64{120.0 / 780, 0, 1, 180.0 / 780}},
+		{value: "dutyCycle", threshold: 0, output: []float64{120.0 / 780, 1, 1, 1}},
+	}
+	for _, testCase := range tests {
+		testName := fmt.Sprintf("%v: %v", testCase.value, testCase.threshold)
+		t.Run(testName, func(t *testing.T) {
+			result, err := filterIndexer(allData, testCase.value, testCase.threshold, timeRange)
+			if err != nil {
+				t.Fatalf("Error not expected %v", err)
+			}
+			if diff := cmp.Diff(testCase.output, result); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSortByTimeWeightedAvg(t *testing.T) {
+	// The plain average of the flickering series is higher, but the time-weighted average is lower
+	flicker := &models.SingleData{
+		Values: &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(10), testhelper.TimeHelper(11), testhelper.TimeHelper(12), testhelper.TimeHelper(13)},
+			Values: testhelper.InitFloat64SlicePointer([]float64{0, 10, 0, 10, 0}),
+		},
+	}
+	constant := &models.SingleData{
+		Values: &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(13)},
+			Values: testhelper.InitFloat64SlicePointer([]float64{3, 3}),
+		},
+	}
+
+	timeRange := backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(13)}
+
+	var tests = []struct {
+		order  string
+		output []*models.SingleData
+	}{
+		{order: "desc", output: []*models.SingleData{constant, flicker}},
+		{order: "asc", output: []*models.SingleData{flicker, constant}},
+	}
+	for tdx, testCase := range tests {
+		testName := fmt.Sprintf("case %d: %v", tdx, testCase.order)
+		t.Run(testName, func(t *testing.T) {
+			result, err := sortByTimeWeightedAvg([]*models.SingleData{flicker, constant}, testCase.order, timeRange)
+			if err != nil {
+				t.Errorf("Error not expected %v", err)
+			}
+			models.SingleDataCompareHelper(result, testCase.output, t)
+		})
+	}
+
+	result, err := top([]*models.SingleData{flicker, constant}, 1, "dutyCycle", 5, timeRange)
+	if err != nil {
+		t.Fatalf("Error not expected %v", err)
+	}
+	models.SingleDataCompareHelper(result, []*models.SingleData{flicker}, t)
+}
+
+func TestAlarmScalarsFunctions(t *testing.T) {
+	alarmData := func() *models.SingleData {
+		v := models.NewAlarmScalars(3)
+		for idx, val := range []float64{1, 3, 6} {
+			val := val
+			v.Append(&val, int16(idx), int16(idx), testhelper.TimeHelper(idx))
+		}
+		return &models.SingleData{Values: v}
+	}
+
+	t.Run("scale keeps the severity and status", func(t *testing.T) {
+		result := scale([]*models.SingleData{alarmData()}, 2)
+		v, ok := result[0].Values.(*models.AlarmScalars)
+		if !ok {
+			t.Fatalf("The data should be AlarmScalars: %T", result[0].Values)
+		}
+		output := &models.Scalars{Times: testhelper.TimeArrayHelper(-1, 2), Values: testhelper.InitFloat64SlicePointer([]float64{2, 6, 12})}
+		if diff := cmp.Diff(output, v.Scalars); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+		if len(v.Severity.Values) != 3 || len(v.Status.Values) != 3 {
+			t.Errorf("The severity and status should be kept: %v %v", v.Severity.Values, v.Status.Values)
+		}
+	})
+
+	t.Run("delta drops the severity and status", func(t *testing.T) {
+		result := delta([]*models.SingleData{alarmData()})
+		output := &models.Scalars{Times: testhelper.TimeArrayHelper(0, 2), Values: testhelper.InitFloat64SlicePointer([]float64{2, 3})}
+		if diff := cmp.Diff(output, result[0].Values); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("rank by the value", func(t *testing.T) {
+		rank, err := filterIndexer([]*models.SingleData{alarmData()}, "max", 0, backend.TimeRange{})
+		if err != nil {
+			t.Fatalf("Error not expected %v", err)
+		}
+		if diff := cmp.Diff([]float64{6}, rank); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestStatisticsFunctions(t *testing.T) {
+	statsData := func() *models.SingleData {
+		v := models.NewStatistics(3)
+		for idx, val := range []float64{1, 3, 6} {
+			v.Append(val, 0.5, val-1, val+1, 4, testhelper.TimeHelper(idx))
+		}
+		return &models.SingleData{Values: v}
+	}
+
+	t.Run("scale drops the statistics except the value", func(t *testing.T) {
+		result := scale([]*models.SingleData{statsData()}, 2)
+		output := &models.Scalars{Times: testhelper.TimeArrayHelper(-1, 2), Values: testhelper.InitFloat64SlicePointer([]float64{2, 6, 12})}
+		if diff := cmp.Diff(output, result[0].Values); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("delta", func(t *testing.T) {
+		result := delta([]*models.SingleData{statsData()})
+		output := &models.Scalars{Times: testhelper.TimeArrayHelper(0, 2), Values: testhelper.InitFloat64SlicePointer([]float64{2, 3})}
+		if diff := cmp.Diff(output, result[0].Values); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("rank by the value", func(t *testing.T) {
+		rank, err := filterIndexer([]*models.SingleData{statsData()}, "max", 0, backend.TimeRange{})
+		if err != nil {
+			t.Fatalf("Error not expected %v", err)
+		}
+		if diff := cmp.Diff([]float64{6}, rank); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestTransformSampleMeta(t *testing.T) {
+	var tests = []struct {
+		name      string
+		transform func([]*models.SingleData) []*models.SingleData
+		kept      bool
+	}{
+		{name: "scale", transform: func(d []*models.SingleData) []*models.SingleData { return scale(d, 2) }, kept: true},
+		{name: "movingAverage", transform: func(d []*models.SingleData) []*models.SingleData { return movingAverage(d, 2) }, kept: true},
+		{name: "delta", transform: delta, kept: false},
+		{
+			name: "derivative",
+			transform: func(d []*models.SingleData) []*models.SingleData {
+				r, _ := derivative(d, "1s", false)
+				return r
+			},
+			kept: false,
+		},
+		{
+			name: "resample",
+			transform: func(d []*models.SingleData) []*models.SingleData {
+				r, _ := resample(d, "30s", models.RESAMPLE_STEP, backend.TimeRange{From: testhelper.TimeHelper(0), To: testhelper.TimeHelper(3)})
+				return r
+			},
+			kept: false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			count := int64(2)
+			m := models.NewSampleMetadata()
+			for range 3 {
+				m.Append(&count, nil, nil)
+			}
+			sD := &models.SingleData{
+				Values: &models.Scalars{
+					Times:  testhelper.TimeArrayHelper(0, 3),
+					Values: testhelper.InitFloat64SlicePointer([]float64{1, 2, 4}),
+				},
+				SampleMeta: m,
+			}
+
+			result := testCase.transform([]*models.SingleData{sD})
+			if kept := result[0].SampleMeta != nil; kept != testCase.kept {
+				t.Errorf("got %v, want %v", kept, testCase.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+)
+
+// mathExpr is a node of the parsed math expression.
+// eval returns false if the value is not available, e.g. a reference to a null sample.
+type mathExpr interface {
+	eval(vars map[string]float64) (float64, bool)
+}
+
+type mathNumber float64
+
+func (n mathNumber) eval(vars map[string]float64) (float64, bool) {
+	return float64(n), true
+}
+
+// mathRef refers the series by its name or PV name
+type mathRef string
+
+func (r mathRef) eval(vars map[string]float64) (float64, bool) {
+	v, ok := vars[string(r)]
+	return v, ok
+}
+
+type mathNeg struct {
+	x mathExpr
+}
+
+func (n mathNeg) eval(vars map[string]float64) (float64, bool) {
+	v, ok := n.x.eval(vars)
+	return -v, ok
+}
+
+type mathBinary struct {
+	op    byte
+	left  mathExpr
+	right mathExpr
+}
+
+func (b mathBinary) eval(vars map[string]float64) (float64, bool) {
+	l, lok := b.left.eval(vars)
+	r, rok := b.right.eval(vars)
+	if !lok || !rok {
+		return 0, false
+	}
+
+	switch b.op {
+	case '+':
+		return l + r, true
+	case '-':
+		return l - r, true
+	case '*':
+		return l * r, true
+	case '/':
+		return l / r, true
+	}
+
+	return 0, false
+}
+
+// mathParser is a recursive descent parser of the math expression.
+// The series are referenced as #name, or #{name} if the name has the characters other than letters, digits, "_", ":" and ".".
+// "#" is used instead of "$" which Grafana replaces with the template variables.
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = unary { ("*" | "/") unary }
+//	unary  = "-" unary | factor
+//	factor = number | "#" name | "#{" name "}" | "(" expr ")"
+type mathParser struct {
+	input string
+	pos   int
+	refs  []string
+}
+
+func parseMathExpression(input string) (mathExpr, []string, error) {
+	p := &mathParser{input: input}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, nil, fmt.Errorf("unexpected %q at %d", p.input[p.pos], p.pos)
+	}
+	if len(p.refs) == 0 {
+		return nil, nil, errors.New("expression has no series references")
+	}
+
+	return expr, p.refs, nil
+}
+
+func (p *mathParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// next returns the next character without consuming it. It returns 0 at the end of the input.
+func (p *mathParser) next() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *mathParser) parseExpr() (mathExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.next(); op == '+' || op == '-'; op = p.next() {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = mathBinary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *mathParser) parseTerm() (mathExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.next(); op == '*' || op == '/'; op = p.next() {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = mathBinary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *mathParser) parseUnary() (mathExpr, error) {
+	if p.next() == '-' {
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return mathNeg{x: x}, nil
+	}
+
+	return p.parseFactor()
+}
+
+func (p *mathParser) parseFactor() (mathExpr, error) {
+	switch c := p.next(); {
+	case c == 0:
+		return nil, errors.New("unexpected end of expression")
+	case c == '(':
+		p.pos++
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ')' {
+			return nil, fmt.Errorf("missing ')' at %d", p.pos)
+		}
+		p.pos++
+		return expr, nil
+	case c == '#':
+		p.pos++
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		p.refs = append(p.refs, name)
+		return mathRef(name), nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.input) && strings.IndexByte("0123456789.eE", p.input[p.pos]) >= 0 {
+			// The sign of the exponent
+			if (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') && p.pos+1 < len(p.input) && strings.IndexByte("+-", p.input[p.pos+1]) >= 0 {
+				p.pos++
+			}
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return mathNumber(v), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", c, p.pos)
+	}
+}
+
+func isMathNameChar(c byte) bool {
+	return c == '_' || c == ':' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *mathParser) parseName() (string, error) {
+	if p.pos < len(p.input) && p.input[p.pos] == '{' {
+		end := strings.IndexByte(p.input[p.pos:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("missing '}' at %d", p.pos)
+		}
+		name := p.input[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		if name == "" {
+			return "", errors.New("empty series name")
+		}
+		return name, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && isMathNameChar(p.input[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", fmt.Errorf("missing series name at %d", p.pos)
+	}
+
+	return p.input[start:p.pos], nil
+}
+
+// findSeries returns the scalar data referenced by the name. The name or alias is preferred to the PV name.
+func findSeries(responseData []*models.SingleData, name string) (*models.Scalars, error) {
+	var found *models.SingleData
+	for _, sD := range responseData {
+		if sD.Name == name {
+			found = sD
+			break
+		}
+	}
+	if found == nil {
+		for _, sD := range responseData {
+			if sD.PVname == name {
+				found = sD
+				break
+			}
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("series %q is not found", name)
+	}
+
+	v, ok := models.ScalarsOf(found.Values)
+	if !ok {
+		return nil, fmt.Errorf("series %q is not scalar data", name)
+	}
+
+	return v, nil
+}
+
+// evalMath evaluates the expression at the timestamps of all the referenced series.
+// The value of each series is held until its next sample like the archiver, so the timestamps don't have to match.
+// The result starts when all series have a sample, and it is null if any of the held values is null.
+func evalMath(expr mathExpr, series map[string]*models.Scalars) *models.Scalars {
+	var times []time.Time
+	for _, s := range series {
+		times = append(times, s.Times...)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	result := models.NewSclars(len(times))
+	indexes := make(map[string]int, len(series))
+	for name := range series {
+		indexes[name] = -1
+	}
+
+	for idx, t := range times {
+		if idx > 0 && t.Equal(times[idx-1]) {
+			continue
+		}
+
+		vars := make(map[string]float64, len(series))
+		started := true
+		for name, s := range series {
+			i := indexes[name]
+			for i+1 < len(s.Times) && !s.Times[i+1].After(t) {
+				i++
+			}
+			indexes[name] = i
+
+			if i < 0 {
+				started = false
+				continue
+			}
+			if s.Values[i] != nil {
+				vars[name] = *s.Values[i]
+			}
+		}
+		if !started {
+			continue
+		}
+
+		v, ok := expr.eval(vars)
+		if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+			result.Append(nil, t)
+			continue
+		}
+		result.AppendConcrete(v, t)
+	}
+
+	return result
+}
+
+// mathSeries evaluates the expression of the series and returns the data with the result appended
+func mathSeries(responseData []*models.SingleData, expression string, alias string) ([]*models.SingleData, error) {
+	expr, refs, err := parseMathExpression(expression)
+	if err != nil {
+		return responseData, fmt.Errorf("failed to parse %q: %w", expression, err)
+	}
+
+	series := make(map[string]*models.Scalars, len(refs))
+	for _, name := range refs {
+		s, err := findSeries(responseData, name)
+		if err != nil {
+			return responseData, err
+		}
+		series[name] = s
+	}
+
+	name := alias
+	if name == "" {
+		name = expression
+	}
+
+	sD := &models.SingleData{
+		Name:   name,
+		PVname: name,
+		Values: evalMath(expr, series),
+	}
+
+	return append(responseData, sD), nil
+}
+
+func applyMathFunctions(responseData []*models.SingleData, qm models.ArchiverQueryModel) ([]*models.SingleData, error) {
+	functions := qm.PickFuncsByCategories([]models.FunctionCategory{models.FUNC_CATEGORY_MATH})
+	newData := responseData
+
+	var errs []error
+	for _, fdqm := range functions {
+		expression, err := fdqm.ExtractParamString("expression")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("function %v has failed: %w", fdqm.Def.Name, err))
+			continue
+		}
+		alias, err := fdqm.ExtractParamString("alias")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("function %v has failed: %w", fdqm.Def.Name, err))
+			continue
+		}
+
+		newData, err = mathSeries(newData, expression, alias)
+		if err != nil {
+			log.DefaultLogger.Warn(fmt.Sprintf("Function %v has failed", fdqm.Def.Name), "Error", err)
+			errs = append(errs, fmt.Errorf("function %v has failed: %w", fdqm.Def.Name, err))
+		}
+	}
+
+	return newData, errors.Join(errs...)
+}
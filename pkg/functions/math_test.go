@@ -0,0 +1,219 @@
+package functions
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/models"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
+)
+
+func TestParseMathExpression(t *testing.T) {
+	vars := map[string]float64{"A": 6, "PV:B": 2, "PV-C": 3}
+
+	var tests = []struct {
+		expression string
+		refs       []string
+		output     float64
+	}{
+		{expression: "#A - #PV:B", refs: []string{"A", "PV:B"}, output: 4},
+		{expression: "#A-#{PV-C}", refs: []string{"A", "PV-C"}, output: 3},
+		{expression: "#A + #PV:B * #{PV-C}", refs: []string{"A", "PV:B", "PV-C"}, output: 12},
+		{expression: "(#A + #PV:B) / 4", refs: []string{"A", "PV:B"}, output: 2},
+		{expression: "-#A * -2", refs: []string{"A"}, output: 12},
+		{expression: "#A / 1e-1 - 2.5", refs: []string{"A"}, output: 57.5},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.expression, func(t *testing.T) {
+			expr, refs, err := parseMathExpression(testCase.expression)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(testCase.refs, refs); diff != "" {
+				t.Errorf("Refs mismatch (-want +got):\n%s", diff)
+			}
+			if v, ok := expr.eval(vars); !ok || v != testCase.output {
+				t.Errorf("got %v, want %v", v, testCase.output)
+			}
+		})
+	}
+}
+
+func TestParseMathExpressionError(t *testing.T) {
+	for _, expression := range []string{"", "1 + 2", "#A +", "(#A", "#{A", "# + 1", "#A #B", "#A % 2", "$A + 1"} {
+		t.Run(expression, func(t *testing.T) {
+			if _, _, err := parseMathExpression(expression); err == nil {
+				t.Errorf("Error should be returned")
+			}
+		})
+	}
+}
+
+func TestMathSeries(t *testing.T) {
+	// A has the samples at 0, 2 and 4 minutes, and PV:B has the samples at 1 and 2 minutes and a null value at 3 minutes
+	a := &models.SingleData{
+		Name:   "A",
+		PVname: "PV:A",
+		Values: &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(2), testhelper.TimeHelper(4)},
+			Values: testhelper.InitFloat64SlicePointer([]float64{10, 20, 30}),
+		},
+	}
+	b := &models.SingleData{
+		Name:   "PV:B",
+		PVname: "PV:B",
+		Values: &models.Scalars{
+			Times:  []time.Time{testhelper.TimeHelper(1), testhelper.TimeHelper(2), testhelper.TimeHelper(3)},
+			Values: append(testhelper.InitFloat64SlicePointer([]float64{1, 2}), nil),
+		},
+	}
+
+	var tests = []struct {
+		name       string
+		expression string
+		alias      string
+		output     *models.SingleData
+	}{
+		{
+			name:       "sample and hold",
+			expression: "#A - #PV:B",
+			output: &models.SingleData{
+				Name:   "#A - #PV:B",
+				PVname: "#A - #PV:B",
+				Values: &models.Scalars{
+					Times:  []time.Time{testhelper.TimeHelper(1), testhelper.TimeHelper(2), testhelper.TimeHelper(3), testhelper.TimeHelper(4)},
+					Values: []*float64{testhelper.InitFloat64SlicePointer([]float64{9})[0], testhelper.InitFloat64SlicePointer([]float64{18})[0], nil, nil},
+				},
+			},
+		},
+		{
+			name:       "PV name and alias",
+			expression: "#PV:A / 10",
+			alias:      "ratio",
+			output: &models.SingleData{
+				Name:   "ratio",
+				PVname: "ratio",
+				Values: &models.Scalars{
+					Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(2), testhelper.TimeHelper(4)},
+					Values: testhelper.InitFloat64SlicePointer([]float64{1, 2, 3}),
+				},
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := mathSeries([]*models.SingleData{a, b}, testCase.expression, testCase.alias)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(result) != 3 || result[0] != a || result[1] != b {
+				t.Fatalf("The original series should be kept: %v", result)
+			}
+			if diff := cmp.Diff(testCase.output, result[2]); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMathSeriesError(t *testing.T) {
+	a := &models.SingleData{Name: "A", PVname: "PV:A", Values: &models.Scalars{}}
+	s := &models.SingleData{Name: "S", PVname: "PV:S", Values: &models.Strings{}}
+
+	for _, expression := range []string{"#B + 1", "#S + 1", "#A +"} {
+		t.Run(expression, func(t *testing.T) {
+			result, err := mathSeries([]*models.SingleData{a, s}, expression, "")
+			if err == nil {
+				t.Errorf("Error should be returned")
+			}
+			if len(result) != 2 {
+				t.Errorf("The data should be returned unaltered: %v", result)
+			}
+		})
+	}
+}
+
+func TestApplyMathFunctions(t *testing.T) {
+	a := &models.SingleData{Name: "A", Values: &models.Scalars{Times: testhelper.TimeArrayHelper(0, 2), Values: testhelper.InitFloat64SlicePointer([]float64{1, 2})}}
+	b := &models.SingleData{Name: "B", Values: &models.Scalars{Times: testhelper.TimeArrayHelper(0, 2), Values: testhelper.InitFloat64SlicePointer([]float64{3, 4})}}
+
+	qm := models.ArchiverQueryModel{
+		Functions: []models.FunctionDescriptorQueryModel{
+			{
+				Def: models.FuncDefQueryModel{
+					Category: "Math",
+					Name:     "math",
+					Params: []models.FuncDefParamQueryModel{
+						{Name: "expression", Type: "string"},
+						{Name: "alias", Type: "string"},
+					},
+				},
+				Params: []string{"#A + #B", "sum"},
+			},
+			{
+				Def: models.FuncDefQueryModel{
+					Category: "Transform",
+					Name:     "scale",
+					Params: []models.FuncDefParamQueryModel{
+						{Name: "factor", Type: "float"},
+					},
+				},
+				Params: []string{"10"},
+			},
+		},
+	}
+
+	result, err := ApplyFunctions([]*models.SingleData{a, b}, qm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The transform functions are applied to the result of the math
+	output := &models.Scalars{Times: testhelper.TimeArrayHelper(0, 2), Values: testhelper.InitFloat64SlicePointer([]float64{40, 60})}
+	if len(result) != 3 || result[2].Name != "sum" {
+		t.Fatalf("Unexpected result: %v", result)
+	}
+	if diff := cmp.Diff(output, result[2].Values); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyMathFunctionsError(t *testing.T) {
+	a := &models.SingleData{Name: "A", Values: &models.Scalars{Times: testhelper.TimeArrayHelper(0, 2), Values: testhelper.InitFloat64SlicePointer([]float64{1, 2})}}
+	s := &models.SingleData{Name: "S", Values: &models.Strings{Times: testhelper.TimeArrayHelper(0, 2), Values: []string{"a", "b"}}}
+
+	mathFunc := func(expression string) models.FunctionDescriptorQueryModel {
+		return models.FunctionDescriptorQueryModel{
+			Def: models.FuncDefQueryModel{
+				Category: "Math",
+				Name:     "math",
+				Params: []models.FuncDefParamQueryModel{
+					{Name: "expression", Type: "string"},
+					{Name: "alias", Type: "string"},
+				},
+			},
+			Params: []string{expression, "result"},
+		}
+	}
+	qm := models.ArchiverQueryModel{
+		Functions: []models.FunctionDescriptorQueryModel{mathFunc("#A +"), mathFunc("#B * 2"), mathFunc("#S * 2"), mathFunc("#A * 2")},
+	}
+
+	// The failed functions are reported and the others are applied
+	result, err := ApplyFunctions([]*models.SingleData{a, s}, qm)
+	if err == nil {
+		t.Fatalf("Error should be returned")
+	}
+	for _, msg := range []string{"#A +", `"B"`, `"S"`} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Error should contain %s: %v", msg, err)
+		}
+	}
+	if len(result) != 3 || result[2].Name != "result" {
+		t.Errorf("Unexpected result: %v", result)
+	}
+}
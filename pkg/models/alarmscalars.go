@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// AlarmScalars holds the value with its severity and status which share the same timestamps
+type AlarmScalars struct {
+	Scalars  *Scalars
+	Severity *Enums
+	Status   *Enums
+}
+
+func NewAlarmScalars(length int) *AlarmScalars {
+	return &AlarmScalars{
+		Scalars:  NewSclars(length),
+		Severity: NewSevirityEnums(length),
+		Status:   NewStatusEnums(length),
+	}
+}
+
+func (v *AlarmScalars) Append(val *float64, sevr int16, stat int16, t time.Time) {
+	v.Scalars.Append(val, t)
+	v.Severity.Append(sevr, t)
+	v.Status.Append(stat, t)
+}
+
+func (v *AlarmScalars) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
+	// time and value fields
+	fields := v.Scalars.ToFields(pvname, name, format, meta)
+
+	// Enum fields share the time field with the value field
+	sevrFields := v.Severity.ToFields(pvname, fmt.Sprintf("%s.%s", name, FIELD_NAME_SEVR), format, meta.DescriptionOnly())
+	statFields := v.Status.ToFields(pvname, fmt.Sprintf("%s.%s", name, FIELD_NAME_STAT), format, meta.DescriptionOnly())
+	fields = append(fields, sevrFields[1:]...)
+	fields = append(fields, statFields[1:]...)
+
+	return fields
+}
+
+func (v *AlarmScalars) Extrapolation(t time.Time) {
+	// Scalars skips the extrapolation if there is no valid value. Keep the length of the fields equal.
+	length := len(v.Scalars.Times)
+	v.Scalars.Extrapolation(t)
+	if len(v.Scalars.Times) == length {
+		return
+	}
+
+	v.Severity.Extrapolation(t)
+	v.Status.Extrapolation(t)
+}
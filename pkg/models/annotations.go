@@ -0,0 +1,115 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// AnnotationState is a state of the PV which lasts until the next transition
+type AnnotationState struct {
+	Time  time.Time
+	Text  string
+	Tags  []string
+	Alarm bool
+}
+
+// ToAnnotationStates returns the states of the data at the transitions only.
+// Consecutive samples in the same state are merged into the first sample.
+func (sd *SingleData) ToAnnotationStates() ([]AnnotationState, error) {
+	var states []AnnotationState
+
+	appendState := func(s AnnotationState) {
+		if len(states) > 0 && states[len(states)-1].Text == s.Text {
+			return
+		}
+		states = append(states, s)
+	}
+
+	switch v := sd.Values.(type) {
+	case *Scalars:
+		for idx, val := range v.Values {
+			// Invalid samples don't change the state
+			if val == nil {
+				continue
+			}
+			text := strconv.FormatFloat(*val, 'f', -1, 64)
+			appendState(AnnotationState{Time: v.Times[idx], Text: text, Tags: []string{text}})
+		}
+	case *Strings:
+		for idx, val := range v.Values {
+			appendState(AnnotationState{Time: v.Times[idx], Text: val, Tags: []string{val}})
+		}
+	case *Enums:
+		for idx, val := range v.Values {
+			text := enumText(v.EnumConfig, val)
+			appendState(AnnotationState{Time: v.Times[idx], Text: text, Tags: []string{text}, Alarm: val != 0})
+		}
+	case *AlarmScalars:
+		for idx, sevr := range v.Severity.Values {
+			sevrText := enumText(v.Severity.EnumConfig, sevr)
+			statText := enumText(v.Status.EnumConfig, v.Status.Values[idx])
+			text := fmt.Sprintf("%s (%s)", sevrText, statText)
+			appendState(AnnotationState{Time: v.Severity.Times[idx], Text: text, Tags: []string{sevrText, statText}, Alarm: sevr != 0})
+		}
+	default:
+		return nil, fmt.Errorf("%s: annotations are not supported for this data type", sd.Name)
+	}
+
+	return states, nil
+}
+
+// ToAnnotationFrame converts the states into the annotation regions.
+// Each region lasts until the next transition and the last one lasts until end.
+// If alarmOnly is true, the regions only cover the states in alarm.
+func (sd *SingleData) ToAnnotationFrame(end time.Time, alarmOnly bool) (*data.Frame, error) {
+	states, err := sd.ToAnnotationStates()
+	if err != nil {
+		return nil, err
+	}
+
+	times := make([]time.Time, 0, len(states))
+	timeEnds := make([]time.Time, 0, len(states))
+	texts := make([]string, 0, len(states))
+	tags := make([]json.RawMessage, 0, len(states))
+
+	for idx, s := range states {
+		if alarmOnly && !s.Alarm {
+			continue
+		}
+
+		timeEnd := end
+		if idx+1 < len(states) {
+			timeEnd = states[idx+1].Time
+		}
+
+		t, err := json.Marshal(append([]string{sd.PVname}, s.Tags...))
+		if err != nil {
+			return nil, err
+		}
+
+		times = append(times, s.Time)
+		timeEnds = append(timeEnds, timeEnd)
+		texts = append(texts, fmt.Sprintf("%s: %s", sd.Name, s.Text))
+		tags = append(tags, t)
+	}
+
+	frame := data.NewFrame(sd.Name,
+		data.NewField("time", nil, times),
+		data.NewField("timeEnd", nil, timeEnds),
+		data.NewField("text", nil, texts),
+		data.NewField("tags", nil, tags),
+	)
+
+	return frame, nil
+}
+
+func enumText(c data.EnumFieldConfig, val data.EnumItemIndex) string {
+	if int(val) >= 0 && int(val) < len(c.Text) {
+		return c.Text[val]
+	}
+	return strconv.Itoa(int(val))
+}
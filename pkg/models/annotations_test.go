@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestToAnnotationFrame(t *testing.T) {
+	times := []time.Time{
+		time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.January, 1, 0, 1, 0, 0, time.UTC),
+		time.Date(2024, time.January, 1, 0, 2, 0, 0, time.UTC),
+		time.Date(2024, time.January, 1, 0, 3, 0, 0, time.UTC),
+	}
+	end := time.Date(2024, time.January, 1, 0, 10, 0, 0, time.UTC)
+
+	alarm := NewAlarmScalars(4)
+	for idx, sevr := range []int16{0, 2, 2, 0} {
+		v := float64(idx)
+		alarm.Append(&v, sevr, sevr*1, times[idx])
+	}
+
+	var tests = []struct {
+		name      string
+		values    Values
+		alarmOnly bool
+		times     []time.Time
+		timeEnds  []time.Time
+		texts     []string
+		tags      []string
+	}{
+		{
+			name:     "strings",
+			values:   &Strings{Times: times, Values: []string{"Injection", "Injection", "Storage", "Injection"}},
+			times:    []time.Time{times[0], times[2], times[3]},
+			timeEnds: []time.Time{times[2], times[3], end},
+			texts:    []string{"PV: Injection", "PV: Storage", "PV: Injection"},
+			tags:     []string{`["PV","Injection"]`, `["PV","Storage"]`, `["PV","Injection"]`},
+		},
+		{
+			name:      "alarm only",
+			values:    alarm,
+			alarmOnly: true,
+			times:     []time.Time{times[1]},
+			timeEnds:  []time.Time{times[3]},
+			texts:     []string{"PV: MAJOR (WRITE)"},
+			tags:      []string{`["PV","MAJOR","WRITE"]`},
+		},
+		{
+			name:     "scalars with invalid samples",
+			values:   &Scalars{Times: times, Values: []*float64{floatPointer(1), nil, floatPointer(1), floatPointer(2.5)}},
+			times:    []time.Time{times[0], times[3]},
+			timeEnds: []time.Time{times[3], end},
+			texts:    []string{"PV: 1", "PV: 2.5"},
+			tags:     []string{`["PV","1"]`, `["PV","2.5"]`},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			sd := SingleData{Name: "PV", PVname: "PV", Values: testCase.values}
+			frame, err := sd.ToAnnotationFrame(end, testCase.alarmOnly)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var times, timeEnds []time.Time
+			var texts, tags []string
+			for idx := 0; idx < frame.Rows(); idx++ {
+				times = append(times, frame.Fields[0].At(idx).(time.Time))
+				timeEnds = append(timeEnds, frame.Fields[1].At(idx).(time.Time))
+				texts = append(texts, frame.Fields[2].At(idx).(string))
+				tags = append(tags, string(frame.Fields[3].At(idx).(json.RawMessage)))
+			}
+
+			if diff := cmp.Diff(testCase.times, times); diff != "" {
+				t.Errorf("time mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.timeEnds, timeEnds); diff != "" {
+				t.Errorf("timeEnd mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.texts, texts); diff != "" {
+				t.Errorf("text mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.tags, tags); diff != "" {
+				t.Errorf("tags mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestToAnnotationFrameUnsupported(t *testing.T) {
+	sd := SingleData{Name: "PV", PVname: "PV", Values: NewArrays(0)}
+	if _, err := sd.ToAnnotationFrame(time.Now(), false); err == nil {
+		t.Errorf("Arrays should not be supported")
+	}
+}
+
+func floatPointer(v float64) *float64 {
+	return &v
+}
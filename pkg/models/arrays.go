@@ -24,19 +24,19 @@ func (v *Arrays) Append(val []float64, t time.Time) {
 	v.Times = append(v.Times, t)
 }
 
-func (v *Arrays) ToFields(pvname string, name string, format FormatOption) []*data.Field {
+func (v *Arrays) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
 	if format == FormatOption(FORMAT_DTSPACE) {
-		fields := v.makeDtSpaceFields(pvname, name)
+		fields := v.makeDtSpaceFields(pvname, name, meta)
 		return fields
 	}
 
 	if format == FormatOption(FORMAT_INDEX) {
-		fields := v.makeIndexFields(pvname)
+		fields := v.makeIndexFields(pvname, meta)
 		return fields
 	}
 
 	// Default: Timeseries
-	fields := v.makeTimeseriesFields(pvname, name)
+	fields := v.makeTimeseriesFields(pvname, name, meta)
 	return fields
 }
 
@@ -49,7 +49,7 @@ func (v *Arrays) Extrapolation(t time.Time) {
 	v.Times = append(v.Times, t)
 }
 
-func (v *Arrays) makeDtSpaceFields(pvname string, name string) []*data.Field {
+func (v *Arrays) makeDtSpaceFields(pvname string, name string, meta Metadata) []*data.Field {
 	var times []time.Time
 	var vals []float64
 
@@ -71,12 +71,13 @@ func (v *Arrays) makeDtSpaceFields(pvname string, name string) []*data.Field {
 
 	valueField := data.NewField(name, labels, vals)
 	valueField.Config = &data.FieldConfig{DisplayNameFromDS: name}
+	meta.applyFieldConfig(valueField.Config)
 	fields = append(fields, valueField)
 
 	return fields
 }
 
-func (v *Arrays) makeIndexFields(pvname string) []*data.Field {
+func (v *Arrays) makeIndexFields(pvname string, meta Metadata) []*data.Field {
 	var fields []*data.Field
 
 	//add the index field
@@ -95,13 +96,14 @@ func (v *Arrays) makeIndexFields(pvname string) []*data.Field {
 		n := v.Times[idx].Local().Format("2006-01-02T15:04:05.000Z07:00")
 		valueField := data.NewField(n, labels, datapoint[0:dataLen])
 		valueField.Config = &data.FieldConfig{DisplayNameFromDS: n}
+		meta.applyFieldConfig(valueField.Config)
 		fields = append(fields, valueField)
 	}
 
 	return fields
 }
 
-func (v *Arrays) makeTimeseriesFields(pvname string, name string) []*data.Field {
+func (v *Arrays) makeTimeseriesFields(pvname string, name string, meta Metadata) []*data.Field {
 	var fields []*data.Field
 
 	//add the time dimension
@@ -116,6 +118,7 @@ func (v *Arrays) makeTimeseriesFields(pvname string, name string) []*data.Field
 		n := fmt.Sprintf("%s[%d]", name, idx)
 		valueField := data.NewField(n, labels, datapoint)
 		valueField.Config = &data.FieldConfig{DisplayNameFromDS: n}
+		meta.applyFieldConfig(valueField.Config)
 		fields = append(fields, valueField)
 	}
 
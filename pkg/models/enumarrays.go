@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type EnumArrays struct {
+	Times  []time.Time
+	Values [][]data.EnumItemIndex
+}
+
+func NewEnumArrays(length int) *EnumArrays {
+	return &EnumArrays{
+		Times:  make([]time.Time, 0, length),
+		Values: make([][]data.EnumItemIndex, 0, length),
+	}
+}
+
+func (v *EnumArrays) Append(val []int32, t time.Time) {
+	e := make([]data.EnumItemIndex, len(val))
+	for idx, i := range val {
+		e[idx] = data.EnumItemIndex(i)
+	}
+
+	v.Values = append(v.Values, e)
+	v.Times = append(v.Times, t)
+}
+
+// ToArrays returns the indices of the enum states as the numeric arrays
+func (v *EnumArrays) ToArrays() *Arrays {
+	arrays := NewArrays(len(v.Values))
+	for idx, row := range v.Values {
+		vals := make([]float64, len(row))
+		for i, val := range row {
+			vals[i] = float64(val)
+		}
+		arrays.Append(vals, v.Times[idx])
+	}
+
+	return arrays
+}
+
+func (v *EnumArrays) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
+	// Enum labels are taken from the state strings of the PV
+	var maxVal data.EnumItemIndex
+	for _, row := range v.Values {
+		for _, val := range row {
+			if val > maxVal {
+				maxVal = val
+			}
+		}
+	}
+	enumConfig := meta.enumFieldConfig(maxVal)
+
+	if format == FormatOption(FORMAT_DTSPACE) {
+		times, vals := flattenArrays(v.Times, v.Values)
+		return []*data.Field{
+			data.NewField("time", nil, times),
+			newEnumArrayField(pvname, name, vals, enumConfig, meta),
+		}
+	}
+
+	if format == FormatOption(FORMAT_INDEX) {
+		fields := []*data.Field{newIndexField(maxArrayLength(v.Values))}
+		for idx, datapoint := range padArrays(v.Values, 0) {
+			n := v.Times[idx].Local().Format("2006-01-02T15:04:05.000Z07:00")
+			fields = append(fields, newEnumArrayField(pvname, n, datapoint, enumConfig, meta))
+		}
+		return fields
+	}
+
+	// Default: Timeseries
+	fields := []*data.Field{data.NewField("time", nil, v.Times)}
+	for idx, datapoint := range transposeArrays(v.Values, 0) {
+		n := fmt.Sprintf("%s[%d]", name, idx)
+		fields = append(fields, newEnumArrayField(pvname, n, datapoint, enumConfig, meta))
+	}
+	return fields
+}
+
+func (v *EnumArrays) Extrapolation(t time.Time) {
+	if len(v.Values) == 0 {
+		return
+	}
+
+	v.Values = append(v.Values, v.Values[len(v.Values)-1])
+	v.Times = append(v.Times, t)
+}
+
+func newEnumArrayField(pvname string, name string, vals []data.EnumItemIndex, enumConfig data.EnumFieldConfig, meta Metadata) *data.Field {
+	labels := make(data.Labels, 1)
+	labels["pvname"] = pvname
+
+	valueField := data.NewField(name, labels, vals)
+	tc := &data.FieldTypeConfig{Enum: &enumConfig}
+	valueField.Config = &data.FieldConfig{DisplayNameFromDS: name, TypeConfig: tc, Description: meta.DESC}
+	return valueField
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnumLabels(t *testing.T) {
+	var tests = []struct {
+		name    string
+		headers map[string]string
+		output  []string
+	}{
+		{
+			name:    "mbbi state strings",
+			headers: map[string]string{"ZRST": "Off", "ONST": "Standby", "THST": "On"},
+			output:  []string{"Off", "Standby", "", "On"},
+		},
+		{
+			name:    "bi state strings",
+			headers: map[string]string{"ZNAM": "Close", "ONAM": "Open"},
+			output:  []string{"Close", "Open"},
+		},
+		{
+			name:    "no state strings",
+			headers: map[string]string{"EGU": "mA"},
+			output:  nil,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			m := NewMetadata(testCase.headers)
+			if diff := cmp.Diff(testCase.output, m.EnumLabels); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnumFieldConfig(t *testing.T) {
+	m := NewMetadata(map[string]string{"ZRST": "Off", "TWST": "On"})
+	c := m.enumFieldConfig(3)
+
+	if diff := cmp.Diff([]string{"Off", "1", "On", "3"}, c.Text); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEnumArraysToArrays(t *testing.T) {
+	times := []time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)}
+	v := NewEnumArrays(2)
+	v.Append([]int32{0, 2}, times[0])
+	v.Append([]int32{1}, times[1])
+
+	want := &Arrays{Times: times, Values: [][]float64{{0, 2}, {1}}}
+	if diff := cmp.Diff(want, v.ToArrays()); diff != "" {
+		t.Errorf("Result mismatch (-want +got):\n%s", diff)
+	}
+}
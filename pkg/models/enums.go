@@ -6,6 +6,11 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
+var (
+	severityText  = []string{"NO_ALARM", "MINOR", "MAJOR", "INVALID"}
+	severityColor = []string{"rgb(86, 166, 75)", "rgb(255, 120, 10)", "rgb(224, 47, 68)", "rgb(163, 82, 204)"}
+)
+
 type Enums struct {
 	Times      []time.Time
 	Values     []data.EnumItemIndex
@@ -21,8 +26,8 @@ func NewEnums(length int) *Enums {
 
 func NewSevirityEnums(length int) *Enums {
 	c := data.EnumFieldConfig{
-		Text:  []string{"NO_ALARM", "MINOR", "MAJOR", "INVALID"},
-		Color: []string{"rgb(86, 166, 75)", "rgb(255, 120, 10)", "rgb(224, 47, 68)", "rgb(163, 82, 204)"},
+		Text:  severityText,
+		Color: severityColor,
 	}
 
 	return &Enums{
@@ -49,7 +54,7 @@ func (v *Enums) Append(val int16, t time.Time) {
 	v.Times = append(v.Times, t)
 }
 
-func (v *Enums) ToFields(pvname string, name string, format FormatOption) []*data.Field {
+func (v *Enums) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
 	// ToFields doesn't use FormatOption in Enums for now
 
 	var fields []*data.Field
@@ -63,7 +68,7 @@ func (v *Enums) ToFields(pvname string, name string, format FormatOption) []*dat
 
 	valueField := data.NewField(name, labels, v.Values)
 	tc := &data.FieldTypeConfig{Enum: &v.EnumConfig}
-	valueField.Config = &data.FieldConfig{DisplayNameFromDS: name, TypeConfig: tc}
+	valueField.Config = &data.FieldConfig{DisplayNameFromDS: name, TypeConfig: tc, Description: meta.DESC}
 	fields = append(fields, valueField)
 
 	return fields
@@ -15,6 +15,7 @@ const (
 	FUNC_CATEGORY_TOSCALAR  = FunctionCategory("Array to Scalar")
 	FUNC_CATEGORY_FILTER    = FunctionCategory("Filter Series")
 	FUNC_CATEGORY_SORT      = FunctionCategory("Sort")
+	FUNC_CATEGORY_MATH      = FunctionCategory("Math")
 	FUNC_CATEGORY_OPTIONS   = FunctionCategory("Options")
 )
 
@@ -30,6 +31,16 @@ const (
 	FUNC_OPTION_ARRAY_FORMAT    = FunctionOption("arrayFormat")
 	FUNC_OPTION_IGNOREEMPTYERR  = FunctionOption("ignoreEmptyErr")
 	FUNC_OPTION_HIDEINVALID     = FunctionOption("hideInvalid")
+	FUNC_OPTION_ALARMTHRESHOLDS = FunctionOption("alarmThresholds")
+	FUNC_OPTION_SAMPLEMETADATA  = FunctionOption("sampleMetadata")
+	FUNC_OPTION_TIMEOUT         = FunctionOption("timeout")
+	FUNC_OPTION_PROCESSING      = FunctionOption("processing")
+	FUNC_OPTION_ALIGNALL        = FunctionOption("alignAll")
+	FUNC_OPTION_FRAMEFORMAT     = FunctionOption("frameFormat")
+)
+
+const (
+	QUERY_TYPE_ANNOTATION = "annotation"
 )
 
 type FieldName string
@@ -40,6 +51,8 @@ const (
 	FIELD_NAME_STAT         FieldName = "STAT"
 	FIELD_NAME_SEVR_AS_ENUM FieldName = "SEVR as Enum"
 	FIELD_NAME_STAT_AS_ENUM FieldName = "STAT as Enum"
+	// VAL, SEVR as Enum and STAT as Enum in one frame
+	FIELD_NAME_VAL_WITH_ALARM FieldName = "VAL with Alarm"
 )
 
 func (qm ArchiverQueryModel) PickFuncsByCategories(categories []FunctionCategory) []FunctionDescriptorQueryModel {
@@ -0,0 +1,301 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Names of the PV fields sent in the headers of the PB response
+const (
+	META_FIELD_EGU  = "EGU"
+	META_FIELD_PREC = "PREC"
+	META_FIELD_HOPR = "HOPR"
+	META_FIELD_LOPR = "LOPR"
+	META_FIELD_DESC = "DESC"
+	META_FIELD_HIHI = "HIHI"
+	META_FIELD_HIGH = "HIGH"
+	META_FIELD_LOW  = "LOW"
+	META_FIELD_LOLO = "LOLO"
+	META_FIELD_HHSV = "HHSV"
+	META_FIELD_HSV  = "HSV"
+	META_FIELD_LSV  = "LSV"
+	META_FIELD_LLSV = "LLSV"
+	META_FIELD_ZNAM = "ZNAM"
+	META_FIELD_ONAM = "ONAM"
+)
+
+// Names of the state strings of mbbi and mbbo records
+var metaFieldStateStrings = []string{
+	"ZRST", "ONST", "TWST", "THST", "FRST", "FVST", "SXST", "SVST",
+	"EIST", "NIST", "TEST", "ELST", "TVST", "TTST", "FTST", "FFST",
+}
+
+type Severity int
+
+const (
+	SEVERITY_NO_ALARM Severity = 0
+	SEVERITY_MINOR    Severity = 1
+	SEVERITY_MAJOR    Severity = 2
+	SEVERITY_INVALID  Severity = 3
+)
+
+type AlarmLimit struct {
+	Value    float64
+	Severity Severity
+}
+
+type AlarmLimits struct {
+	HIHI *AlarmLimit
+	HIGH *AlarmLimit
+	LOW  *AlarmLimit
+	LOLO *AlarmLimit
+}
+
+type Metadata struct {
+	EGU  string
+	PREC *uint16
+	HOPR *float64
+	LOPR *float64
+	DESC string
+
+	// EnumLabels holds the state strings of the enum PV
+	EnumLabels []string
+
+	Alarm AlarmLimits
+	// UseAlarmThresholds enables the alarm limits to be exported as thresholds
+	UseAlarmThresholds bool
+
+	// Fields holds every header as is, including the ones not mapped above
+	Fields map[string]string
+}
+
+func NewMetadata(headers map[string]string) Metadata {
+	m := Metadata{Fields: headers}
+
+	if len(headers) == 0 {
+		return m
+	}
+
+	m.EGU = headers[META_FIELD_EGU]
+	m.DESC = headers[META_FIELD_DESC]
+
+	if v, err := strconv.ParseUint(headers[META_FIELD_PREC], 10, 16); err == nil {
+		prec := uint16(v)
+		m.PREC = &prec
+	}
+
+	m.HOPR = parseMetaFloat(headers[META_FIELD_HOPR])
+	m.LOPR = parseMetaFloat(headers[META_FIELD_LOPR])
+
+	m.EnumLabels = newEnumLabels(headers)
+	m.Alarm = newAlarmLimits(headers)
+
+	return m
+}
+
+func newEnumLabels(headers map[string]string) []string {
+	labels := make([]string, len(metaFieldStateStrings))
+	last := -1
+	for idx, name := range metaFieldStateStrings {
+		labels[idx] = headers[name]
+		if labels[idx] != "" {
+			last = idx
+		}
+	}
+
+	if last >= 0 {
+		return labels[:last+1]
+	}
+
+	// bi and bo records have only two states
+	znam, onam := headers[META_FIELD_ZNAM], headers[META_FIELD_ONAM]
+	if znam != "" || onam != "" {
+		return []string{znam, onam}
+	}
+
+	return nil
+}
+
+// enumFieldConfig returns the enum config for the values.
+// The index is used as the label of the state which doesn't have a state string.
+func (m Metadata) enumFieldConfig(maxVal data.EnumItemIndex) data.EnumFieldConfig {
+	n := int(maxVal) + 1
+	if len(m.EnumLabels) > n {
+		n = len(m.EnumLabels)
+	}
+
+	text := make([]string, n)
+	for idx := range text {
+		if idx < len(m.EnumLabels) && m.EnumLabels[idx] != "" {
+			text[idx] = m.EnumLabels[idx]
+			continue
+		}
+		text[idx] = strconv.Itoa(idx)
+	}
+
+	return data.EnumFieldConfig{Text: text}
+}
+
+func newAlarmLimits(headers map[string]string) AlarmLimits {
+	var a AlarmLimits
+
+	hihi := parseMetaFloat(headers[META_FIELD_HIHI])
+	high := parseMetaFloat(headers[META_FIELD_HIGH])
+	low := parseMetaFloat(headers[META_FIELD_LOW])
+	lolo := parseMetaFloat(headers[META_FIELD_LOLO])
+
+	// All limits are 0 if the alarm limits are not configured in the record
+	allZero := true
+	for _, v := range []*float64{hihi, high, low, lolo} {
+		if v != nil && *v != 0 {
+			allZero = false
+		}
+	}
+	if allZero {
+		return a
+	}
+
+	a.HIHI = newAlarmLimit(hihi, headers[META_FIELD_HHSV], SEVERITY_MAJOR)
+	a.HIGH = newAlarmLimit(high, headers[META_FIELD_HSV], SEVERITY_MINOR)
+	a.LOW = newAlarmLimit(low, headers[META_FIELD_LSV], SEVERITY_MINOR)
+	a.LOLO = newAlarmLimit(lolo, headers[META_FIELD_LLSV], SEVERITY_MAJOR)
+
+	return a
+}
+
+func newAlarmLimit(value *float64, severity string, defaultSeverity Severity) *AlarmLimit {
+	if value == nil || math.IsNaN(*value) {
+		return nil
+	}
+
+	sev, ok := parseSeverity(severity)
+	if !ok {
+		// The severity field is not archived. EPICS records leave the unused limits at 0 with NO_ALARM,
+		// so a limit of 0 is regarded as unused, and the conventional severity is assumed for the others.
+		if *value == 0 {
+			return nil
+		}
+		sev = defaultSeverity
+	}
+
+	// The limit doesn't raise any alarm
+	if sev == SEVERITY_NO_ALARM {
+		return nil
+	}
+
+	return &AlarmLimit{Value: *value, Severity: sev}
+}
+
+func parseSeverity(s string) (Severity, bool) {
+	for idx, text := range severityText {
+		if s == text {
+			return Severity(idx), true
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < int(SEVERITY_NO_ALARM) || v > int(SEVERITY_INVALID) {
+		return SEVERITY_NO_ALARM, false
+	}
+
+	return Severity(v), true
+}
+
+func (a AlarmLimits) IsEmpty() bool {
+	return a.HIHI == nil && a.HIGH == nil && a.LOW == nil && a.LOLO == nil
+}
+
+func (a AlarmLimits) ToThresholds() *data.ThresholdsConfig {
+	// Grafana picks the color of the highest step whose value is less than or equal to the value.
+	// The base step covers the values below the lower limits.
+	if a.IsEmpty() {
+		return nil
+	}
+
+	baseSeverity := SEVERITY_NO_ALARM
+	if a.LOLO != nil {
+		baseSeverity = a.LOLO.Severity
+	} else if a.LOW != nil {
+		baseSeverity = a.LOW.Severity
+	}
+
+	steps := []data.Threshold{
+		data.NewThreshold(math.Inf(-1), severityColor[baseSeverity], severityText[baseSeverity]),
+	}
+
+	if a.LOLO != nil {
+		sev := SEVERITY_NO_ALARM
+		if a.LOW != nil {
+			sev = a.LOW.Severity
+		}
+		steps = append(steps, data.NewThreshold(a.LOLO.Value, severityColor[sev], severityText[sev]))
+	}
+	if a.LOW != nil {
+		steps = append(steps, data.NewThreshold(a.LOW.Value, severityColor[SEVERITY_NO_ALARM], severityText[SEVERITY_NO_ALARM]))
+	}
+	if a.HIGH != nil {
+		steps = append(steps, data.NewThreshold(a.HIGH.Value, severityColor[a.HIGH.Severity], severityText[a.HIGH.Severity]))
+	}
+	if a.HIHI != nil {
+		steps = append(steps, data.NewThreshold(a.HIHI.Value, severityColor[a.HIHI.Severity], severityText[a.HIHI.Severity]))
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Value < steps[j].Value })
+
+	return &data.ThresholdsConfig{
+		Mode:  data.ThresholdsModeAbsolute,
+		Steps: steps,
+	}
+}
+
+// DescriptionOnly drops the metadata that describes the VAL field.
+// Use it for the fields which don't share the unit and the range of VAL such as SEVR or STAT.
+func (m Metadata) DescriptionOnly() Metadata {
+	return Metadata{DESC: m.DESC, Fields: m.Fields}
+}
+
+func (m Metadata) applyFieldConfig(c *data.FieldConfig) {
+	c.Unit = m.EGU
+	c.Decimals = m.PREC
+	c.Description = m.DESC
+
+	// HOPR and LOPR are both 0 if the display limits are not set in the record
+	if m.HOPR != nil && m.LOPR != nil && *m.HOPR > *m.LOPR {
+		max := data.ConfFloat64(*m.HOPR)
+		min := data.ConfFloat64(*m.LOPR)
+		c.Max = &max
+		c.Min = &min
+	}
+}
+
+func (m Metadata) applyThresholds(c *data.FieldConfig) {
+	if !m.UseAlarmThresholds {
+		return
+	}
+
+	t := m.Alarm.ToThresholds()
+	if t == nil {
+		return
+	}
+
+	c.Thresholds = t
+	c.Custom = map[string]interface{}{
+		"thresholdsStyle": map[string]interface{}{"mode": "line+area"},
+	}
+}
+
+func parseMetaFloat(s string) *float64 {
+	if s == "" {
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &v
+}
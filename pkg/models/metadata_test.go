@@ -0,0 +1,127 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestAlarmLimitsToThresholds(t *testing.T) {
+	var tests = []struct {
+		name    string
+		headers map[string]string
+		output  *data.ThresholdsConfig
+	}{
+		{
+			name: "all limits with severities",
+			headers: map[string]string{
+				"HIHI": "90", "HIGH": "80", "LOW": "20", "LOLO": "10",
+				"HHSV": "MAJOR", "HSV": "MINOR", "LSV": "MINOR", "LLSV": "MAJOR",
+			},
+			output: &data.ThresholdsConfig{
+				Mode: data.ThresholdsModeAbsolute,
+				Steps: []data.Threshold{
+					data.NewThreshold(math.Inf(-1), severityColor[SEVERITY_MAJOR], "MAJOR"),
+					data.NewThreshold(10, severityColor[SEVERITY_MINOR], "MINOR"),
+					data.NewThreshold(20, severityColor[SEVERITY_NO_ALARM], "NO_ALARM"),
+					data.NewThreshold(80, severityColor[SEVERITY_MINOR], "MINOR"),
+					data.NewThreshold(90, severityColor[SEVERITY_MAJOR], "MAJOR"),
+				},
+			},
+		},
+		{
+			name: "high limits only",
+			headers: map[string]string{
+				"HIHI": "90", "HIGH": "80", "LOW": "0", "LOLO": "0",
+				"HHSV": "2", "HSV": "1", "LSV": "NO_ALARM", "LLSV": "NO_ALARM",
+			},
+			output: &data.ThresholdsConfig{
+				Mode: data.ThresholdsModeAbsolute,
+				Steps: []data.Threshold{
+					data.NewThreshold(math.Inf(-1), severityColor[SEVERITY_NO_ALARM], "NO_ALARM"),
+					data.NewThreshold(80, severityColor[SEVERITY_MINOR], "MINOR"),
+					data.NewThreshold(90, severityColor[SEVERITY_MAJOR], "MAJOR"),
+				},
+			},
+		},
+		{
+			name: "severities are not archived",
+			headers: map[string]string{
+				"HIGH": "80", "LOW": "20",
+			},
+			output: &data.ThresholdsConfig{
+				Mode: data.ThresholdsModeAbsolute,
+				Steps: []data.Threshold{
+					data.NewThreshold(math.Inf(-1), severityColor[SEVERITY_MINOR], "MINOR"),
+					data.NewThreshold(20, severityColor[SEVERITY_NO_ALARM], "NO_ALARM"),
+					data.NewThreshold(80, severityColor[SEVERITY_MINOR], "MINOR"),
+				},
+			},
+		},
+		{
+			name: "unused limits without severities",
+			headers: map[string]string{
+				"HIHI": "90", "HIGH": "0", "LOW": "0", "LOLO": "-10",
+			},
+			output: &data.ThresholdsConfig{
+				Mode: data.ThresholdsModeAbsolute,
+				Steps: []data.Threshold{
+					data.NewThreshold(math.Inf(-1), severityColor[SEVERITY_MAJOR], "MAJOR"),
+					data.NewThreshold(-10, severityColor[SEVERITY_NO_ALARM], "NO_ALARM"),
+					data.NewThreshold(90, severityColor[SEVERITY_MAJOR], "MAJOR"),
+				},
+			},
+		},
+		{
+			name: "limits are not configured",
+			headers: map[string]string{
+				"HIHI": "0", "HIGH": "0", "LOW": "0", "LOLO": "0",
+			},
+			output: nil,
+		},
+		{
+			name:    "no headers",
+			headers: map[string]string{},
+			output:  nil,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			m := NewMetadata(testCase.headers)
+			result := m.Alarm.ToThresholds()
+			if diff := cmp.Diff(testCase.output, result); diff != "" {
+				t.Errorf("Result mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyThresholds(t *testing.T) {
+	headers := map[string]string{"HIHI": "90", "HIGH": "80"}
+
+	var tests = []struct {
+		name   string
+		enable bool
+		output bool
+	}{
+		{name: "enabled", enable: true, output: true},
+		{name: "disabled", enable: false, output: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			m := NewMetadata(headers)
+			m.UseAlarmThresholds = testCase.enable
+
+			c := &data.FieldConfig{}
+			m.applyThresholds(c)
+
+			if (c.Thresholds != nil) != testCase.output {
+				t.Errorf("got %v, want %v", c.Thresholds != nil, testCase.output)
+			}
+		})
+	}
+}
@@ -55,6 +55,62 @@ type ArchiverQueryModel struct {
 	HideInvalid     bool              `json:"-"`
 	FormatOption    FormatOption      `json:"-"`
 	IgnoreEmptyErr  bool              `json:"-"`
+	AlarmThresholds bool              `json:"-"`
+	SampleMetadata  bool              `json:"-"`
+	Timeout         int               `json:"-"` // seconds
+	Processing      ProcessingOption  `json:"-"`
+	AlignAll        bool              `json:"-"`
+	FrameFormat     FrameFormatOption `json:"-"`
+}
+
+// ProcessingOption selects where the operator is applied to the data
+type ProcessingOption string
+
+const (
+	// The operator is applied by the appliance
+	PROCESSING_APPLIANCE = ProcessingOption("appliance")
+	// The raw data is retrieved and the operator is applied in the backend
+	PROCESSING_LOCAL = ProcessingOption("local")
+)
+
+// BinInterval returns the interval of the bins in seconds. It returns 0 if the raw data is retrieved.
+func (qm ArchiverQueryModel) BinInterval() int {
+	// No operators are necessary in this case
+	if qm.Operator == "raw" || qm.Operator == "last" {
+		return 0
+	}
+
+	// interval is less than 1 second or interval is not updated from "zero value"
+	if qm.Interval < 1 {
+		// if DisableAutoRaw is enabled, binInterval should be 1 second
+		if qm.DisableAutoRaw {
+			return 1
+		}
+		return 0
+	}
+
+	return qm.Interval
+}
+
+// Operators of the appliance whose parameter is the number of the points instead of the bin interval
+var pointCountOperators = map[string]bool{
+	"optimized":       true,
+	"optimLastSample": true,
+}
+
+// IsPointCountOperator reports whether the parameter of the operator is the number of the points
+func IsPointCountOperator(operator string) bool {
+	return pointCountOperators[operator]
+}
+
+// PointCount returns the number of the points for the operators which take it as the parameter.
+// It returns 0 for the other operators or if MaxDataPoints is not set.
+func (qm ArchiverQueryModel) PointCount() int {
+	if !IsPointCountOperator(qm.Operator) || qm.MaxDataPoints < 1 {
+		return 0
+	}
+
+	return qm.MaxDataPoints
 }
 
 type FunctionDescriptorQueryModel struct {
@@ -190,18 +246,36 @@ func convertNanosec(number *json.Number) time.Time {
 }
 
 type DatasourceSettings struct {
-	DefaultOperator    string `json:"defaultOperator"`
-	DefaultHideInvalid bool   `json:"hideInvalid"`
-	UseLiveUpdate      bool   `json:"useLiveUpdate"`
-	LiveUpdateURI      string `json:"liveUpdateURI"`
+	DefaultOperator     string `json:"defaultOperator"`
+	DefaultHideInvalid  bool   `json:"hideInvalid"`
+	UseLiveUpdate       bool   `json:"useLiveUpdate"`
+	LiveUpdateURI       string `json:"liveUpdateURI"`
+	CacheTTL            int    `json:"cacheTTL"`            // seconds. The cache is disabled if it's 0
+	CacheMaxSize        int    `json:"cacheMaxSize"`        // maximum number of the cached samples
+	MaxConcurrency      int    `json:"maxConcurrency"`      // maximum number of the concurrent requests of the datasource
+	MaxQueryConcurrency int    `json:"maxQueryConcurrency"` // maximum number of the concurrent requests of a query
+	QueryTimeout        int    `json:"queryTimeout"`        // seconds
+	DefaultProcessing   string `json:"defaultProcessing"`   // appliance or local
+	MaxRetries          *int   `json:"maxRetries"`          // the default is used if it's not set
+	RetryMaxDelay       int    `json:"retryMaxDelay"`       // seconds
+	// URLs of the other appliances searched and queried together with URL
+	ApplianceURLs []string `json:"applianceURLs"`
+	// URLs of the replicas of URL in the order of preference. The queries fail over to them when URL is unavailable.
+	ReplicaURLs []string `json:"replicaURLs"`
+	// URL of the secondary source which serves the data before SecondaryCutover, e.g. the legacy archive
+	SecondaryURL string `json:"secondaryURL"`
+	// RFC 3339 date-time or date in UTC
+	SecondaryCutover string `json:"secondaryCutover"`
 
 	URL         string             `json:"-"`
 	UID         string             `json:"-"`
 	HttpOptions httpclient.Options `json:"-"`
+	Cutover     time.Time          `json:"-"`
 }
 
 func ReadQueryModel(query backend.DataQuery, config DatasourceSettings) (ArchiverQueryModel, error) {
 	const REGEX_MAXIMUM_MATCHES = 1000
+	const DEFAULT_QUERY_TIMEOUT = 30 // seconds
 	model := ArchiverQueryModel{}
 
 	err := json.Unmarshal(query.JSON, &model)
@@ -237,10 +311,42 @@ func ReadQueryModel(query backend.DataQuery, config DatasourceSettings) (Archive
 	model.FieldName, _ = model.LoadStrOption(FunctionOption(FUNC_OPTION_FIELDNAME), "VAL")
 	model.IgnoreEmptyErr, _ = model.LoadBooleanOption(FunctionOption(FUNC_OPTION_IGNOREEMPTYERR), false)
 	model.HideInvalid, _ = model.LoadBooleanOption(FunctionOption(FUNC_OPTION_HIDEINVALID), config.DefaultHideInvalid)
+	model.AlarmThresholds, _ = model.LoadBooleanOption(FunctionOption(FUNC_OPTION_ALARMTHRESHOLDS), false)
+	model.SampleMetadata, _ = model.LoadBooleanOption(FunctionOption(FUNC_OPTION_SAMPLEMETADATA), false)
+	model.AlignAll, _ = model.LoadBooleanOption(FunctionOption(FUNC_OPTION_ALIGNALL), false)
+
+	timeout := config.QueryTimeout
+	if timeout <= 0 {
+		timeout = DEFAULT_QUERY_TIMEOUT
+	}
+	model.Timeout, err = model.LoadIntOption(FunctionOption(FUNC_OPTION_TIMEOUT), timeout)
+	if err != nil || model.Timeout <= 0 {
+		model.Timeout = timeout
+	}
+
+	processing := PROCESSING_APPLIANCE
+	if ProcessingOption(config.DefaultProcessing) == PROCESSING_LOCAL {
+		processing = PROCESSING_LOCAL
+	}
+	p, _ := model.LoadStrOption(FUNC_OPTION_PROCESSING, string(processing))
+	model.Processing = ProcessingOption(p)
+	if model.Processing != PROCESSING_APPLIANCE && model.Processing != PROCESSING_LOCAL {
+		model.Processing = processing
+	}
 
 	f, _ := model.LoadStrOption(FUNC_OPTION_ARRAY_FORMAT, string(FORMAT_TIMESERIES))
 	model.FormatOption = FormatOption(f)
 
+	ff, _ := model.LoadStrOption(FUNC_OPTION_FRAMEFORMAT, string(FRAME_FORMAT_NARROW))
+	model.FrameFormat = FrameFormatOption(ff)
+	if model.FrameFormat != FRAME_FORMAT_WIDE {
+		model.FrameFormat = FRAME_FORMAT_NARROW
+	}
+	// The wide format is alignAll with the sample-and-hold fill
+	if model.FrameFormat == FRAME_FORMAT_WIDE {
+		model.AlignAll = true
+	}
+
 	return model, nil
 }
 
@@ -263,9 +369,25 @@ func LoadSettings(ctx context.Context, settings backend.DataSourceInstanceSettin
 	model.URL = settings.URL
 	model.UID = settings.UID
 
+	if model.SecondaryURL != "" {
+		model.Cutover, err = parseCutover(model.SecondaryCutover)
+		if err != nil {
+			return nil, fmt.Errorf("error reading cutover date of the secondary source: %w", err)
+		}
+	}
+
 	return &model, nil
 }
 
+// parseCutover parses the RFC 3339 date-time or the date in UTC
+func parseCutover(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, v)
+}
+
 func loadInterval(qm ArchiverQueryModel) (int, error) {
 	// No operators are necessary in this case
 	if qm.Operator == "raw" || qm.Operator == "last" {
@@ -69,6 +69,9 @@ func TestReadQueryModel(t *testing.T) {
 				DisableExtrapol: false,
 				HideInvalid:     true,
 				FormatOption:    "timeseries",
+				Timeout:         30,
+				Processing:      PROCESSING_APPLIANCE,
+				FrameFormat:     FRAME_FORMAT_NARROW,
 			},
 		},
 		{
@@ -160,6 +163,7 @@ func TestReadQueryModel(t *testing.T) {
 			config: DatasourceSettings{
 				DefaultOperator:    "max",
 				DefaultHideInvalid: false,
+				QueryTimeout:       60,
 			},
 			output: ArchiverQueryModel{
 				Target:       "PV:TEST",
@@ -239,6 +243,9 @@ func TestReadQueryModel(t *testing.T) {
 				DisableExtrapol: true,
 				HideInvalid:     true,
 				FormatOption:    "timeseries",
+				Timeout:         60,
+				Processing:      PROCESSING_APPLIANCE,
+				FrameFormat:     FRAME_FORMAT_NARROW,
 			},
 		},
 	}
@@ -1031,3 +1038,211 @@ func TestExtractParamString(t *testing.T) {
 		})
 	}
 }
+
+func TestReadQueryModelTimeout(t *testing.T) {
+	timeoutQuery := func(param string) json.RawMessage {
+		return json.RawMessage(`{
+			"target": "PV:TEST",
+			"functions": [
+				{
+					"def": {
+						"category": "Options",
+						"defaultParams": ["30"],
+						"name": "timeout",
+						"params": [{"name": "seconds", "type": "int"}]
+					},
+					"params": ["` + param + `"]
+				}
+			]
+		}`)
+	}
+
+	var tests = []struct {
+		name   string
+		input  json.RawMessage
+		config DatasourceSettings
+		output int
+	}{
+		{name: "default", input: json.RawMessage(`{"target": "PV:TEST"}`), output: 30},
+		{name: "datasource setting", input: json.RawMessage(`{"target": "PV:TEST"}`), config: DatasourceSettings{QueryTimeout: 60}, output: 60},
+		{name: "timeout function", input: timeoutQuery("5"), config: DatasourceSettings{QueryTimeout: 60}, output: 5},
+		{name: "bad parameter", input: timeoutQuery("abc"), config: DatasourceSettings{QueryTimeout: 60}, output: 60},
+		{name: "zero", input: timeoutQuery("0"), output: 30},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ReadQueryModel(backend.DataQuery{JSON: testCase.input}, testCase.config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Timeout != testCase.output {
+				t.Errorf("got %v, want %v", result.Timeout, testCase.output)
+			}
+		})
+	}
+}
+
+func TestParseCutover(t *testing.T) {
+	var tests = []struct {
+		input  string
+		output time.Time
+		err    bool
+	}{
+		{input: "2015-04-01", output: time.Date(2015, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "2015-04-01T09:00:00+09:00", output: time.Date(2015, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "", err: true},
+		{input: "2015/04/01", err: true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.input, func(t *testing.T) {
+			result, err := parseCutover(testCase.input)
+			if testCase.err {
+				if err == nil {
+					t.Errorf("Error should be returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !result.Equal(testCase.output) {
+				t.Errorf("got %v, want %v", result, testCase.output)
+			}
+		})
+	}
+}
+
+func TestReadQueryModelProcessing(t *testing.T) {
+	processingQuery := func(param string) json.RawMessage {
+		return json.RawMessage(`{
+			"target": "PV:TEST",
+			"functions": [
+				{
+					"def": {
+						"category": "Options",
+						"defaultParams": ["local"],
+						"name": "processing",
+						"params": [{"name": "processing", "type": "string", "options": ["appliance", "local"]}]
+					},
+					"params": ["` + param + `"]
+				}
+			]
+		}`)
+	}
+
+	var tests = []struct {
+		name   string
+		input  json.RawMessage
+		config DatasourceSettings
+		output ProcessingOption
+	}{
+		{name: "default", input: json.RawMessage(`{"target": "PV:TEST"}`), output: PROCESSING_APPLIANCE},
+		{name: "datasource setting", input: json.RawMessage(`{"target": "PV:TEST"}`), config: DatasourceSettings{DefaultProcessing: "local"}, output: PROCESSING_LOCAL},
+		{name: "processing function", input: processingQuery("local"), output: PROCESSING_LOCAL},
+		{name: "processing function overrides setting", input: processingQuery("appliance"), config: DatasourceSettings{DefaultProcessing: "local"}, output: PROCESSING_APPLIANCE},
+		{name: "bad parameter", input: processingQuery("remote"), config: DatasourceSettings{DefaultProcessing: "local"}, output: PROCESSING_LOCAL},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ReadQueryModel(backend.DataQuery{JSON: testCase.input}, testCase.config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Processing != testCase.output {
+				t.Errorf("got %v, want %v", result.Processing, testCase.output)
+			}
+		})
+	}
+}
+
+func TestBinInterval(t *testing.T) {
+	var tests = []struct {
+		name   string
+		input  ArchiverQueryModel
+		output int
+	}{
+		{name: "mean", input: ArchiverQueryModel{Operator: "mean", Interval: 10}, output: 10},
+		{name: "raw", input: ArchiverQueryModel{Operator: "raw", Interval: 10}, output: 0},
+		{name: "last", input: ArchiverQueryModel{Operator: "last", Interval: 10}, output: 0},
+		{name: "auto raw", input: ArchiverQueryModel{Operator: "mean", Interval: 0}, output: 0},
+		{name: "disable auto raw", input: ArchiverQueryModel{Operator: "mean", Interval: 0, DisableAutoRaw: true}, output: 1},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := testCase.input.BinInterval(); result != testCase.output {
+				t.Errorf("got %v, want %v", result, testCase.output)
+			}
+		})
+	}
+}
+
+func TestPointCount(t *testing.T) {
+	var tests = []struct {
+		name   string
+		input  ArchiverQueryModel
+		output int
+	}{
+		{name: "optimized", input: ArchiverQueryModel{Operator: "optimized", Interval: 10, MaxDataPoints: 1000}, output: 1000},
+		{name: "optimLastSample", input: ArchiverQueryModel{Operator: "optimLastSample", MaxDataPoints: 500}, output: 500},
+		{name: "no max data points", input: ArchiverQueryModel{Operator: "optimized"}, output: 0},
+		{name: "mean", input: ArchiverQueryModel{Operator: "mean", Interval: 10, MaxDataPoints: 1000}, output: 0},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := testCase.input.PointCount(); result != testCase.output {
+				t.Errorf("got %v, want %v", result, testCase.output)
+			}
+		})
+	}
+}
+
+func TestReadQueryModelFrameFormat(t *testing.T) {
+	frameFormatQuery := func(param string) json.RawMessage {
+		return json.RawMessage(`{
+			"target": "PV:TEST",
+			"functions": [
+				{
+					"def": {
+						"category": "Options",
+						"defaultParams": ["wide"],
+						"name": "frameFormat",
+						"params": [{"name": "format", "type": "string", "options": ["narrow", "wide"]}]
+					},
+					"params": ["` + param + `"]
+				}
+			]
+		}`)
+	}
+
+	var tests = []struct {
+		name     string
+		input    json.RawMessage
+		output   FrameFormatOption
+		alignAll bool
+	}{
+		{name: "default", input: json.RawMessage(`{"target": "PV:TEST"}`), output: FRAME_FORMAT_NARROW, alignAll: false},
+		{name: "wide", input: frameFormatQuery("wide"), output: FRAME_FORMAT_WIDE, alignAll: true},
+		{name: "narrow", input: frameFormatQuery("narrow"), output: FRAME_FORMAT_NARROW, alignAll: false},
+		{name: "bad parameter", input: frameFormatQuery("long"), output: FRAME_FORMAT_NARROW, alignAll: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ReadQueryModel(backend.DataQuery{JSON: testCase.input}, DatasourceSettings{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.FrameFormat != testCase.output {
+				t.Errorf("got %v, want %v", result.FrameFormat, testCase.output)
+			}
+			if result.AlignAll != testCase.alignAll {
+				t.Errorf("got alignAll %v, want %v", result.AlignAll, testCase.alignAll)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// SampleMetadata holds the per-sample metadata sent by the appliance.
+// Most samples don't have any metadata, so only the samples with metadata are stored by their index.
+type SampleMetadata struct {
+	length        int
+	repeatCounts  map[int]int64
+	actualChanges map[int]bool
+	fieldValues   map[string]map[int]string
+}
+
+func NewSampleMetadata() *SampleMetadata {
+	return &SampleMetadata{
+		repeatCounts:  make(map[int]int64),
+		actualChanges: make(map[int]bool),
+		fieldValues:   make(map[string]map[int]string),
+	}
+}
+
+// Append records the metadata for the next sample. Call it once for every appended sample.
+func (m *SampleMetadata) Append(repeatCount *int64, actualChange *bool, fieldValues map[string]string) {
+	idx := m.length
+	m.length++
+
+	if repeatCount != nil {
+		m.repeatCounts[idx] = *repeatCount
+	}
+
+	if actualChange != nil {
+		m.actualChanges[idx] = *actualChange
+	}
+
+	for name, val := range fieldValues {
+		if _, ok := m.fieldValues[name]; !ok {
+			m.fieldValues[name] = make(map[int]string)
+		}
+		m.fieldValues[name][idx] = val
+	}
+}
+
+func (m *SampleMetadata) Len() int {
+	return m.length
+}
+
+// Slice returns the metadata of the samples in [start, end)
+func (m *SampleMetadata) Slice(start int, end int) *SampleMetadata {
+	c := NewSampleMetadata()
+	c.length = end - start
+
+	for idx, v := range m.repeatCounts {
+		if idx >= start && idx < end {
+			c.repeatCounts[idx-start] = v
+		}
+	}
+	for idx, v := range m.actualChanges {
+		if idx >= start && idx < end {
+			c.actualChanges[idx-start] = v
+		}
+	}
+	for name, vals := range m.fieldValues {
+		for idx, v := range vals {
+			if idx >= start && idx < end {
+				if _, ok := c.fieldValues[name]; !ok {
+					c.fieldValues[name] = make(map[int]string)
+				}
+				c.fieldValues[name][idx-start] = v
+			}
+		}
+	}
+
+	return c
+}
+
+func (m *SampleMetadata) IsEmpty() bool {
+	return len(m.repeatCounts) == 0 && len(m.actualChanges) == 0 && len(m.fieldValues) == 0
+}
+
+func (m *SampleMetadata) ToFields(pvname string, name string, rows int) []*data.Field {
+	// The extrapolation appends a row without metadata.
+	// The other mismatches mean the samples are changed by the functions and the metadata isn't aligned.
+	if m.IsEmpty() || rows < m.length || rows > m.length+1 {
+		return nil
+	}
+
+	var fields []*data.Field
+
+	if len(m.repeatCounts) > 0 {
+		repeatCounts := make([]*int64, rows)
+		for idx, v := range m.repeatCounts {
+			c := v
+			repeatCounts[idx] = &c
+		}
+		fields = append(fields, newSampleMetaField(fmt.Sprintf("%s.repeatcount", name), pvname, repeatCounts))
+	}
+
+	if len(m.actualChanges) > 0 {
+		actualChanges := make([]*bool, rows)
+		for idx, v := range m.actualChanges {
+			c := v
+			actualChanges[idx] = &c
+		}
+		fields = append(fields, newSampleMetaField(fmt.Sprintf("%s.fieldactualchange", name), pvname, actualChanges))
+	}
+
+	names := make([]string, 0, len(m.fieldValues))
+	for n := range m.fieldValues {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		vals := make([]*string, rows)
+		for idx, v := range m.fieldValues[n] {
+			c := v
+			vals[idx] = &c
+		}
+		fields = append(fields, newSampleMetaField(fmt.Sprintf("%s.%s", name, n), pvname, vals))
+	}
+
+	return fields
+}
+
+func newSampleMetaField(name string, pvname string, values interface{}) *data.Field {
+	labels := make(data.Labels, 1)
+	labels["pvname"] = pvname
+
+	f := data.NewField(name, labels, values)
+	f.Config = &data.FieldConfig{DisplayNameFromDS: name}
+	return f
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestSampleMetadataToFields(t *testing.T) {
+	repeatCount := int64(2)
+
+	var tests = []struct {
+		name   string
+		rows   int
+		output []string
+	}{
+		{name: "same length", rows: 3, output: []string{"PV.repeatcount", "PV.DESC", "PV.EGU"}},
+		{name: "extrapolated", rows: 4, output: []string{"PV.repeatcount", "PV.DESC", "PV.EGU"}},
+		{name: "samples are reduced", rows: 2, output: nil},
+		{name: "samples are increased", rows: 5, output: nil},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			m := NewSampleMetadata()
+			m.Append(nil, nil, nil)
+			m.Append(&repeatCount, nil, nil)
+			m.Append(nil, nil, map[string]string{"EGU": "mA", "DESC": "current"})
+
+			fields := m.ToFields("PV", "PV", testCase.rows)
+
+			if len(fields) != len(testCase.output) {
+				t.Fatalf("Number of fields differs - Wanted: %v Got: %v", len(testCase.output), len(fields))
+			}
+			for idx, f := range fields {
+				if f.Name != testCase.output[idx] {
+					t.Errorf("Field name differs - Wanted: %v Got: %v", testCase.output[idx], f.Name)
+				}
+				if f.Len() != testCase.rows {
+					t.Errorf("Field length differs - Wanted: %v Got: %v", testCase.rows, f.Len())
+				}
+			}
+		})
+	}
+}
+
+func TestSampleMetadataEmpty(t *testing.T) {
+	m := NewSampleMetadata()
+	m.Append(nil, nil, nil)
+	m.Append(nil, nil, nil)
+
+	if !m.IsEmpty() {
+		t.Errorf("Metadata should be empty")
+	}
+	if fields := m.ToFields("PV", "PV", 2); fields != nil {
+		t.Errorf("No fields should be returned: %v", fields)
+	}
+}
+
+func TestSampleMetadataSlice(t *testing.T) {
+	repeatCount := int64(2)
+
+	m := NewSampleMetadata()
+	m.Append(&repeatCount, nil, nil)
+	m.Append(nil, nil, nil)
+	m.Append(&repeatCount, nil, map[string]string{"EGU": "mA"})
+	m.Append(nil, nil, nil)
+
+	s := m.Slice(1, 3)
+	if s.Len() != 2 {
+		t.Fatalf("got %d samples, want 2", s.Len())
+	}
+
+	fields := s.ToFields("PV", "PV", 2)
+	if len(fields) != 2 {
+		t.Fatalf("got %d fields, want 2", len(fields))
+	}
+	if v, _ := fields[0].ConcreteAt(1); v != repeatCount {
+		t.Errorf("got %v, want %v", v, repeatCount)
+	}
+	if v, ok := fields[0].ConcreteAt(0); ok {
+		t.Errorf("The first sample should not have the metadata: %v", v)
+	}
+}
@@ -39,7 +39,7 @@ func (v *Scalars) SetValConcrete(idx int, val float64) {
 	v.Values[idx] = &val
 }
 
-func (v *Scalars) ToFields(pvname string, name string, format FormatOption) []*data.Field {
+func (v *Scalars) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
 	// ToFields doesn't use FormatOption in Scalars for now
 
 	var fields []*data.Field
@@ -53,6 +53,8 @@ func (v *Scalars) ToFields(pvname string, name string, format FormatOption) []*d
 
 	valueField := data.NewField(name, labels, v.Values)
 	valueField.Config = &data.FieldConfig{DisplayNameFromDS: name}
+	meta.applyFieldConfig(valueField.Config)
+	meta.applyThresholds(valueField.Config)
 	fields = append(fields, valueField)
 
 	return fields
@@ -170,6 +172,161 @@ func (v *Scalars) MovingAverage(windowSize int) {
 	v.Values = newValues
 }
 
+// Derivative replaces the values with the rate of change per unit time from the previous valid sample.
+// The first sample is dropped, and the null samples and the negative rates if nonNegative is true are null.
+func (v *Scalars) Derivative(unit time.Duration, nonNegative bool) {
+	newValues := make([]*float64, 0, len(v.Values))
+	newTimes := make([]time.Time, 0, len(v.Times))
+
+	prev := -1
+	for idx, val := range v.Values {
+		if prev < 0 {
+			if val != nil {
+				prev = idx
+			}
+			continue
+		}
+
+		// The samples at the same time as the previous one have no rate
+		dt := v.Times[idx].Sub(v.Times[prev])
+		if dt <= 0 {
+			continue
+		}
+
+		newTimes = append(newTimes, v.Times[idx])
+		if val == nil {
+			newValues = append(newValues, nil)
+			continue
+		}
+
+		rate := (*val - *v.Values[prev]) / (float64(dt) / float64(unit))
+		prev = idx
+		if nonNegative && rate < 0 {
+			newValues = append(newValues, nil)
+			continue
+		}
+		newValues = append(newValues, &rate)
+	}
+
+	v.Times = newTimes
+	v.Values = newValues
+}
+
+// Integral replaces the values with the cumulative integral over time by the trapezoidal rule.
+// The time is measured in unit. The intervals next to the null samples don't contribute to the integral.
+func (v *Scalars) Integral(unit time.Duration) {
+	newValues := make([]*float64, len(v.Values))
+
+	var total float64
+	for idx, val := range v.Values {
+		if idx > 0 && val != nil && v.Values[idx-1] != nil {
+			dt := float64(v.Times[idx].Sub(v.Times[idx-1])) / float64(unit)
+			total += (*v.Values[idx-1] + *val) / 2 * dt
+		}
+
+		nv := total
+		newValues[idx] = &nv
+	}
+
+	v.Values = newValues
+}
+
+// CumulativeSum replaces the values with the running sum of the values.
+// The null samples remain null and don't contribute to the sum.
+func (v *Scalars) CumulativeSum() {
+	newValues := make([]*float64, len(v.Values))
+
+	var total float64
+	for idx, val := range v.Values {
+		if val == nil {
+			continue
+		}
+
+		total += *val
+		nv := total
+		newValues[idx] = &nv
+	}
+
+	v.Values = newValues
+}
+
+// ResampleMethod selects how the values between the samples are interpolated
+type ResampleMethod string
+
+const (
+	// The value is held until the next sample like the archiver
+	RESAMPLE_STEP = ResampleMethod("step")
+	// The value is interpolated linearly between the samples
+	RESAMPLE_LINEAR = ResampleMethod("linear")
+	// The value of the nearest sample in time
+	RESAMPLE_NEAREST = ResampleMethod("nearest")
+)
+
+// Validate returns an error if the method is not recognized
+func (m ResampleMethod) Validate() error {
+	if m != RESAMPLE_STEP && m != RESAMPLE_LINEAR && m != RESAMPLE_NEAREST {
+		return fmt.Errorf("resample method %s not recognized", m)
+	}
+	return nil
+}
+
+// Resample replaces the samples with the values at the times.
+// The value is null if the sample used for the value is null or there is no sample before the time.
+// The values after the last sample are held for all methods.
+func (v *Scalars) Resample(times []time.Time, method ResampleMethod) error {
+	if err := method.Validate(); err != nil {
+		return err
+	}
+
+	newValues := make([]*float64, len(times))
+
+	// prev is the index of the last sample at or before t
+	prev := -1
+	for idx, t := range times {
+		for prev+1 < len(v.Times) && !v.Times[prev+1].After(t) {
+			prev++
+		}
+
+		if prev < 0 {
+			// The nearest sample may be after t
+			if method == RESAMPLE_NEAREST && len(v.Values) > 0 {
+				newValues[idx] = v.Values[0]
+			}
+			continue
+		}
+
+		next := prev + 1
+		if next >= len(v.Times) || v.Times[prev].Equal(t) || method == RESAMPLE_STEP {
+			newValues[idx] = v.Values[prev]
+			continue
+		}
+
+		before := t.Sub(v.Times[prev])
+		after := v.Times[next].Sub(t)
+		switch method {
+		case RESAMPLE_NEAREST:
+			if after < before {
+				newValues[idx] = v.Values[next]
+			} else {
+				newValues[idx] = v.Values[prev]
+			}
+		case RESAMPLE_LINEAR:
+			if v.Values[prev] == nil || v.Values[next] == nil {
+				continue
+			}
+			ratio := float64(before) / float64(before+after)
+			nv := *v.Values[prev] + (*v.Values[next]-*v.Values[prev])*ratio
+			newValues[idx] = &nv
+		}
+	}
+
+	// The values are copied so that the resampled series doesn't share the values with the others
+	v.Times = append([]time.Time{}, times...)
+	v.Values = copyFloat64Pointers(newValues)
+
+	return nil
+}
+
 type RankType string
 
 const (
@@ -179,8 +336,15 @@ const (
 	RANKTYPE_ABSOLUTEMIN = RankType("AbsoluteMin")
 	RANKTYPE_ABSOLUTEMAX = RankType("AbsoluteMax")
 	RANKTYPE_SUM         = RankType("Sum")
+
+	// The time-weighted rank types weight each sample by the time until the next sample,
+	// so that the rank doesn't depend on how often the PV is archived.
+	RANKTYPE_TIMEWEIGHTEDAVG    = RankType("TimeWeightedAvg")
+	RANKTYPE_TIMEABOVETHRESHOLD = RankType("TimeAboveThreshold")
+	RANKTYPE_DUTYCYCLE          = RankType("DutyCycle")
 )
 
+// Rank returns a single value representing the series. Use TimeWeightedRank for the time-weighted rank types.
 func (v *Scalars) Rank(rankType RankType) (float64, error) {
 	data := v.Values
 	switch rankType {
@@ -275,3 +439,56 @@ func (v *Scalars) Rank(rankType RankType) (float64, error) {
 		return 0, errors.New(errMsg)
 	}
 }
+
+// TimeWeightedRank returns a single value representing the series in the time range by the time-weighted statistics.
+// Each sample lasts until the next sample, and the last sample is held until the end of the time range.
+// The periods are clipped to the time range, and the periods of the null samples are excluded.
+//
+//   - TimeWeightedAvg: the average weighted by the duration of each sample. It is the plain average if there is no duration.
+//   - TimeAboveThreshold: the seconds during which the value is above the threshold.
+//   - DutyCycle: the fraction of the time during which the value is above the threshold, from 0 to 1.
+func (v *Scalars) TimeWeightedRank(rankType RankType, threshold float64, from time.Time, to time.Time) (float64, error) {
+	var total, duration, above float64
+	for idx, val := range v.Values {
+		if val == nil {
+			continue
+		}
+
+		start := v.Times[idx]
+		if start.Before(from) {
+			start = from
+		}
+		end := to
+		if idx+1 < len(v.Times) && v.Times[idx+1].Before(to) {
+			end = v.Times[idx+1]
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		dt := end.Sub(start).Seconds()
+		total += *val * dt
+		duration += dt
+		if *val > threshold {
+			above += dt
+		}
+	}
+
+	switch rankType {
+	case RANKTYPE_TIMEWEIGHTEDAVG:
+		if duration == 0 {
+			return v.Rank(RANKTYPE_AVG)
+		}
+		return total / duration, nil
+	case RANKTYPE_TIMEABOVETHRESHOLD:
+		return above, nil
+	case RANKTYPE_DUTYCYCLE:
+		if duration == 0 {
+			return 0, nil
+		}
+		return above / duration, nil
+	default:
+		errMsg := fmt.Sprintf("Value %s not recognized", rankType)
+		return 0, errors.New(errMsg)
+	}
+}
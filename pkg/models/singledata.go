@@ -9,13 +9,31 @@ import (
 
 type Values interface {
 	Extrapolation(t time.Time)
-	ToFields(pvname string, name string, format FormatOption) []*data.Field
+	ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field
+}
+
+// ScalarsOf returns the scalar values of the data.
+// The value of "VAL with Alarm" and the value of the statistics are also returned.
+func ScalarsOf(v Values) (*Scalars, bool) {
+	switch v := v.(type) {
+	case *Scalars:
+		return v, true
+	case *AlarmScalars:
+		return v.Scalars, true
+	case *Statistics:
+		return v.Value, true
+	}
+	return nil, false
 }
 
 type SingleData struct {
-	Name   string
-	PVname string
-	Values Values
+	Name       string
+	PVname     string
+	Values     Values
+	Meta       Metadata
+	SampleMeta *SampleMetadata
+	// Endpoint is the retrieval URL which served the data
+	Endpoint string
 }
 
 type FormatOption string
@@ -26,17 +44,37 @@ const (
 	FORMAT_DTSPACE    = FunctionCategory("dt-space")
 )
 
+// FrameFormatOption selects how the data of the PVs are compiled into the frames
+type FrameFormatOption string
+
+const (
+	// Each PV is compiled into a frame
+	FRAME_FORMAT_NARROW = FrameFormatOption("narrow")
+	// The scalar data of all PVs is joined into one frame with the sample-and-hold fill
+	FRAME_FORMAT_WIDE = FrameFormatOption("wide")
+)
+
 func (sd *SingleData) ToFrame(format FormatOption) *data.Frame {
 	// create data frame response
 	frame := data.NewFrame(sd.Name)
 
+	if sd.Endpoint != "" {
+		frame.SetMeta(&data.FrameMeta{Custom: map[string]interface{}{"endpoint": sd.Endpoint}})
+	}
+
 	if sd.Values == nil {
 		return frame
 	}
 
-	v := sd.Values.ToFields(sd.PVname, sd.Name, format)
+	v := sd.Values.ToFields(sd.PVname, sd.Name, format, sd.Meta)
 	frame.Fields = append(frame.Fields, v...)
 
+	// Sample metadata is aligned with the samples only in timeseries format
+	if sd.SampleMeta != nil && format == FormatOption(FORMAT_TIMESERIES) && len(frame.Fields) > 0 {
+		m := sd.SampleMeta.ToFields(sd.PVname, sd.Name, frame.Fields[0].Len())
+		frame.Fields = append(frame.Fields, m...)
+	}
+
 	return frame
 }
 
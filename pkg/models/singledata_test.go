@@ -594,6 +594,42 @@ func TestExtrapolation(t *testing.T) {
 				},
 			},
 		},
+		{
+			sDIn: SingleData{
+				Values: &AlarmScalars{
+					Scalars:  &Scalars{Times: []time.Time{testhelper.TimeHelper(0)}, Values: testhelper.InitFloat64SlicePointer([]float64{1})},
+					Severity: &Enums{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []data.EnumItemIndex{1}},
+					Status:   &Enums{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []data.EnumItemIndex{4}},
+				},
+			},
+			name: "alarm scalars extrapolation",
+			t:    testhelper.TimeHelper(5),
+			sDOut: SingleData{
+				Values: &AlarmScalars{
+					Scalars:  &Scalars{Times: []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(5)}, Values: testhelper.InitFloat64SlicePointer([]float64{1, 1})},
+					Severity: &Enums{Times: []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(5)}, Values: []data.EnumItemIndex{1, 1}},
+					Status:   &Enums{Times: []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(5)}, Values: []data.EnumItemIndex{4, 4}},
+				},
+			},
+		},
+		{
+			sDIn: SingleData{
+				Values: &AlarmScalars{
+					Scalars:  &Scalars{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []*float64{nil}},
+					Severity: &Enums{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []data.EnumItemIndex{3}},
+					Status:   &Enums{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []data.EnumItemIndex{0}},
+				},
+			},
+			name: "alarm scalars without valid value",
+			t:    testhelper.TimeHelper(5),
+			sDOut: SingleData{
+				Values: &AlarmScalars{
+					Scalars:  &Scalars{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []*float64{nil}},
+					Severity: &Enums{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []data.EnumItemIndex{3}},
+					Status:   &Enums{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []data.EnumItemIndex{0}},
+				},
+			},
+		},
 	}
 	for _, testCase := range tests {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Statistics holds the statistics of the bins returned by the optimized operators which share the same timestamps.
+// Value is the mean of the bin for optimized and the last sample of the bin for optimLastSample.
+type Statistics struct {
+	Value *Scalars
+	Std   *Scalars
+	Min   *Scalars
+	Max   *Scalars
+	Count *Scalars
+}
+
+func NewStatistics(length int) *Statistics {
+	return &Statistics{
+		Value: NewSclars(length),
+		Std:   NewSclars(length),
+		Min:   NewSclars(length),
+		Max:   NewSclars(length),
+		Count: NewSclars(length),
+	}
+}
+
+func (v *Statistics) Append(val float64, std float64, min float64, max float64, count float64, t time.Time) {
+	v.Value.AppendConcrete(val, t)
+	v.Std.AppendConcrete(std, t)
+	v.Min.AppendConcrete(min, t)
+	v.Max.AppendConcrete(max, t)
+	v.Count.AppendConcrete(count, t)
+}
+
+func (v *Statistics) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
+	// time and value fields
+	fields := v.Value.ToFields(pvname, name, format, meta)
+
+	// The statistics have the unit of the value but the alarm thresholds are only shown on the value
+	statMeta := meta
+	statMeta.UseAlarmThresholds = false
+
+	stats := []struct {
+		name   string
+		values *Scalars
+		meta   Metadata
+	}{
+		{name: "min", values: v.Min, meta: statMeta},
+		{name: "max", values: v.Max, meta: statMeta},
+		{name: "std", values: v.Std, meta: statMeta},
+		{name: "count", values: v.Count, meta: meta.DescriptionOnly()},
+	}
+
+	// The fields share the time field with the value field
+	for _, s := range stats {
+		f := s.values.ToFields(pvname, fmt.Sprintf("%s.%s", name, s.name), format, s.meta)
+		fields = append(fields, f[1:]...)
+	}
+
+	return fields
+}
+
+func (v *Statistics) Extrapolation(t time.Time) {
+	// Scalars skips the extrapolation if there is no valid value. Keep the length of the fields equal.
+	length := len(v.Value.Times)
+	v.Value.Extrapolation(t)
+	if len(v.Value.Times) == length {
+		return
+	}
+
+	v.Std.Extrapolation(t)
+	v.Min.Extrapolation(t)
+	v.Max.Extrapolation(t)
+	v.Count.Extrapolation(t)
+}
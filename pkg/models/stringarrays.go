@@ -0,0 +1,136 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type StringArrays struct {
+	Times  []time.Time
+	Values [][]string
+}
+
+func NewStringArrays(length int) *StringArrays {
+	return &StringArrays{
+		Times:  make([]time.Time, 0, length),
+		Values: make([][]string, 0, length),
+	}
+}
+
+func (v *StringArrays) Append(val []string, t time.Time) {
+	v.Values = append(v.Values, val)
+	v.Times = append(v.Times, t)
+}
+
+func (v *StringArrays) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
+	if format == FormatOption(FORMAT_DTSPACE) {
+		times, vals := flattenArrays(v.Times, v.Values)
+		return []*data.Field{
+			data.NewField("time", nil, times),
+			newStringArrayField(pvname, name, vals, meta),
+		}
+	}
+
+	if format == FormatOption(FORMAT_INDEX) {
+		fields := []*data.Field{newIndexField(maxArrayLength(v.Values))}
+		for idx, datapoint := range padArrays(v.Values, "") {
+			n := v.Times[idx].Local().Format("2006-01-02T15:04:05.000Z07:00")
+			fields = append(fields, newStringArrayField(pvname, n, datapoint, meta))
+		}
+		return fields
+	}
+
+	// Default: Timeseries
+	fields := []*data.Field{data.NewField("time", nil, v.Times)}
+	for idx, datapoint := range transposeArrays(v.Values, "") {
+		n := fmt.Sprintf("%s[%d]", name, idx)
+		fields = append(fields, newStringArrayField(pvname, n, datapoint, meta))
+	}
+	return fields
+}
+
+func (v *StringArrays) Extrapolation(t time.Time) {
+	if len(v.Values) == 0 {
+		return
+	}
+
+	v.Values = append(v.Values, v.Values[len(v.Values)-1])
+	v.Times = append(v.Times, t)
+}
+
+func newStringArrayField(pvname string, name string, vals []string, meta Metadata) *data.Field {
+	labels := make(data.Labels, 1)
+	labels["pvname"] = pvname
+
+	valueField := data.NewField(name, labels, vals)
+	valueField.Config = &data.FieldConfig{DisplayNameFromDS: name, Description: meta.DESC}
+	return valueField
+}
+
+// flattenArrays lays out the elements of the arrays in time.
+// Each element is shifted by 1 millisecond from the timestamp of the array.
+func flattenArrays[T any](t []time.Time, arrays [][]T) ([]time.Time, []T) {
+	var times []time.Time
+	var vals []T
+
+	for i, row := range arrays {
+		for j, column := range row {
+			vals = append(vals, column)
+			times = append(times, t[i].Add(time.Duration(j)*time.Millisecond))
+		}
+	}
+
+	return times, vals
+}
+
+func maxArrayLength[T any](arrays [][]T) int {
+	l := 0
+	for _, row := range arrays {
+		if len(row) > l {
+			l = len(row)
+		}
+	}
+	return l
+}
+
+// padArrays pads the arrays to the same length because the length of the arrays can change in time
+func padArrays[T any](arrays [][]T, pad T) [][]T {
+	l := maxArrayLength(arrays)
+
+	result := make([][]T, len(arrays))
+	for i, row := range arrays {
+		result[i] = make([]T, l)
+		copy(result[i], row)
+		for j := len(row); j < l; j++ {
+			result[i][j] = pad
+		}
+	}
+
+	return result
+}
+
+func transposeArrays[T any](arrays [][]T, pad T) [][]T {
+	padded := padArrays(arrays, pad)
+	x := maxArrayLength(arrays)
+	y := len(padded)
+
+	result := make([][]T, x)
+	for i := range result {
+		result[i] = make([]T, y)
+		for j := 0; j < y; j++ {
+			result[i][j] = padded[j][i]
+		}
+	}
+
+	return result
+}
+
+func newIndexField(length int) *data.Field {
+	numbers := make([]int64, length)
+	for i := 0; i < length; i++ {
+		numbers[i] = int64(i)
+	}
+	return data.NewField("index", nil, numbers)
+}
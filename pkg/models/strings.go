@@ -23,7 +23,7 @@ func (v *Strings) Append(val string, t time.Time) {
 	v.Times = append(v.Times, t)
 }
 
-func (v *Strings) ToFields(pvname string, name string, format FormatOption) []*data.Field {
+func (v *Strings) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
 	// ToFields doesn't use FormatOption in Strings for now
 
 	var fields []*data.Field
@@ -36,7 +36,7 @@ func (v *Strings) ToFields(pvname string, name string, format FormatOption) []*d
 	labels["pvname"] = pvname
 
 	valueField := data.NewField(name, labels, v.Values)
-	valueField.Config = &data.FieldConfig{DisplayNameFromDS: name}
+	valueField.Config = &data.FieldConfig{DisplayNameFromDS: name, Description: meta.DESC}
 	fields = append(fields, valueField)
 
 	return fields
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// TableColumn is a column of the table. Either Numbers or Strings is used.
+type TableColumn struct {
+	Name    string
+	Numbers []*float64
+	Strings []*string
+}
+
+func (c *TableColumn) IsString() bool {
+	return c.Strings != nil
+}
+
+// Tables holds the rows of the tables sampled in time.
+// Every row of a table has the timestamp of the sample.
+type Tables struct {
+	Times   []time.Time
+	Columns []*TableColumn
+}
+
+func NewTables(length int) *Tables {
+	return &Tables{
+		Times: make([]time.Time, 0, length),
+	}
+}
+
+// Append appends the rows of a table. Each value of the columns must be []float64 or []string.
+// The columns which don't exist in the previous tables are added, and the missing cells are set to null.
+func (v *Tables) Append(names []string, columns []interface{}, t time.Time) {
+	start := len(v.Times)
+
+	rows := 0
+	for _, c := range columns {
+		switch x := c.(type) {
+		case []float64:
+			rows = max(rows, len(x))
+		case []string:
+			rows = max(rows, len(x))
+		}
+	}
+
+	for i := 0; i < rows; i++ {
+		v.Times = append(v.Times, t)
+	}
+
+	for idx, name := range names {
+		if idx >= len(columns) {
+			break
+		}
+
+		switch x := columns[idx].(type) {
+		case []float64:
+			col := v.column(name, false, start)
+			if col.IsString() {
+				continue
+			}
+			for i, val := range x {
+				f := val
+				col.Numbers[start+i] = &f
+			}
+		case []string:
+			col := v.column(name, true, start)
+			if !col.IsString() {
+				continue
+			}
+			for i, val := range x {
+				s := val
+				col.Strings[start+i] = &s
+			}
+		}
+	}
+
+	// Pad the columns which are not in this table
+	for _, col := range v.Columns {
+		col.resize(len(v.Times))
+	}
+}
+
+func (v *Tables) column(name string, isString bool, length int) *TableColumn {
+	for _, col := range v.Columns {
+		if col.Name == name {
+			col.resize(len(v.Times))
+			return col
+		}
+	}
+
+	col := &TableColumn{Name: name}
+	if isString {
+		col.Strings = make([]*string, length)
+	} else {
+		col.Numbers = make([]*float64, length)
+	}
+	col.resize(len(v.Times))
+	v.Columns = append(v.Columns, col)
+
+	return col
+}
+
+func (c *TableColumn) resize(length int) {
+	if c.IsString() {
+		for len(c.Strings) < length {
+			c.Strings = append(c.Strings, nil)
+		}
+		return
+	}
+
+	for len(c.Numbers) < length {
+		c.Numbers = append(c.Numbers, nil)
+	}
+}
+
+func (v *Tables) ToFields(pvname string, name string, format FormatOption, meta Metadata) []*data.Field {
+	// ToFields doesn't use FormatOption in Tables for now
+
+	var fields []*data.Field
+
+	//add the time dimension
+	fields = append(fields, data.NewField("time", nil, v.Times))
+
+	// add values
+	for _, col := range v.Columns {
+		labels := make(data.Labels, 1)
+		labels["pvname"] = pvname
+
+		n := fmt.Sprintf("%s.%s", name, col.Name)
+
+		var valueField *data.Field
+		if col.IsString() {
+			valueField = data.NewField(n, labels, col.Strings)
+		} else {
+			valueField = data.NewField(n, labels, col.Numbers)
+		}
+		valueField.Config = &data.FieldConfig{DisplayNameFromDS: n, Description: meta.DESC}
+		fields = append(fields, valueField)
+	}
+
+	return fields
+}
+
+func (v *Tables) Extrapolation(t time.Time) {
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTablesAppend(t *testing.T) {
+	t0 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Second)
+
+	v := NewTables(0)
+	v.Append([]string{"name", "current"}, []interface{}{[]string{"A", "B"}, []float64{0.5, 1}}, t0)
+	v.Append([]string{"name", "voltage"}, []interface{}{[]string{"C"}, []float64{3}}, t1)
+
+	fields := v.ToFields("PV", "PV", FormatOption(FORMAT_TIMESERIES), Metadata{})
+
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	if diff := cmp.Diff([]string{"time", "PV.name", "PV.current", "PV.voltage"}, names); diff != "" {
+		t.Fatalf("Field names mismatch (-want +got):\n%s", diff)
+	}
+
+	// Missing cells are null
+	var got []interface{}
+	for _, f := range fields[1:] {
+		for i := 0; i < f.Len(); i++ {
+			v, ok := f.ConcreteAt(i)
+			if !ok {
+				v = nil
+			}
+			got = append(got, v)
+		}
+	}
+	want := []interface{}{"A", "B", "C", 0.5, 1.0, nil, nil, nil, 3.0}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Values mismatch (-want +got):\n%s", diff)
+	}
+}
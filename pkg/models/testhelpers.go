@@ -96,6 +96,12 @@ func SingleDataCompareHelper(result []*SingleData, wanted []*SingleData, t *test
 					t.Errorf("Values at index %v do not match, Wanted %v, got %v", idx, wantedv.Values[idx], resultv.Values[idx])
 				}
 			}
+		case *AlarmScalars:
+			wantedv := wanted[udx].Values.(*AlarmScalars)
+			name := wanted[udx].Name
+			SingleDataCompareHelper([]*SingleData{{Name: name, Values: resultv.Scalars}}, []*SingleData{{Name: name, Values: wantedv.Scalars}}, t)
+			SingleDataCompareHelper([]*SingleData{{Name: name, Values: resultv.Severity}}, []*SingleData{{Name: name, Values: wantedv.Severity}}, t)
+			SingleDataCompareHelper([]*SingleData{{Name: name, Values: resultv.Status}}, []*SingleData{{Name: name, Values: wantedv.Status}}, t)
 		default:
 			t.Fatalf("Response Values are invalid")
 		}
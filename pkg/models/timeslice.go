@@ -0,0 +1,228 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var errTypeMismatch = errors.New("values of different types can't be concatenated")
+
+// TimeSlicer is implemented by the Values whose samples can be cut and concatenated in time.
+// Slice and Concat return copies which don't share the samples with the receiver.
+type TimeSlicer interface {
+	Values
+	Len() int
+	TimeAt(idx int) time.Time
+	Slice(start int, end int) Values
+	Concat(v Values) (Values, error)
+}
+
+// SliceByTime returns the samples in [from, to].
+// The last sample before from is also kept because it is the value at from.
+func SliceByTime(v TimeSlicer, from time.Time, to time.Time) Values {
+	return v.Slice(IndexByTime(v, from, to))
+}
+
+// IndexByTime returns the index range of the samples which SliceByTime returns
+func IndexByTime(v TimeSlicer, from time.Time, to time.Time) (int, int) {
+	n := v.Len()
+	start := sort.Search(n, func(i int) bool { return !v.TimeAt(i).Before(from) })
+	if start > 0 {
+		start--
+	}
+	end := sort.Search(n, func(i int) bool { return v.TimeAt(i).After(to) })
+	if end < start {
+		end = start
+	}
+
+	return start, end
+}
+
+// MergeByTime merges the samples of head before at and the samples of tail at and after at
+func MergeByTime(head TimeSlicer, tail TimeSlicer, at time.Time) (Values, error) {
+	headEnd := sort.Search(head.Len(), func(i int) bool { return !head.TimeAt(i).Before(at) })
+	tailStart := sort.Search(tail.Len(), func(i int) bool { return !tail.TimeAt(i).Before(at) })
+
+	h, ok := head.Slice(0, headEnd).(TimeSlicer)
+	if !ok {
+		return nil, errTypeMismatch
+	}
+
+	return h.Concat(tail.Slice(tailStart, tail.Len()))
+}
+
+func copyFloat64Pointers(v []*float64) []*float64 {
+	c := make([]*float64, len(v))
+	for idx, p := range v {
+		if p == nil {
+			continue
+		}
+		f := *p
+		c[idx] = &f
+	}
+	return c
+}
+
+func (v *Scalars) Len() int                 { return len(v.Times) }
+func (v *Scalars) TimeAt(idx int) time.Time { return v.Times[idx] }
+
+func (v *Scalars) Slice(start int, end int) Values {
+	return &Scalars{
+		Times:  append([]time.Time{}, v.Times[start:end]...),
+		Values: copyFloat64Pointers(v.Values[start:end]),
+	}
+}
+
+func (v *Scalars) Concat(o Values) (Values, error) {
+	other, ok := o.(*Scalars)
+	if !ok {
+		return nil, errTypeMismatch
+	}
+
+	c := v.Slice(0, v.Len()).(*Scalars)
+	c.Times = append(c.Times, other.Times...)
+	c.Values = append(c.Values, copyFloat64Pointers(other.Values)...)
+
+	return c, nil
+}
+
+func (v *Arrays) Len() int                 { return len(v.Times) }
+func (v *Arrays) TimeAt(idx int) time.Time { return v.Times[idx] }
+
+func (v *Arrays) Slice(start int, end int) Values {
+	vals := make([][]float64, 0, end-start)
+	for _, row := range v.Values[start:end] {
+		vals = append(vals, append([]float64{}, row...))
+	}
+
+	return &Arrays{
+		Times:  append([]time.Time{}, v.Times[start:end]...),
+		Values: vals,
+	}
+}
+
+func (v *Arrays) Concat(o Values) (Values, error) {
+	other, ok := o.(*Arrays)
+	if !ok {
+		return nil, errTypeMismatch
+	}
+
+	c := v.Slice(0, v.Len()).(*Arrays)
+	o2 := other.Slice(0, other.Len()).(*Arrays)
+	c.Times = append(c.Times, o2.Times...)
+	c.Values = append(c.Values, o2.Values...)
+
+	return c, nil
+}
+
+func (v *Strings) Len() int                 { return len(v.Times) }
+func (v *Strings) TimeAt(idx int) time.Time { return v.Times[idx] }
+
+func (v *Strings) Slice(start int, end int) Values {
+	return &Strings{
+		Times:  append([]time.Time{}, v.Times[start:end]...),
+		Values: append([]string{}, v.Values[start:end]...),
+	}
+}
+
+func (v *Strings) Concat(o Values) (Values, error) {
+	other, ok := o.(*Strings)
+	if !ok {
+		return nil, errTypeMismatch
+	}
+
+	c := v.Slice(0, v.Len()).(*Strings)
+	c.Times = append(c.Times, other.Times...)
+	c.Values = append(c.Values, other.Values...)
+
+	return c, nil
+}
+
+func (v *Enums) Len() int                 { return len(v.Times) }
+func (v *Enums) TimeAt(idx int) time.Time { return v.Times[idx] }
+
+func (v *Enums) Slice(start int, end int) Values {
+	c := &Enums{
+		Times:      append([]time.Time{}, v.Times[start:end]...),
+		EnumConfig: v.EnumConfig,
+	}
+	c.Values = append(c.Values, v.Values[start:end]...)
+
+	return c
+}
+
+func (v *Enums) Concat(o Values) (Values, error) {
+	other, ok := o.(*Enums)
+	if !ok {
+		return nil, errTypeMismatch
+	}
+
+	c := v.Slice(0, v.Len()).(*Enums)
+	c.Times = append(c.Times, other.Times...)
+	c.Values = append(c.Values, other.Values...)
+
+	return c, nil
+}
+
+func (v *AlarmScalars) Len() int                 { return v.Scalars.Len() }
+func (v *AlarmScalars) TimeAt(idx int) time.Time { return v.Scalars.TimeAt(idx) }
+
+func (v *AlarmScalars) Slice(start int, end int) Values {
+	return &AlarmScalars{
+		Scalars:  v.Scalars.Slice(start, end).(*Scalars),
+		Severity: v.Severity.Slice(start, end).(*Enums),
+		Status:   v.Status.Slice(start, end).(*Enums),
+	}
+}
+
+func (v *AlarmScalars) Concat(o Values) (Values, error) {
+	other, ok := o.(*AlarmScalars)
+	if !ok {
+		return nil, errTypeMismatch
+	}
+
+	scalars, _ := v.Scalars.Concat(other.Scalars)
+	sevr, _ := v.Severity.Concat(other.Severity)
+	stat, _ := v.Status.Concat(other.Status)
+
+	return &AlarmScalars{
+		Scalars:  scalars.(*Scalars),
+		Severity: sevr.(*Enums),
+		Status:   stat.(*Enums),
+	}, nil
+}
+
+func (v *Statistics) Len() int                 { return v.Value.Len() }
+func (v *Statistics) TimeAt(idx int) time.Time { return v.Value.TimeAt(idx) }
+
+func (v *Statistics) Slice(start int, end int) Values {
+	return &Statistics{
+		Value: v.Value.Slice(start, end).(*Scalars),
+		Std:   v.Std.Slice(start, end).(*Scalars),
+		Min:   v.Min.Slice(start, end).(*Scalars),
+		Max:   v.Max.Slice(start, end).(*Scalars),
+		Count: v.Count.Slice(start, end).(*Scalars),
+	}
+}
+
+func (v *Statistics) Concat(o Values) (Values, error) {
+	other, ok := o.(*Statistics)
+	if !ok {
+		return nil, errTypeMismatch
+	}
+
+	val, _ := v.Value.Concat(other.Value)
+	std, _ := v.Std.Concat(other.Std)
+	min, _ := v.Min.Concat(other.Min)
+	max, _ := v.Max.Concat(other.Max)
+	count, _ := v.Count.Concat(other.Count)
+
+	return &Statistics{
+		Value: val.(*Scalars),
+		Std:   std.(*Scalars),
+		Min:   min.(*Scalars),
+		Max:   max.(*Scalars),
+		Count: count.(*Scalars),
+	}, nil
+}
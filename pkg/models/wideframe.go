@@ -0,0 +1,79 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// ToWideFrames joins the scalar data into one frame with a time field and a value field for each series.
+// The time field has the timestamps of all series. If hold is true, the value of a series is held until its next sample like the archiver,
+// otherwise the value is null at the timestamps where the series has no sample. The value before the first sample of the series is always null.
+// Only the value of "VAL with Alarm" and the statistics is joined.
+// The other data is converted into the frames of its own after the joined frame.
+func ToWideFrames(name string, sDs []*SingleData, format FormatOption, hold bool) []*data.Frame {
+	var scalars []*SingleData
+	var frames []*data.Frame
+	for _, sD := range sDs {
+		if _, ok := ScalarsOf(sD.Values); ok {
+			scalars = append(scalars, sD)
+			continue
+		}
+		frames = append(frames, sD.ToFrame(format))
+	}
+
+	if len(scalars) == 0 {
+		return frames
+	}
+
+	times := unionTimes(scalars)
+	frame := data.NewFrame(name, data.NewField("time", nil, times))
+	for _, sD := range scalars {
+		v, _ := ScalarsOf(sD.Values)
+
+		// The samples are placed at the index of their timestamps in the joined time field
+		vals := make([]*float64, len(times))
+		idx := 0
+		for i, t := range v.Times {
+			for !times[idx].Equal(t) {
+				idx++
+				if hold && i > 0 {
+					vals[idx] = v.Values[i-1]
+				}
+			}
+			vals[idx] = v.Values[i]
+		}
+		// The last value is held until the end
+		if hold && len(v.Values) > 0 {
+			for i := idx + 1; i < len(times); i++ {
+				vals[i] = v.Values[len(v.Values)-1]
+			}
+		}
+
+		fields := (&Scalars{Times: times, Values: vals}).ToFields(sD.PVname, sD.Name, format, sD.Meta)
+		frame.Fields = append(frame.Fields, fields[1:]...)
+	}
+
+	return append([]*data.Frame{frame}, frames...)
+}
+
+// unionTimes returns the sorted timestamps of all series without duplicates
+func unionTimes(sDs []*SingleData) []time.Time {
+	var times []time.Time
+	for _, sD := range sDs {
+		v, _ := ScalarsOf(sD.Values)
+		times = append(times, v.Times...)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	unique := make([]time.Time, 0, len(times))
+	for idx, t := range times {
+		if idx > 0 && t.Equal(times[idx-1]) {
+			continue
+		}
+		unique = append(unique, t)
+	}
+
+	return unique
+}
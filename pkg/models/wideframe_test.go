@@ -0,0 +1,149 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sasaki77/archiverappliance-datasource/pkg/testhelper"
+)
+
+func TestToWideFrames(t *testing.T) {
+	sDs := []*SingleData{
+		{
+			Name:   "A",
+			PVname: "PV:A",
+			Values: &Scalars{
+				Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(2)},
+				Values: testhelper.InitFloat64SlicePointer([]float64{1, 2}),
+			},
+			Meta: Metadata{EGU: "mA"},
+		},
+		{
+			Name:   "PV:S",
+			PVname: "PV:S",
+			Values: &Strings{Times: []time.Time{testhelper.TimeHelper(0)}, Values: []string{"on"}},
+		},
+		{
+			Name:   "B",
+			PVname: "PV:B",
+			Values: &Scalars{
+				Times:  []time.Time{testhelper.TimeHelper(1), testhelper.TimeHelper(2)},
+				Values: testhelper.InitFloat64SlicePointer([]float64{3, 4}),
+			},
+		},
+	}
+
+	frames := ToWideFrames("A", sDs, FormatOption(FORMAT_TIMESERIES), false)
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+
+	frame := frames[0]
+	if len(frame.Fields) != 3 || frame.Fields[1].Name != "A" || frame.Fields[2].Name != "B" {
+		t.Fatalf("Unexpected fields: %v", frame.Fields)
+	}
+	if frame.Fields[1].Labels["pvname"] != "PV:A" || frame.Fields[1].Config.Unit != "mA" {
+		t.Errorf("Field config should be kept: %v, %v", frame.Fields[1].Labels, frame.Fields[1].Config)
+	}
+
+	var times []time.Time
+	for i := 0; i < frame.Fields[0].Len(); i++ {
+		times = append(times, frame.Fields[0].At(i).(time.Time))
+	}
+	if diff := cmp.Diff([]time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(1), testhelper.TimeHelper(2)}, times); diff != "" {
+		t.Errorf("Times mismatch (-want +got):\n%s", diff)
+	}
+
+	// The series are null at the timestamps where they have no sample
+	wants := [][]*float64{
+		{testhelper.InitFloat64SlicePointer([]float64{1})[0], nil, testhelper.InitFloat64SlicePointer([]float64{2})[0]},
+		{nil, testhelper.InitFloat64SlicePointer([]float64{3})[0], testhelper.InitFloat64SlicePointer([]float64{4})[0]},
+	}
+	for idx, want := range wants {
+		var got []*float64
+		for i := 0; i < frame.Fields[idx+1].Len(); i++ {
+			got = append(got, frame.Fields[idx+1].At(i).(*float64))
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Values of %s mismatch (-want +got):\n%s", frame.Fields[idx+1].Name, diff)
+		}
+	}
+
+	if frames[1].Name != "PV:S" {
+		t.Errorf("The other data should be in its own frame: %v", frames[1].Name)
+	}
+}
+
+func TestToWideFramesHold(t *testing.T) {
+	p := func(v float64) *float64 { return &v }
+	sDs := []*SingleData{
+		{
+			Name: "A",
+			Values: &Scalars{
+				Times:  []time.Time{testhelper.TimeHelper(0), testhelper.TimeHelper(2)},
+				Values: []*float64{p(1), nil},
+			},
+		},
+		{
+			Name: "B",
+			Values: &Scalars{
+				Times:  []time.Time{testhelper.TimeHelper(1), testhelper.TimeHelper(3)},
+				Values: []*float64{p(3), p(4)},
+			},
+		},
+		{
+			Name:   "C",
+			Values: &Scalars{},
+		},
+	}
+
+	frames := ToWideFrames("A", sDs, FormatOption(FORMAT_TIMESERIES), true)
+	if len(frames) != 1 || len(frames[0].Fields) != 4 {
+		t.Fatalf("Unexpected frames: %v", frames)
+	}
+
+	// The values are held until the next sample, and they are null before the first sample
+	wants := [][]*float64{
+		{p(1), p(1), nil, nil},
+		{nil, p(3), p(3), p(4)},
+		{nil, nil, nil, nil},
+	}
+	for idx, want := range wants {
+		field := frames[0].Fields[idx+1]
+		var got []*float64
+		for i := 0; i < field.Len(); i++ {
+			got = append(got, field.At(i).(*float64))
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Values of %s mismatch (-want +got):\n%s", field.Name, diff)
+		}
+	}
+}
+
+func TestToWideFramesStatistics(t *testing.T) {
+	stats := NewStatistics(2)
+	stats.Append(1.5, 0.5, 1, 2, 4, testhelper.TimeHelper(0))
+	stats.Append(3, 1, 2, 4, 3, testhelper.TimeHelper(1))
+	sDs := []*SingleData{
+		{Name: "A", PVname: "PV:A", Values: stats},
+		{Name: "B", PVname: "PV:B", Values: NewSclarsWithValues([]time.Time{testhelper.TimeHelper(1)}, testhelper.InitFloat64SlicePointer([]float64{5}))},
+	}
+
+	// Only the value of the statistics is joined
+	frames := ToWideFrames("A", sDs, FormatOption(FORMAT_TIMESERIES), false)
+	if len(frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(frames))
+	}
+	if len(frames[0].Fields) != 3 || frames[0].Fields[1].Name != "A" || frames[0].Fields[2].Name != "B" {
+		t.Fatalf("Unexpected fields: %v", frames[0].Fields)
+	}
+
+	var got []*float64
+	for i := 0; i < frames[0].Fields[1].Len(); i++ {
+		got = append(got, frames[0].Fields[1].At(i).(*float64))
+	}
+	if diff := cmp.Diff(testhelper.InitFloat64SlicePointer([]float64{1.5, 3}), got); diff != "" {
+		t.Errorf("Values mismatch (-want +got):\n%s", diff)
+	}
+}
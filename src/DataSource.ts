@@ -1,7 +1,7 @@
 import _ from 'lodash';
 import { Observable, from } from 'rxjs';
 import { DataSourceWithBackend, getTemplateSrv } from '@grafana/runtime';
-import { DataQueryResponse, DataQueryRequest, DataSourceInstanceSettings } from '@grafana/data';
+import { AnnotationQuery, DataQueryResponse, DataQueryRequest, DataSourceInstanceSettings } from '@grafana/data';
 
 import { AAQuery, AADataSourceOptions, TargetQuery } from './types';
 import { getOptions } from './aafunc';
@@ -32,6 +32,15 @@ export class DataSource extends DataSourceWithBackend<AAQuery, AADataSourceOptio
     this.liveUpdateURI = instanceSettings.jsonData.liveUpdateURI || 'ws://localhost:8080/pvws/pv';
     this.aaclient = new AAclient(url, instanceSettings.withCredentials || false);
     this.streamQuery = new StreamQuery(this.aaclient);
+    this.annotations = {
+      // Annotations are only supported by the backend
+      prepareQuery: (anno: AnnotationQuery<AAQuery>) => {
+        if (!this.useBackend || !anno.target) {
+          return undefined;
+        }
+        return { ...anno.target, queryType: 'annotation' };
+      },
+    };
   }
 
   // Called from Grafana panels to get data
@@ -66,6 +75,9 @@ export class DataSource extends DataSourceWithBackend<AAQuery, AADataSourceOptio
 
   // Called from Grafana data source configuration page to make sure the connection is working
   async testDatasource() {
+    if (this.useBackend) {
+      return super.testDatasource();
+    }
     return this.aaclient.testDatasource();
   }
 
@@ -79,14 +91,19 @@ export class DataSource extends DataSourceWithBackend<AAQuery, AADataSourceOptio
      * query format:
      * ex1) PV:NAME:.*
      * ex2) PV:NAME:.*?limit=10
+     * ex3) PV:NAME:.*?limit=10&pattern=PV:NAME:(.*)
      */
     const templateSrv = getTemplateSrv();
     const replacedQuery = templateSrv.replace(query, undefined, 'regex');
-    const [pvQuery, paramsQuery] = replacedQuery.split('?', 2);
+    // The pattern may have "?", so only the first one separates the parameters
+    const paramsIndex = replacedQuery.indexOf('?');
+    const pvQuery = paramsIndex < 0 ? replacedQuery : replacedQuery.slice(0, paramsIndex);
+    const paramsQuery = paramsIndex < 0 ? '' : replacedQuery.slice(paramsIndex + 1);
     const parsedPVs = parseTargetPV(pvQuery);
 
     // Parse query parameters
     let limitNum = 100;
+    let pattern = '';
     if (paramsQuery) {
       const params: URLSearchParams = new URLSearchParams(paramsQuery);
       const limit_param: string | null = params.get('limit');
@@ -94,13 +111,23 @@ export class DataSource extends DataSourceWithBackend<AAQuery, AADataSourceOptio
         const limit = parseInt(limit_param, 10);
         limitNum = Number.isInteger(limit) ? limit : 100;
       }
+      pattern = params.get('pattern') ?? '';
+    }
+
+    if (this.useBackend) {
+      const resourceParams: { [key: string]: string | number } = { regex: pvQuery, limit: limitNum };
+      if (pattern) {
+        resourceParams.pattern = pattern;
+      }
+      return this.getResource<Array<{ text: string; value: string }>>('pvs', resourceParams);
     }
 
+    const rep = pattern ? new RegExp(pattern) : undefined;
     const pvnamesPromise = _.map(parsedPVs, (targetQuery) => this.pvNamesFindQuery(targetQuery, limitNum));
 
     return Promise.all(pvnamesPromise).then((pvnamesArray) => {
-      const pvnames = _.slice(_.uniq(_.flatten(pvnamesArray)), 0, limitNum);
-      return _.map(pvnames, (pvname) => ({ text: pvname }));
+      const items = _.compact(_.map(_.uniq(_.flatten(pvnamesArray)), (pvname) => extractVariableItem(pvname, rep)));
+      return _.slice(_.uniqBy(items, 'value'), 0, limitNum);
     });
   }
 
@@ -183,3 +210,24 @@ export class DataSource extends DataSourceWithBackend<AAQuery, AADataSourceOptio
     return targets;
   }
 }
+
+// extractVariableItem returns the text and value of the variable from the PV name by the pattern.
+// The named groups "text" and "value" take precedence over the first capture group. Unmatched PV names are dropped.
+function extractVariableItem(pvname: string, rep?: RegExp) {
+  if (!rep) {
+    return { text: pvname, value: pvname };
+  }
+
+  const match = pvname.match(rep);
+  if (!match) {
+    return undefined;
+  }
+
+  let text = match.groups?.text ?? '';
+  let value = match.groups?.value ?? '';
+  if (!text && !value) {
+    text = match.length > 1 ? match[1] : pvname;
+  }
+
+  return { text: text || value, value: value || text };
+}
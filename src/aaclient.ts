@@ -3,7 +3,7 @@ import _ from 'lodash';
 import { getBackendSrv } from '@grafana/runtime';
 import { lastValueFrom } from 'rxjs';
 
-import { operatorList, TargetQuery, AADataQueryData } from 'types';
+import { operatorList, pointCountOperatorList, TargetQuery, AADataQueryData } from 'types';
 import { parseTargetPV } from 'pvnameParser';
 
 export class AAclient {
@@ -71,7 +71,7 @@ export class AAclient {
 
           try {
             urls = _.map(pvnames, (pvname) =>
-              this.buildUrl(this.url, pvname, target.operator, binInterval, target.from, target.to)
+              this.buildUrl(this.url, pvname, target.operator, binInterval, target.maxDataPoints, target.from, target.to)
             );
           } catch (e) {
             reject(e);
@@ -104,8 +104,21 @@ export class AAclient {
     return { method: 'GET', url, headers: this.headers, withCredentials: this.withCredentials };
   }
 
-  private buildUrl(baseUrl: string, pvname: string, operator: string, interval: string, from: Date, to: Date) {
+  private buildUrl(
+    baseUrl: string,
+    pvname: string,
+    operator: string,
+    interval: string,
+    maxDataPoints: number,
+    from: Date,
+    to: Date
+  ) {
     const pv = (() => {
+      // Optimized operators take the number of points instead of the bin interval
+      if (_.includes(pointCountOperatorList, operator)) {
+        return maxDataPoints > 0 ? `${operator}_${maxDataPoints}(${pvname})` : `${pvname}`;
+      }
+
       // raw Operator or last Operator or interval is less than 1 sec
       if (operator === 'raw' || operator === 'last' || interval === '') {
         return `${pvname}`;
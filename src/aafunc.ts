@@ -1,7 +1,7 @@
 import _ from 'lodash';
 import { FuncDef, FunctionDescriptor } from './types';
 import { DataFrame } from '@grafana/data';
-import { arrayFunctions, seriesFunctions } from './dataProcessor';
+import { arrayFunctions, createSeriesFunctions, TimeRangeMs } from './dataProcessor';
 
 const funcIndex: { [key: string]: FuncDef } = {};
 const categories: { [key: string]: FuncDef[] } = {
@@ -9,6 +9,7 @@ const categories: { [key: string]: FuncDef[] } = {
   'Array to Scalar': [],
   'Filter Series': [],
   Sort: [],
+  Math: [],
   Options: [],
 };
 
@@ -99,6 +100,45 @@ addFuncDef({
   defaultParams: ['10'],
 });
 
+addFuncDef({
+  name: 'derivative',
+  category: 'Transform',
+  params: [{ name: 'unit', type: 'string', options: ['1s', '1m', '1h'] }],
+  defaultParams: ['1s'],
+});
+
+addFuncDef({
+  name: 'nonNegativeDerivative',
+  category: 'Transform',
+  params: [{ name: 'unit', type: 'string', options: ['1s', '1m', '1h'] }],
+  defaultParams: ['1s'],
+});
+
+addFuncDef({
+  name: 'integral',
+  category: 'Transform',
+  params: [{ name: 'unit', type: 'string', options: ['1s', '1m', '1h'] }],
+  defaultParams: ['1s'],
+});
+
+addFuncDef({
+  name: 'cumulativeSum',
+  category: 'Transform',
+  params: [],
+  defaultParams: [],
+});
+
+addFuncDef({
+  name: 'resample',
+  category: 'Transform',
+  backendOnly: true,
+  params: [
+    { name: 'interval', type: 'string', options: ['first', '1s', '10s', '1m', '10m', '1h'] },
+    { name: 'method', type: 'string', options: ['step', 'linear', 'nearest'] },
+  ],
+  defaultParams: ['1m', 'step'],
+});
+
 // Array to Scalar
 
 addFuncDef({
@@ -145,6 +185,18 @@ addFuncDef({
 
 // Filter Series
 
+const rankValueOptions = [
+  'avg',
+  'min',
+  'max',
+  'absoluteMin',
+  'absoluteMax',
+  'sum',
+  'timeWeightedAvg',
+  'timeAboveThreshold',
+  'dutyCycle',
+];
+
 addFuncDef({
   name: 'top',
   category: 'Filter Series',
@@ -153,10 +205,11 @@ addFuncDef({
     {
       name: 'value',
       type: 'string',
-      options: ['avg', 'min', 'max', 'absoluteMin', 'absoluteMax', 'sum'],
+      options: rankValueOptions,
     },
+    { name: 'threshold', type: 'float' },
   ],
-  defaultParams: ['5', 'avg'],
+  defaultParams: ['5', 'avg', '0'],
 });
 
 addFuncDef({
@@ -167,10 +220,11 @@ addFuncDef({
     {
       name: 'value',
       type: 'string',
-      options: ['avg', 'min', 'max', 'absoluteMin', 'absoluteMax', 'sum'],
+      options: rankValueOptions,
     },
+    { name: 'threshold', type: 'float' },
   ],
-  defaultParams: ['5', 'avg'],
+  defaultParams: ['5', 'avg', '0'],
 });
 
 addFuncDef({
@@ -223,12 +277,31 @@ addFuncDef({
   defaultParams: ['desc'],
 });
 
+addFuncDef({
+  name: 'sortByTimeWeightedAvg',
+  category: 'Sort',
+  params: [{ name: 'order', type: 'string', options: ['desc', 'asc'] }],
+  defaultParams: ['desc'],
+});
+
+// Math
+
+addFuncDef({
+  name: 'math',
+  category: 'Math',
+  params: [
+    { name: 'expression', type: 'string' },
+    { name: 'alias', type: 'string' },
+  ],
+  defaultParams: ['#A - #B', ''],
+});
+
 // Options
 
 addFuncDef({
   name: 'fieldName',
   category: 'Options',
-  params: [{ name: 'name', type: 'string', options: ['VAL', 'SEVR', 'STAT', 'SEVR as Enum', 'STAT as Enum'] }],
+  params: [{ name: 'name', type: 'string', options: ['VAL', 'SEVR', 'STAT', 'SEVR as Enum', 'STAT as Enum', 'VAL with Alarm'] }],
   defaultParams: ['SEVR'],
 });
 
@@ -281,6 +354,48 @@ addFuncDef({
   defaultParams: ['true'],
 });
 
+addFuncDef({
+  name: 'alarmThresholds',
+  category: 'Options',
+  params: [{ name: 'boolean', type: 'string', options: ['true', 'false'] }],
+  defaultParams: ['true'],
+});
+
+addFuncDef({
+  name: 'sampleMetadata',
+  category: 'Options',
+  params: [{ name: 'boolean', type: 'string', options: ['true', 'false'] }],
+  defaultParams: ['true'],
+});
+
+addFuncDef({
+  name: 'timeout',
+  category: 'Options',
+  params: [{ name: 'seconds', type: 'int' }],
+  defaultParams: ['30'],
+});
+
+addFuncDef({
+  name: 'processing',
+  category: 'Options',
+  params: [{ name: 'processing', type: 'string', options: ['appliance', 'local'] }],
+  defaultParams: ['local'],
+});
+
+addFuncDef({
+  name: 'alignAll',
+  category: 'Options',
+  params: [{ name: 'boolean', type: 'string', options: ['true', 'false'] }],
+  defaultParams: ['true'],
+});
+
+addFuncDef({
+  name: 'frameFormat',
+  category: 'Options',
+  params: [{ name: 'format', type: 'string', options: ['narrow', 'wide'] }],
+  defaultParams: ['wide'],
+});
+
 addFuncDef({
   name: 'liveOnly',
   category: 'Options',
@@ -306,8 +421,13 @@ export function createFuncDescriptor(funcDef: FuncDef, params?: string[]): Funct
   return { def: funcDef, params: params };
 }
 
-export function applyFunctionDefs(functionDefs: FunctionDescriptor[], dataFrames: DataFrame[]) {
-  const applyFuncDefs = pickFuncDefsFromCategories(functionDefs, ['Transform', 'Filter Series', 'Sort']);
+export function applyFunctionDefs(functionDefs: FunctionDescriptor[], dataFrames: DataFrame[], range?: TimeRangeMs) {
+  const applyFuncDefs = _.reject(
+    pickFuncDefsFromCategories(functionDefs, ['Transform', 'Filter Series', 'Sort']),
+    (func) => func.def.backendOnly
+  );
+
+  const seriesFunctions = createSeriesFunctions(range);
 
   const promises = _.reduce(
     applyFuncDefs,
@@ -1,5 +1,17 @@
 import React, { PureComponent, ChangeEvent } from 'react';
-import { Input, Field, Label, Icon, Tooltip, Combobox, ComboboxOption, Divider, Switch, Stack } from '@grafana/ui';
+import {
+  Input,
+  Field,
+  Label,
+  Icon,
+  Tooltip,
+  Combobox,
+  ComboboxOption,
+  Divider,
+  Switch,
+  Stack,
+  TagsInput,
+} from '@grafana/ui';
 import { DataSourcePluginOptionsEditorProps } from '@grafana/data';
 import { AADataSourceOptions, operatorList } from '../types';
 import { toComboboxOption } from './utils';
@@ -15,6 +27,7 @@ import {
 
 //const LABEL_WIDTH = 26;
 const operatorOptions: Array<ComboboxOption<string>> = operatorList.map(toComboboxOption);
+const processingOptions: Array<ComboboxOption<string>> = ['appliance', 'local'].map(toComboboxOption);
 
 export type Props = DataSourcePluginOptionsEditorProps<AADataSourceOptions>;
 
@@ -41,6 +54,15 @@ export class ConfigEditor extends PureComponent<Props> {
     onOptionsChange({ ...options, jsonData });
   };
 
+  onProcessingChange = (option: ComboboxOption) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      defaultProcessing: option.value,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
   onHideInvalidChange = (event: React.SyntheticEvent<HTMLInputElement>) => {
     const { onOptionsChange, options } = this.props;
     const jsonData = {
@@ -68,6 +90,107 @@ export class ConfigEditor extends PureComponent<Props> {
     onOptionsChange({ ...options, jsonData });
   };
 
+  onQueryTimeoutChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      queryTimeout: parseInt(event.target.value, 10) || 0,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onReplicaURLsChange = (urls: string[]) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      replicaURLs: urls,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onApplianceURLsChange = (urls: string[]) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      applianceURLs: urls,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onSecondaryURLChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      secondaryURL: event.target.value,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onSecondaryCutoverChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      secondaryCutover: event.target.value,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onMaxRetriesChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const value = parseInt(event.target.value, 10);
+    const jsonData = {
+      ...options.jsonData,
+      // The default is used if it's empty
+      maxRetries: isNaN(value) ? undefined : value,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onRetryMaxDelayChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      retryMaxDelay: parseInt(event.target.value, 10) || 0,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onCacheTTLChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      cacheTTL: parseInt(event.target.value, 10) || 0,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onCacheMaxSizeChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      cacheMaxSize: parseInt(event.target.value, 10) || 0,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onMaxConcurrencyChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      maxConcurrency: parseInt(event.target.value, 10) || 0,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
+  onMaxQueryConcurrencyChange = (event: ChangeEvent<HTMLInputElement>) => {
+    const { onOptionsChange, options } = this.props;
+    const jsonData = {
+      ...options.jsonData,
+      maxQueryConcurrency: parseInt(event.target.value, 10) || 0,
+    };
+    onOptionsChange({ ...options, jsonData });
+  };
+
   render() {
     const { options, onOptionsChange } = this.props;
 
@@ -148,6 +271,35 @@ export class ConfigEditor extends PureComponent<Props> {
                 />
               </Field>
 
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Default Processing</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Selects where the operator is applied. <code>appliance</code> applies the operator in the
+                            appliance. <code>local</code> retrieves the raw data and applies mean, min, max, count, std,
+                            firstSample, lastSample and median operators in the backend.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Combobox
+                  value={options.jsonData.defaultProcessing}
+                  options={processingOptions}
+                  width={40}
+                  onChange={this.onProcessingChange}
+                  placeholder="appliance"
+                />
+              </Field>
+
               <Field
                 label={
                   <Label>
@@ -162,6 +314,301 @@ export class ConfigEditor extends PureComponent<Props> {
               >
                 <Switch value={options.jsonData.hideInvalid ?? false} onChange={this.onHideInvalidChange} />
               </Field>
+
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Query Timeout</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Timeout of a query in seconds. The PVs whose data is not retrieved in time are shown in the
+                            panel warning.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  type="number"
+                  value={options.jsonData.queryTimeout}
+                  placeholder="30"
+                  width={40}
+                  onChange={this.onQueryTimeoutChange}
+                />
+              </Field>
+            </ConfigSubSection>
+
+            <ConfigSubSection title="Failover Options">
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Replica URLs</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Retrieval URLs of the replicas of the appliance in the order of preference. The queries fail
+                            over to the next URL on connection failure or 5xx response.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <TagsInput
+                  tags={options.jsonData.replicaURLs ?? []}
+                  placeholder="http://localhost:17668/retrieval"
+                  width={40}
+                  onChange={this.onReplicaURLsChange}
+                />
+              </Field>
+            </ConfigSubSection>
+
+            <ConfigSubSection title="Federation Options">
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Additional Appliance URLs</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Retrieval URLs of the other appliances. PVs are searched in all appliances and the data is
+                            retrieved from the appliance which archives the PV.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <TagsInput
+                  tags={options.jsonData.applianceURLs ?? []}
+                  placeholder="http://localhost:17668/retrieval"
+                  width={40}
+                  onChange={this.onApplianceURLsChange}
+                />
+              </Field>
+            </ConfigSubSection>
+
+            <ConfigSubSection title="Secondary Source Options">
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Secondary URL</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Retrieval URL of the secondary source, e.g. the legacy archive. The data before the cutover
+                            date is retrieved from the secondary source.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  value={options.jsonData.secondaryURL}
+                  placeholder="http://localhost:17668/retrieval"
+                  width={40}
+                  onChange={this.onSecondaryURLChange}
+                />
+              </Field>
+
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Cutover Date</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Date in UTC or RFC 3339 date-time when the data retrieval is switched from the secondary
+                            source to the appliance.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  value={options.jsonData.secondaryCutover}
+                  placeholder="2015-04-01"
+                  width={40}
+                  onChange={this.onSecondaryCutoverChange}
+                />
+              </Field>
+            </ConfigSubSection>
+
+            <ConfigSubSection title="Retry Options">
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Max Retries</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Maximum number of the retries on connection errors, timeouts and 502, 503 or 504 responses.
+                            Set 0 to disable the retry.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  type="number"
+                  value={options.jsonData.maxRetries}
+                  placeholder="3"
+                  width={40}
+                  onChange={this.onMaxRetriesChange}
+                />
+              </Field>
+
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Retry Max Delay</span>
+                      <Tooltip content={<span>Maximum delay between the retries in seconds.</span>}>
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  type="number"
+                  value={options.jsonData.retryMaxDelay}
+                  placeholder="10"
+                  width={40}
+                  onChange={this.onRetryMaxDelayChange}
+                />
+              </Field>
+            </ConfigSubSection>
+
+            <ConfigSubSection title="Concurrency Options">
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Max Concurrency</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Maximum number of the concurrent requests from this datasource to the archiver. The requests
+                            over the limit wait in the queue.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  type="number"
+                  value={options.jsonData.maxConcurrency}
+                  placeholder="50"
+                  width={40}
+                  onChange={this.onMaxConcurrencyChange}
+                />
+              </Field>
+
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Max Query Concurrency</span>
+                      <Tooltip content={<span>Maximum number of the concurrent requests for the PVs of a query.</span>}>
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  type="number"
+                  value={options.jsonData.maxQueryConcurrency}
+                  placeholder="10"
+                  width={40}
+                  onChange={this.onMaxQueryConcurrencyChange}
+                />
+              </Field>
+            </ConfigSubSection>
+
+            <ConfigSubSection title="Cache Options">
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Cache TTL</span>
+                      <Tooltip
+                        content={
+                          <span>
+                            Time in seconds to keep the retrieved data in the backend cache. The cache is disabled if
+                            this is 0.
+                          </span>
+                        }
+                      >
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  type="number"
+                  value={options.jsonData.cacheTTL}
+                  placeholder="0"
+                  width={40}
+                  onChange={this.onCacheTTLChange}
+                />
+              </Field>
+
+              <Field
+                label={
+                  <Label>
+                    <EditorStack gap={0.5}>
+                      <span>Cache Max Size</span>
+                      <Tooltip content={<span>Maximum number of the elements kept in the backend cache. A waveform sample counts its elements.</span>}>
+                        <Icon name="info-circle" size="sm" />
+                      </Tooltip>
+                    </EditorStack>
+                  </Label>
+                }
+              >
+                <Input
+                  type="number"
+                  value={options.jsonData.cacheMaxSize}
+                  placeholder="1000000"
+                  width={40}
+                  onChange={this.onCacheMaxSizeChange}
+                />
+              </Field>
             </ConfigSubSection>
 
             <ConfigSubSection title="Live Feature Options">
@@ -1,5 +1,5 @@
 import _ from 'lodash';
-import { createDataFrame, DataFrame, getFieldDisplayName } from '@grafana/data';
+import { createDataFrame, DataFrame, getFieldDisplayName, rangeUtil } from '@grafana/data';
 import * as math from 'mathjs';
 
 // Transform
@@ -19,8 +19,8 @@ function offset(delta: number, times: number[], values: number[]) {
 }
 
 function delta(times: number[], values: number[]) {
-  const newTimes = [];
-  const newValues = [];
+  const newTimes: number[] = [];
+  const newValues: Array<number | null> = [];
 
   for (let i = 1; i < values.length; i += 1) {
     const deltaValue = values[i] - values[i - 1];
@@ -67,9 +67,82 @@ function movingAverage(windowSize: number, times: number[], values: number[]) {
   };
 }
 
+function derivativeCore(nonNegative: boolean, unit: string, times: number[], values: Array<number | null>) {
+  const unitMs = rangeUtil.intervalToMs(unit);
+  const newTimes: number[] = [];
+  const newValues: Array<number | null> = [];
+
+  // Rate from the previous valid sample
+  let prev = -1;
+  for (let i = 0; i < values.length; i += 1) {
+    if (prev < 0) {
+      if (values[i] !== null) {
+        prev = i;
+      }
+      continue;
+    }
+
+    const dt = times[i] - times[prev];
+    if (dt <= 0) {
+      continue;
+    }
+
+    newTimes.push(times[i]);
+    const value = values[i];
+    if (value === null) {
+      newValues.push(null);
+      continue;
+    }
+
+    const rate = (value - (values[prev] as number)) / (dt / unitMs);
+    prev = i;
+    newValues.push(nonNegative && rate < 0 ? null : rate);
+  }
+
+  return {
+    times: newTimes,
+    values: newValues,
+  };
+}
+
+function integral(unit: string, times: number[], values: Array<number | null>) {
+  const unitMs = rangeUtil.intervalToMs(unit);
+  let total = 0;
+
+  const newValues = _.map(values, (value, i) => {
+    const prev = values[i - 1];
+    if (i > 0 && value !== null && prev !== null) {
+      total += ((prev + value) / 2) * ((times[i] - times[i - 1]) / unitMs);
+    }
+    return total;
+  });
+
+  return {
+    times: times,
+    values: newValues,
+  };
+}
+
+function cumulativeSum(times: number[], values: Array<number | null>) {
+  let total = 0;
+
+  const newValues = _.map(values, (value) => {
+    if (value === null) {
+      return null;
+    }
+    total += value;
+    return total;
+  });
+
+  return {
+    times: times,
+    values: newValues,
+  };
+}
+
 // [Support Funcs] Transform wrapper
 
-function transformWrapper(func: (...args: any) => { times: number[]; values: number[] }, ...args: any) {
+function transformWrapper(func: (...args: any) => { times: number[]; values: Array<number | null> }, ...args: any) {
   const funcArgs = args.slice(0, -1);
   const dataFrames: DataFrame[] = args[args.length - 1];
 
@@ -143,20 +216,101 @@ function datapointsAbsMax(values: number[]) {
   return Math.abs(maxPoint);
 }
 
-const datapointsAggFuncs: { [key: string]: (values: number[]) => number | undefined } = {
+// Time range of the query in milliseconds
+export interface TimeRangeMs {
+  from: number;
+  to: number;
+}
+
+// Each datapoint lasts until the next datapoint, and the last datapoint is held until the end of the time range.
+// The durations are clipped to the time range, and null datapoints have no duration.
+// Without the time range, the last datapoint has no duration.
+function datapointsTimeWeighted(values: Array<number | null>, times: number[], threshold: number, range?: TimeRangeMs) {
+  const from = range ? range.from : -Infinity;
+  const to = range ? range.to : times[times.length - 1];
+  let total = 0;
+  let duration = 0;
+  let above = 0;
+
+  for (let i = 0; i < values.length; i += 1) {
+    const value = values[i];
+    if (value === null) {
+      continue;
+    }
+
+    const start = Math.max(times[i], from);
+    const end = i + 1 < times.length ? Math.min(times[i + 1], to) : to;
+    if (end <= start) {
+      continue;
+    }
+
+    const dt = (end - start) / 1000;
+    total += value * dt;
+    duration += dt;
+    if (value > threshold) {
+      above += dt;
+    }
+  }
+
+  return { total, duration, above };
+}
+
+function datapointsTimeWeightedAvg(
+  values: Array<number | null>,
+  times: number[],
+  threshold: number,
+  range?: TimeRangeMs
+) {
+  const { total, duration } = datapointsTimeWeighted(values, times, 0, range);
+
+  if (duration === 0) {
+    return _.mean(_.reject(values, _.isNull));
+  }
+  return total / duration;
+}
+
+function datapointsTimeAboveThreshold(
+  values: Array<number | null>,
+  times: number[],
+  threshold: number,
+  range?: TimeRangeMs
+) {
+  return datapointsTimeWeighted(values, times, threshold, range).above;
+}
+
+function datapointsDutyCycle(values: Array<number | null>, times: number[], threshold: number, range?: TimeRangeMs) {
+  const { duration, above } = datapointsTimeWeighted(values, times, threshold, range);
+
+  if (duration === 0) {
+    return 0;
+  }
+  return above / duration;
+}
+
+const datapointsAggFuncs: {
+  [key: string]: (values: any[], times: number[], threshold: number, range?: TimeRangeMs) => number | undefined;
+} = {
   avg: datapointsAvg,
   min: datapointsMin,
   max: datapointsMax,
   sum: datapointsSum,
   absoluteMin: datapointsAbsMin,
   absoluteMax: datapointsAbsMax,
+  timeWeightedAvg: datapointsTimeWeightedAvg,
+  timeAboveThreshold: datapointsTimeAboveThreshold,
+  dutyCycle: datapointsDutyCycle,
 };
 
 // [Support Funcs] Wrapper function for top and bottom function
 
-function extraction(order: string, n: number, orderFunc: string, dataFrames: DataFrame[]) {
+function extraction(order: string, range: TimeRangeMs | undefined, n: number, orderFunc: string, ...args: any) {
+  // The functions saved before the threshold was added don't have it
+  const threshold: number = args.length > 1 ? args[0] : 0;
+  const dataFrames: DataFrame[] = args[args.length - 1];
+
   const orderByCallback = datapointsAggFuncs[orderFunc];
-  const sortByIteratee = (dataFrame: DataFrame) => orderByCallback(dataFrame.fields[1].values);
+  const sortByIteratee = (dataFrame: DataFrame) =>
+    orderByCallback(dataFrame.fields[1].values, dataFrame.fields[0].values, threshold, range);
 
   const sortedTsData = _.sortBy(dataFrames, sortByIteratee);
   if (order === 'bottom') {
@@ -167,9 +321,10 @@ function extraction(order: string, n: number, orderFunc: string, dataFrames: Dat
 }
 
 // [Support Funcs] Wrapper function for sort by AggFuncs
-function sortByAggFuncs(orderFunc: string, order: string, dataFrames: DataFrame[]) {
+function sortByAggFuncs(orderFunc: string, range: TimeRangeMs | undefined, order: string, dataFrames: DataFrame[]) {
   const orderByCallback = datapointsAggFuncs[orderFunc];
-  const sortByIteratee = (dataFrame: DataFrame) => orderByCallback(dataFrame.fields[1].values);
+  const sortByIteratee = (dataFrame: DataFrame) =>
+    orderByCallback(dataFrame.fields[1].values, dataFrame.fields[0].values, 0, range);
 
   const sortedTsData = _.sortBy(dataFrames, sortByIteratee);
 
@@ -181,26 +336,34 @@ function sortByAggFuncs(orderFunc: string, order: string, dataFrames: DataFrame[
 }
 
 // Function list
+// The filter and sort functions use the time range for the time-weighted values
 
-const functions = {
+const createFunctions = (range?: TimeRangeMs) => ({
   // Transform
   scale: _.partial(transformWrapper, scale),
   offset: _.partial(transformWrapper, offset),
   delta: _.partial(transformWrapper, delta),
   fluctuation: _.partial(transformWrapper, fluctuation),
   movingAverage: _.partial(transformWrapper, movingAverage),
+  derivative: _.partial(transformWrapper, _.partial(derivativeCore, false)),
+  nonNegativeDerivative: _.partial(transformWrapper, _.partial(derivativeCore, true)),
+  integral: _.partial(transformWrapper, integral),
+  cumulativeSum: _.partial(transformWrapper, cumulativeSum),
   // Filter Series
-  top: _.partial(extraction, 'top'),
-  bottom: _.partial(extraction, 'bottom'),
+  top: _.partial(extraction, 'top', range),
+  bottom: _.partial(extraction, 'bottom', range),
   exclude,
   // Sort
-  sortByAvg: _.partial(sortByAggFuncs, 'avg'),
-  sortByMax: _.partial(sortByAggFuncs, 'max'),
-  sortByMin: _.partial(sortByAggFuncs, 'min'),
-  sortBySum: _.partial(sortByAggFuncs, 'sum'),
-  sortByAbsMax: _.partial(sortByAggFuncs, 'absoluteMax'),
-  sortByAbsMin: _.partial(sortByAggFuncs, 'absoluteMin'),
-};
+  sortByAvg: _.partial(sortByAggFuncs, 'avg', range),
+  sortByMax: _.partial(sortByAggFuncs, 'max', range),
+  sortByMin: _.partial(sortByAggFuncs, 'min', range),
+  sortBySum: _.partial(sortByAggFuncs, 'sum', range),
+  sortByAbsMax: _.partial(sortByAggFuncs, 'absoluteMax', range),
+  sortByAbsMin: _.partial(sortByAggFuncs, 'absoluteMin', range),
+  sortByTimeWeightedAvg: _.partial(sortByAggFuncs, 'timeWeightedAvg', range),
+});
+
+const functions = createFunctions();
 
 const arrayFunctions: { [key: string]: { func: any; label: string } } = {
   toScalarByAvg: { func: datapointsAvg, label: 'avg' },
@@ -211,4 +374,4 @@ const arrayFunctions: { [key: string]: { func: any; label: string } } = {
   toScalarByStd: { func: math.std, label: 'std' },
 };
 
-export { functions as seriesFunctions, arrayFunctions };
+export { functions as seriesFunctions, createFunctions as createSeriesFunctions, arrayFunctions };
@@ -2,6 +2,7 @@ import _ from 'lodash';
 import { createDataFrame, DataFrame, getFieldDisplayName } from '@grafana/data';
 
 import { applyFunctionDefs } from './aafunc';
+import { TimeRangeMs } from './dataProcessor';
 import { TargetQuery } from './types';
 import { AAclient } from 'aaclient';
 import { responseParse } from 'responseParse';
@@ -66,12 +67,16 @@ export async function setAlias(dataFrames: DataFrame[], target: TargetQuery): Pr
   return Promise.resolve(newDataFrames);
 }
 
-export function applyFunctions(dataFrames: DataFrame[], target: TargetQuery) {
+export function applyFunctions(
+  dataFrames: DataFrame[],
+  target: TargetQuery,
+  range: TimeRangeMs = { from: target.from.getTime(), to: target.to.getTime() }
+) {
   if (target.functions === undefined) {
     return Promise.resolve(dataFrames);
   }
 
-  return applyFunctionDefs(target.functions, dataFrames);
+  return applyFunctionDefs(target.functions, dataFrames, range);
 }
 
 function targetProcess(responses: any, target: TargetQuery) {
@@ -11,7 +11,7 @@ import { from } from 'rxjs';
 import * as runtime from '@grafana/runtime';
 import { DataSource } from '../DataSource';
 import * as aafunc from '../aafunc';
-import { seriesFunctions } from '../dataProcessor';
+import { createSeriesFunctions, seriesFunctions } from '../dataProcessor';
 import { AAQuery, AADataSourceOptions } from '../types';
 
 const fetchMock = jest.fn().mockResolvedValue(createDefaultResponse());
@@ -310,6 +310,73 @@ describe('Archiverappliance Functions', () => {
     });
   });
 
+  it('should return the server results with derivative and integral functions', (done) => {
+    fetchMock.mockImplementation((request) =>
+      from([
+        {
+          _request: request,
+          data: [
+            {
+              meta: { name: 'PV', PREC: '0' },
+              data: [
+                { millis: 1262304000000, val: 1 },
+                { millis: 1262304001000, val: 3 },
+                { millis: 1262304003000, val: 2 },
+                { millis: 1262304004000, val: 6 },
+              ],
+            },
+          ],
+        },
+      ])
+    );
+
+    const query = {
+      targets: [
+        {
+          target: 'PV',
+          refId: 'A',
+          functions: [aafunc.createFuncDescriptor(aafunc.getFuncDef('derivative'), ['1s'])],
+        },
+        {
+          target: 'PV',
+          refId: 'B',
+          functions: [aafunc.createFuncDescriptor(aafunc.getFuncDef('nonNegativeDerivative'), ['1m'])],
+        },
+        {
+          target: 'PV',
+          refId: 'C',
+          functions: [aafunc.createFuncDescriptor(aafunc.getFuncDef('integral'), ['1s'])],
+        },
+        {
+          target: 'PV',
+          refId: 'D',
+          functions: [aafunc.createFuncDescriptor(aafunc.getFuncDef('cumulativeSum'), [])],
+        },
+      ],
+      range: { from: new Date('2010-01-01T00:00:00.000Z'), to: new Date('2010-01-02T00:00:00.000Z') },
+      maxDataPoints: 1000,
+    } as unknown as DataQueryRequest<AAQuery>;
+
+    ds.query(query).subscribe((result: any) => {
+      expect(result.data).toHaveLength(4);
+
+      const derivative = result.data[0].fields;
+      expect(derivative[0].values).toEqual([1262304001000, 1262304003000, 1262304004000]);
+      expect(derivative[1].values).toEqual([2, -0.5, 4]);
+
+      const nonNegative = result.data[1].fields;
+      expect(nonNegative[1].values).toEqual([120, null, 240]);
+
+      const integral = result.data[2].fields;
+      expect(integral[0].values).toHaveLength(4);
+      expect(integral[1].values).toEqual([0, 2, 7, 11]);
+
+      const cumulativeSum = result.data[3].fields;
+      expect(cumulativeSum[1].values).toEqual([1, 4, 6, 12]);
+      done();
+    });
+  });
+
   it('should return correct scalar data with toScalar funcs', (done) => {
     fetchMock.mockImplementation((request) =>
       from([
@@ -497,6 +564,93 @@ describe('Archiverappliance Functions', () => {
     });
   });
 
+  it('should return the server results with time-weighted rank functions', (done) => {
+    fetchMock.mockImplementation((request) => {
+      const pvname = unescape(split(request.url, /pv=mean_[0-9].*\((.*?)\)&/)[1]);
+      // PV1 has the higher plain average, but it is 0 for most of the time
+      const data =
+        pvname === 'PV1'
+          ? [
+              { millis: 1262304000000, val: 0 },
+              { millis: 1262304010000, val: 10 },
+              { millis: 1262304011000, val: 0 },
+            ]
+          : [
+              { millis: 1262304000000, val: 2 },
+              { millis: 1262304011000, val: 2 },
+            ];
+
+      return from([
+        {
+          _request: request,
+          data: [{ meta: { name: pvname, PREC: '0' }, data: data }],
+        },
+      ]);
+    });
+
+    const query = {
+      targets: [
+        {
+          target: '(PV1|PV2)',
+          refId: 'A',
+          functions: [aafunc.createFuncDescriptor(aafunc.getFuncDef('sortByTimeWeightedAvg'), ['desc'])],
+        },
+        {
+          target: '(PV1|PV2)',
+          refId: 'B',
+          functions: [aafunc.createFuncDescriptor(aafunc.getFuncDef('top'), ['1', 'dutyCycle', '1'])],
+        },
+        {
+          target: '(PV1|PV2)',
+          refId: 'C',
+          functions: [aafunc.createFuncDescriptor(aafunc.getFuncDef('top'), ['1', 'avg', '0'])],
+        },
+      ],
+      range: { from: new Date('2010-01-01T00:00:00.000Z'), to: new Date('2010-01-02T00:00:00.000Z') },
+      maxDataPoints: 1000,
+    } as unknown as DataQueryRequest<AAQuery>;
+
+    ds.query(query).subscribe((result: any) => {
+      const dataFrameArray: DataFrame[] = result.data;
+      const pvnames = dataFrameArray.map((dataFrame) => getFieldDisplayName(dataFrame.fields[1], dataFrame));
+
+      expect(pvnames).toEqual(['PV2', 'PV1', 'PV2', 'PV1']);
+      done();
+    });
+  });
+
+  it('should clip time-weighted values to the time range', () => {
+    const rangeFrom = 1262304000000;
+    const minute = 60000;
+    // PV1 is 100 before the time range and 1 from 3 minutes, PV2 is 30 in the time range
+    const data = [
+      { name: 'PV1', times: [rangeFrom - 10 * minute, rangeFrom + 3 * minute], values: [100, 1] },
+      { name: 'PV2', times: [rangeFrom], values: [30] },
+    ];
+
+    const timeseriesData: DataFrame[] = data.map((d) =>
+      createDataFrame({
+        name: d.name,
+        fields: [
+          { name: 'time', type: FieldType.time, values: d.times },
+          { name: 'value', type: FieldType.number, values: d.values, config: { displayName: d.name } },
+        ],
+      })
+    );
+
+    const functions = createSeriesFunctions({ from: rangeFrom, to: rangeFrom + 13 * minute });
+
+    // The time-weighted average of PV1 is (100 * 3 + 1 * 10) / 13 minutes and the last datapoints are held
+    const sorted = functions.sortByTimeWeightedAvg('desc', timeseriesData);
+    expect(sorted.map((d: DataFrame) => d.name)).toEqual(['PV2', 'PV1']);
+
+    const dutyCycle = functions.top(1, 'dutyCycle', 50, timeseriesData);
+    expect(dutyCycle.map((d: DataFrame) => d.name)).toEqual(['PV1']);
+
+    const timeAbove = functions.bottom(1, 'timeAboveThreshold', 10, timeseriesData);
+    expect(timeAbove.map((d: DataFrame) => d.name)).toEqual(['PV1']);
+  });
+
   it('should return aggregated value', () => {
     const data = [
       {
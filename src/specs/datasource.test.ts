@@ -271,12 +271,13 @@ describe('Archiverappliance Datasource', () => {
         { target: 'PV4', interval: '9', from, to, options } as unknown as TargetQuery,
         { target: 'PV5', operator: 'max', interval: '', from, to, options } as unknown as TargetQuery,
         { target: 'PV6', operator: 'last', interval: '9', from, to, options } as unknown as TargetQuery,
+        { target: 'PV7', operator: 'optimized', maxDataPoints: 1000, from, to, options } as unknown as TargetQuery,
       ];
 
       const urlProcs = targets.map((target) => ds.aaclient.buildUrls(target));
 
       Promise.all(urlProcs).then((urls) => {
-        expect(urls).toHaveLength(6);
+        expect(urls).toHaveLength(7);
         expect(urls[0][0]).toBe(
           'url_header:/data/getData.qw?pv=mean_9(PV1)&from=2010-01-01T00:00:00.000Z&to=2010-01-01T00:00:30.000Z'
         );
@@ -295,6 +296,9 @@ describe('Archiverappliance Datasource', () => {
         expect(urls[5][0]).toBe(
           'url_header:/data/getData.qw?pv=PV6&from=2010-01-01T00:00:30.000Z&to=2010-01-01T00:00:30.000Z'
         );
+        expect(urls[6][0]).toBe(
+          'url_header:/data/getData.qw?pv=optimized_1000(PV7)&from=2010-01-01T00:00:00.000Z&to=2010-01-01T00:00:30.000Z'
+        );
         done();
       });
     });
@@ -1628,6 +1632,19 @@ describe('Archiverappliance Datasource', () => {
         done();
       });
     });
+
+    it('should return the pv name results for metricFindQuery with pattern parameter', (done) => {
+      fetchMock.mockImplementation((request) =>
+        from([{ _request: request, data: ['PV:NAME1:A', 'PV:NAME2:A', 'PV:NAME1:B', 'OTHER'] }])
+      );
+
+      ds.metricFindQuery('PV.*?limit=5&pattern=PV:(?<value>.*?):(?<text>.*)').then((result: any) => {
+        expect(result).toHaveLength(2);
+        expect(result[0]).toEqual({ text: 'A', value: 'NAME1' });
+        expect(result[1]).toEqual({ text: 'A', value: 'NAME2' });
+        done();
+      });
+    });
   });
 });
 
@@ -129,7 +129,8 @@ function doQueryStream(
         .then((responses) => responseParse(responses, targets[i], true))
         .then((dataFrames) => mergeToBuffers(dataFrames, buffers, targets[i]))
         .then((dataFrames) => setAlias(dataFrames, targets[i]))
-        .then((dataFrames) => applyFunctions(dataFrames, targets[i]));
+        // The buffers hold the datapoints before the time range of the stream request
+        .then((dataFrames) => applyFunctions(dataFrames, targets[i], { from: -Infinity, to: targets[i].to.getTime() }));
     });
 
     // Wait all target data processings
@@ -81,6 +81,8 @@ export interface FuncDef {
   category: string;
   description?: string;
   fake?: boolean;
+  // The function is only applied by the backend data retrieval
+  backendOnly?: boolean;
   name: string;
   params: FuncDefParam[];
 }
@@ -118,8 +120,17 @@ export const operatorList: string[] = [
   'skewness',
   'raw',
   'last',
+  'optimized',
+  'optimLastSample',
+  'linear',
+  'loess',
+  'caplotbinning',
+  'deadBand',
 ];
 
+// Operators whose parameter is the number of points instead of the bin interval
+export const pointCountOperatorList: string[] = ['optimized', 'optimLastSample'];
+
 export function isNumberArray(response: AADataQueryData): response is AADataQueryDataNumberArray {
   if (!response.meta.waveform) {
     return false;
@@ -142,6 +153,18 @@ export interface AADataSourceOptions extends DataSourceJsonData {
   hideInvalid?: boolean;
   useLiveUpdate?: boolean;
   liveUpdateURI?: string;
+  cacheTTL?: number;
+  cacheMaxSize?: number;
+  maxConcurrency?: number;
+  maxQueryConcurrency?: number;
+  queryTimeout?: number;
+  defaultProcessing?: string;
+  maxRetries?: number;
+  retryMaxDelay?: number;
+  applianceURLs?: string[];
+  replicaURLs?: string[];
+  secondaryURL?: string;
+  secondaryCutover?: string;
 }
 
 /**